@@ -20,6 +20,11 @@ type Adapter interface {
 type BaseAdapter struct {
 	Name string
 	Ctx  data.GremelContext
+
+	// The columns in the order they appear in the source (e.g. the CSV
+	// header), which parsers record as they go, since map rows can't
+	// preserve key order
+	columns data.ColumnOrder
 }
 
 func NewBaseAdapter(name string, ctx data.GremelContext) *BaseAdapter {
@@ -43,27 +48,37 @@ func (a *BaseAdapter) Load() ([]map[string]any, []string, error) {
 	return nil, nil, fmt.Errorf("Load(): Default implementation does nothing")
 }
 
+// GetHeadings returns the columns picked by the 'select' hint or, failing that,
+// all of the columns in rows in the order they appear in the source
 func (p *BaseAdapter) GetHeadings(rows []data.Row) []string {
+	return p.orderedHeadings(rows, p.columns.Names())
+}
+
+// orderedHeadings returns the columns picked by the 'select' hint or, failing
+// that, all of the columns in rows in the given order.  Any columns which
+// aren't in order go after the others, sorted by name.
+func (p *BaseAdapter) orderedHeadings(rows []data.Row, order []string) []string {
 	headings := []string{}
 
 	// Check the context
 	if p.Ctx != nil {
 		if selectValue := p.Ctx.Values().GetString("select"); selectValue != "" && selectValue != "*" {
-			headings = []string{}
 			for _, columnName := range strings.Split(selectValue, ",") {
 				headings = append(headings, strings.TrimSpace(columnName))
 			}
+			return headings
 		}
 	}
 
-	// Nothing in the context.
-	// We need to grab tem from the map.
-	// Sadly, keys are not ordered so we're going to get a pseudo-random order
-	if len(headings) == 0 {
-		for k := range rows[0] {
-			headings = append(headings, k)
+	// Nothing in the context, so it is the union of the keys across the rows
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		for k := range row {
+			if !seen[k] {
+				seen[k] = true
+				headings = append(headings, k)
+			}
 		}
 	}
-
-	return headings
+	return data.OrderColumns(headings, order)
 }
@@ -0,0 +1,273 @@
+package adapter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/jbirtley88/gremel/util"
+)
+
+const (
+	ArchiveZip = "zip"
+	ArchiveTar = "tar"
+)
+
+// IsArchive reports whether the data is a zip or tar archive, for
+// CreateTableFromArchive() - see detectArchive()
+func IsArchive(ext string, head []byte) bool {
+	return detectArchive(ext, head) != ""
+}
+
+// detectArchive recognises zip and tar archives from their first few bytes.
+//
+// Excel workbooks are zip files too, so anything with an extension which
+// has a parser (e.g. 'xlsx') is not treated as an archive.
+func detectArchive(ext string, head []byte) string {
+	if ext != "" && !data.IsParserError(data.GetParserRegistry().GetByExtension(ext, nil)) {
+		return ""
+	}
+	if SniffContentType(head) == MimeTypeZip {
+		return ArchiveZip
+	}
+	// The tar header has 'ustar' at offset 257
+	if len(head) >= 262 && bytes.Equal(head[257:262], []byte("ustar")) {
+		return ArchiveTar
+	}
+	return ""
+}
+
+// CreateTableFromArchive (re)creates tableName from the members of a zip or
+// tar archive (which may itself be compressed, e.g. a '.tar.gz').
+//
+// The 'archive.member' hint picks the members: a comma-separated list of
+// names or glob patterns, e.g. 'access.log' or 'logs/*.csv'.  Every file in
+// the archive is picked if there is no hint.  A single member is mounted just
+// as if it were a file by itself.  Several members are unioned into one table,
+// as for CreateTableFromFiles(), with the member name in the _source_file column.
+func CreateTableFromArchive(ctx data.GremelContext, database db.GremelDB, tableName string, datafile string) error {
+	members, closer, err := archiveMembers(datafile, ctx.Values().GetString("archive.member"))
+	if err != nil {
+		return fmt.Errorf("CreateTableFromArchive(%s): %w", datafile, err)
+	}
+	defer closer.Close()
+
+	if len(members) > 1 {
+		err = createTableFromSources(ctx, database, tableName, members)
+		if err != nil {
+			return fmt.Errorf("CreateTableFromArchive(%s): %w", datafile, err)
+		}
+		return nil
+	}
+
+	member, err := members[0].open()
+	if err != nil {
+		return fmt.Errorf("CreateTableFromArchive(%s): %s: %w", datafile, members[0].name, err)
+	}
+	defer member.Close()
+	input, decompressor, err := Decompressed(member)
+	if err != nil {
+		return fmt.Errorf("CreateTableFromArchive(%s): %s: %w", datafile, members[0].name, err)
+	}
+	defer decompressor.Close()
+	head, err := PeekHead(input)
+	if err != nil {
+		return fmt.Errorf("CreateTableFromArchive(%s): %s: %w", datafile, members[0].name, err)
+	}
+	_, ext, _, _ := util.SplitCompressedFilename(members[0].name)
+	parser, err := ResolveParser(ctx, "", ext, head)
+	if err != nil {
+		return fmt.Errorf("CreateTableFromArchive(%s): %s: %w", datafile, members[0].name, err)
+	}
+	err = CreateTableFromReader(ctx, database, tableName, input, parser)
+	if err != nil {
+		return fmt.Errorf("CreateTableFromArchive(%s): %s: %w", datafile, members[0].name, err)
+	}
+	return nil
+}
+
+// archiveMembers lists the files in the archive which match the selector.
+// The closer releases them once they have been read.
+func archiveMembers(datafile string, selector string) ([]inputSource, io.Closer, error) {
+	f, err := os.Open(datafile)
+	if err != nil {
+		return nil, nil, err
+	}
+	input, closer, err := Decompressed(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	head, err := PeekHead(input)
+	archive := detectArchive("", head)
+	closer.Close()
+	f.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var selected []inputSource
+	var archiveCloser io.Closer
+	switch archive {
+	case ArchiveZip:
+		selected, archiveCloser, err = zipMembers(datafile, selector)
+	case ArchiveTar:
+		selected, archiveCloser, err = tarMembers(datafile, selector)
+	default:
+		err = fmt.Errorf("not a zip or tar archive")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(selected) == 0 {
+		archiveCloser.Close()
+		if selector != "" {
+			return nil, nil, fmt.Errorf("no members match '%s'", selector)
+		}
+		return nil, nil, fmt.Errorf("the archive is empty")
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].name < selected[j].name
+	})
+	return selected, archiveCloser, nil
+}
+
+func zipMembers(datafile string, selector string) ([]inputSource, io.Closer, error) {
+	zipReader, err := zip.OpenReader(datafile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w (compressed zip files aren't supported)", err)
+	}
+	var members []inputSource
+	for _, file := range zipReader.File {
+		if file.FileInfo().IsDir() || isHiddenMember(file.Name) || !isSelectedMember(file.Name, selector) {
+			continue
+		}
+		members = append(members, inputSource{
+			name: file.Name,
+			open: file.Open,
+		})
+	}
+	return members, zipReader, nil
+}
+
+// A tar file can only be read from start to finish (and decompressed along
+// the way), so the members are copied out to a temporary directory in one
+// pass, rather than reading through the archive again for each of them.  The
+// closer removes the directory.
+func tarMembers(datafile string, selector string) ([]inputSource, io.Closer, error) {
+	dir, err := os.MkdirTemp("", "gremel-*.tar")
+	if err != nil {
+		return nil, nil, err
+	}
+	spool := tempDir(dir)
+	var members []inputSource
+	err = readTar(datafile, func(header *tar.Header, content io.Reader) (bool, error) {
+		if header.Typeflag != tar.TypeReg || isHiddenMember(header.Name) || !isSelectedMember(header.Name, selector) {
+			return false, nil
+		}
+		spooled := filepath.Join(dir, fmt.Sprintf("member-%d", len(members)))
+		if err := spoolTo(spooled, content); err != nil {
+			return true, fmt.Errorf("%s: %w", header.Name, err)
+		}
+		members = append(members, inputSource{
+			name: header.Name,
+			open: func() (io.ReadCloser, error) {
+				return os.Open(spooled)
+			},
+		})
+		return false, nil
+	})
+	if err != nil {
+		spool.Close()
+		return nil, nil, err
+	}
+	return members, spool, nil
+}
+
+func spoolTo(path string, content io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// tempDir is a temporary directory, which is removed when it is closed
+type tempDir string
+
+func (d tempDir) Close() error {
+	return os.RemoveAll(string(d))
+}
+
+// readTar calls fn for each entry in the (possibly compressed) tar file,
+// until fn says that it is done
+func readTar(datafile string, fn func(header *tar.Header, content io.Reader) (bool, error)) error {
+	f, err := os.Open(datafile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	input, closer, err := Decompressed(f)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	tarReader := tar.NewReader(input)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		done, err := fn(header, tarReader)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// e.g. '.DS_Store' and '__MACOSX/...'
+func isHiddenMember(name string) bool {
+	for _, element := range strings.Split(name, "/") {
+		if strings.HasPrefix(element, ".") || element == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+func isSelectedMember(name string, selector string) bool {
+	if selector == "" {
+		return true
+	}
+	for _, pattern := range strings.Split(selector, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == name || pattern == path.Base(name) {
+			return true
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, path.Base(name)); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -1,9 +1,12 @@
 package adapter
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
 	"io"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/jbirtley88/gremel/data"
 )
@@ -15,6 +18,17 @@ import (
 //   - loads the CSV
 //   - uses the first row as headings
 //   - parses the rest of the data as rows
+//
+// The following hints in the context change how the CSV is read:
+//
+//   - csv.delimiter:  the field delimiter, e.g. ';', '|' or 'tab' (default: detected from the first few lines)
+//   - csv.header:     false if there is no header row, in which case the columns are named col1..N (default: true)
+//   - csv.skip:       the number of lines to skip before the header (default: 0)
+//   - csv.comment:    lines starting with this character are ignored, e.g. '#' (default: none)
+//   - csv.lazyquotes: true to allow quotes in unquoted fields, and unescaped quotes in quoted fields (default: false)
+//
+// Rows don't need to have the same number of fields as the header.  Missing
+// fields are null, and extra fields are named after their position (e.g. col7).
 type GenericCSVParser struct {
 	BaseAdapter
 }
@@ -26,33 +40,208 @@ func NewGenericCSVParser(ctx data.GremelContext) data.Parser {
 	return p
 }
 
+// The delimiters which we try when there is no 'csv.delimiter' hint, in order of preference
+var candidateDelimiters = []rune{',', '\t', ';', '|'}
+
+// How many lines auto-detection looks at
+const delimiterDetectionLines = 20
+
 func (p *GenericCSVParser) Parse(input io.Reader) (*data.RowList, error) {
-	// TODO(john): check the context for any hints on how to parse the CSV.
-	// For now, we assume it's:
-	//   - Comma-separated
-	//   - First row is headings
-	if p.Ctx != nil {
+	it, err := p.ParseStream(input)
+	if err != nil {
+		return nil, err
 	}
+	defer it.Close()
 
-	// Step 1: Parse the CSV
-	r := csv.NewReader(input)
-	records, err := r.ReadAll()
+	rows, err := data.CollectRows(it)
 	if err != nil {
-		return nil, fmt.Errorf("Parse(%s): read error: %w", p.GetName(), err)
+		return nil, err
+	}
+	return data.NewRowList(rows, p.GetHeadings(rows), nil), nil
+}
+
+func (p *GenericCSVParser) ParseStream(input io.Reader) (data.RowIterator, error) {
+	// Step 1: Get the hints from the context
+	delimiter := ""
+	header := true
+	skip := int64(0)
+	comment := ""
+	lazyQuotes := false
+	if p.Ctx != nil {
+		values := p.Ctx.Values()
+		delimiter = values.GetString("csv.delimiter")
+		if values.GetValue("csv.header") != nil {
+			header = values.GetBool("csv.header")
+		}
+		skip = values.GetInt("csv.skip")
+		comment = values.GetString("csv.comment")
+		lazyQuotes = values.GetBool("csv.lazyquotes")
 	}
 
-	// Step 2: convert it to rows
-	var rows []data.Row
+	// Step 2: Skip any preamble
+	bufferedInput := bufio.NewReaderSize(input, SniffSize)
+	for i := int64(0); i < skip; i++ {
+		if _, err := bufferedInput.ReadString('\n'); err != nil {
+			if err == io.EOF {
+				return data.NewRowSliceIterator(nil), nil
+			}
+			return nil, fmt.Errorf("Parse(%s): read error: %w", p.GetName(), err)
+		}
+	}
+
+	r := csv.NewReader(bufferedInput)
+	r.ReuseRecord = true
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = lazyQuotes
+	if comment != "" {
+		commentRune, size := utf8.DecodeRuneInString(comment)
+		if size != len(comment) {
+			return nil, fmt.Errorf("Parse(%s): csv.comment must be a single character, not '%s'", p.GetName(), comment)
+		}
+		r.Comment = commentRune
+	}
+	if delimiter == "" {
+		head, err := PeekHead(bufferedInput)
+		if err != nil {
+			return nil, fmt.Errorf("Parse(%s): read error: %w", p.GetName(), err)
+		}
+		r.Comma = DetectDelimiter(head, r.Comment)
+	} else {
+		comma, err := parseDelimiter(delimiter)
+		if err != nil {
+			return nil, fmt.Errorf("Parse(%s): %w", p.GetName(), err)
+		}
+		r.Comma = comma
+	}
+
+	// Step 3: The first record is the headings (unless we've been told otherwise)
 	var headings []string
-	for _, value := range records[0] {
-		headings = append(headings, fmt.Sprint(value))
+	var firstRecord []string
+	record, err := r.Read()
+	if err == io.EOF {
+		return data.NewRowSliceIterator(nil), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Parse(%s): read error: %w", p.GetName(), err)
 	}
-	for _, record := range records[1:] {
-		row := make(map[string]any)
+	if header {
 		for i, value := range record {
-			row[headings[i]] = data.InferValue(value)
+			heading := strings.TrimSpace(value)
+			if heading == "" {
+				heading = fmt.Sprintf("col%d", i+1)
+			}
+			headings = append(headings, heading)
 		}
-		rows = append(rows, row)
+		p.columns.Add(headings...)
+	} else {
+		firstRecord = append(firstRecord, record...)
 	}
-	return data.NewRowList(rows, p.GetHeadings(rows), nil), nil
+
+	// Step 4: convert each subsequent record to a row as it is read
+	toRow := func(record []string) data.Row {
+		row := make(data.Row)
+		for i, value := range record {
+			if i < len(headings) {
+				row[headings[i]] = data.InferValue(value)
+			} else {
+				column := fmt.Sprintf("col%d", i+1)
+				p.columns.Add(column)
+				row[column] = data.InferValue(value)
+			}
+		}
+		return row
+	}
+	next := func() (data.Row, error) {
+		if firstRecord != nil {
+			row := toRow(firstRecord)
+			firstRecord = nil
+			return row, nil
+		}
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Parse(%s): read error: %w", p.GetName(), err)
+		}
+		return toRow(record), nil
+	}
+	return data.NewRowFuncIterator(next, nil), nil
+}
+
+// parseDelimiter understands the names of the delimiters which are awkward
+// to type (or to put in a URL), as well as the delimiters themselves
+func parseDelimiter(delimiter string) (rune, error) {
+	switch strings.ToLower(delimiter) {
+	case "comma":
+		return ',', nil
+	case "tab", "\\t":
+		return '\t', nil
+	case "semicolon":
+		return ';', nil
+	case "pipe":
+		return '|', nil
+	case "space":
+		return ' ', nil
+	}
+	comma, size := utf8.DecodeRuneInString(delimiter)
+	if size != len(delimiter) || comma == '"' || comma == '\r' || comma == '\n' || comma == utf8.RuneError {
+		return 0, fmt.Errorf("invalid csv.delimiter '%s'", delimiter)
+	}
+	return comma, nil
+}
+
+// DetectDelimiter picks the delimiter which appears the same (non-zero)
+// number of times on each of the first few lines, preferring the one which
+// appears most often.  It falls back to a comma.
+func DetectDelimiter(head []byte, comment rune) rune {
+	var lines []string
+	for _, line := range completeLines(head) {
+		if comment != 0 && strings.HasPrefix(line, string(comment)) {
+			continue
+		}
+		lines = append(lines, line)
+		if len(lines) == delimiterDetectionLines {
+			break
+		}
+	}
+	if len(lines) == 0 {
+		return ','
+	}
+
+	best := ','
+	bestCount := 0
+	for _, candidate := range candidateDelimiters {
+		count := countUnquoted(lines[0], candidate)
+		if count == 0 || count <= bestCount {
+			continue
+		}
+		consistent := true
+		for _, line := range lines[1:] {
+			if countUnquoted(line, candidate) != count {
+				consistent = false
+				break
+			}
+		}
+		if consistent {
+			best = candidate
+			bestCount = count
+		}
+	}
+	return best
+}
+
+// countUnquoted counts the occurrences of delimiter which aren't inside double quotes
+func countUnquoted(line string, delimiter rune) int {
+	count := 0
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == delimiter && !quoted:
+			count++
+		}
+	}
+	return count
 }
@@ -0,0 +1,92 @@
+package adapter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseCSV(t *testing.T, input string, hints map[string]any) []data.Row {
+	t.Helper()
+	ctx := data.NewGremelContext(context.TODO())
+	for k, v := range hints {
+		ctx.Values().SetValue(k, v)
+	}
+	rowList, err := NewGenericCSVParser(ctx).Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	require.NotNil(t, rowList)
+	return rowList.Rows
+}
+
+func TestCSVGenericDelimiters(t *testing.T) {
+	expected := []data.Row{
+		{"id": int64(1), "name": "alice, esq.", "score": 1.5},
+		{"id": int64(2), "name": "bob", "score": int64(3)},
+	}
+	tests := []struct {
+		name  string
+		input string
+		hints map[string]any
+	}{
+		{"comma", "id,name,score\n1,\"alice, esq.\",1.5\n2,bob,3\n", nil},
+		{"tab detected", "id\tname\tscore\n1\talice, esq.\t1.5\n2\tbob\t3\n", nil},
+		{"semicolon detected", "id;name;score\n1;alice, esq.;1.5\n2;bob;3\n", nil},
+		{"pipe detected", "id|name|score\n1|alice, esq.|1.5\n2|bob|3\n", nil},
+		{"pipe by name", "id|name|score\n1|alice, esq.|1.5\n2|bob|3\n", map[string]any{"csv.delimiter": "pipe"}},
+		{"tab by name", "id\tname\tscore\n1\talice, esq.\t1.5\n2\tbob\t3\n", map[string]any{"csv.delimiter": "tab"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, expected, parseCSV(t, tt.input, tt.hints))
+		})
+	}
+}
+
+func TestCSVGenericNoHeader(t *testing.T) {
+	rows := parseCSV(t, "1,alice\n2,bob\n", map[string]any{"csv.header": "false"})
+	assert.Equal(t, []data.Row{
+		{"col1": int64(1), "col2": "alice"},
+		{"col1": int64(2), "col2": "bob"},
+	}, rows)
+}
+
+func TestCSVGenericSkipAndComments(t *testing.T) {
+	input := "Exported by SomeTool v1.2\n\nid,name\n# this is a comment\n1,alice\n#2,bob\n3,carol\n"
+	rows := parseCSV(t, input, map[string]any{"csv.skip": "2", "csv.comment": "#"})
+	assert.Equal(t, []data.Row{
+		{"id": int64(1), "name": "alice"},
+		{"id": int64(3), "name": "carol"},
+	}, rows)
+}
+
+func TestCSVGenericVariableFieldsAndLazyQuotes(t *testing.T) {
+	input := "id,name\n1,alice,extra\n2\n3,bo\"b\n"
+	rows := parseCSV(t, input, map[string]any{"csv.lazyquotes": true})
+	assert.Equal(t, []data.Row{
+		{"id": int64(1), "name": "alice", "col3": "extra"},
+		{"id": int64(2)},
+		{"id": int64(3), "name": "bo\"b"},
+	}, rows)
+
+	// Without lazy quotes, the stray quote is an error
+	_, err := NewGenericCSVParser(data.NewGremelContext(context.TODO())).Parse(strings.NewReader(input))
+	assert.Error(t, err)
+}
+
+func TestCSVGenericEmpty(t *testing.T) {
+	assert.Empty(t, parseCSV(t, "", nil))
+	assert.Empty(t, parseCSV(t, "id,name\n", nil))
+	assert.Empty(t, parseCSV(t, "just one line\n", map[string]any{"csv.skip": 5}))
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	assert.Equal(t, ',', DetectDelimiter([]byte("a,b\n1,2\n"), 0))
+	assert.Equal(t, ';', DetectDelimiter([]byte("a;b;c\n\"1;x\";2;3\n4;5;6\n"), 0))
+	assert.Equal(t, '\t', DetectDelimiter([]byte("# a;b\na\tb\n1\t2\n"), '#'))
+	// Nothing consistent, so fall back to a comma
+	assert.Equal(t, ',', DetectDelimiter([]byte("hello world\n"), 0))
+}
@@ -1,82 +1,257 @@
 package adapter
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/jbirtley88/gremel/data"
 	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/jbirtley88/gremel/util"
+	"github.com/spf13/viper"
 )
 
-func CreateTableFromFile(ctx data.GremelContext, database db.GremelDB, tableName string, fileType string, datafile string) error {
-	// Step 1: Parse the underlying file into rows
-	var parser data.Parser
-	switch fileType {
-	case "json":
-		// Parse JSON file
-		parser = NewGenericJsonParser(ctx)
-
-	case "csv":
-		// Parse CSV file
-		parser = NewGenericCSVParser(ctx)
-
-	case "log":
-		// Parse log file
-		parser = NewGenericLogParser(ctx)
+// DefaultSampleSize is the number of rows used to infer the table schema
+// before the remainder of the input is streamed into the table
+const DefaultSampleSize = 1000
 
-	case "xlsx", "xls":
-		// Parse Excel file
-		parser = NewGenericExcelParser(ctx)
-
-	default:
-		return fmt.Errorf("CreateDB(%s): unsupported file type: %s", datafile, fileType)
-	}
-
-	// Step 2: Parse the data into rows
+// CreateTableFromFile (re)creates tableName from the contents of datafile.
+//
+// Compressed files (e.g. 'access.log.gz') are decompressed on the fly, and
+// zip or tar archives are handed to CreateTableFromArchive().
+//
+// The parser is resolved through the ParserRegistry - see ResolveParser() -
+// using the 'format' in the context, then fileType (the file extension, or
+// the extension inside the compression), then the content type sniffed from
+// the start of the (decompressed) file.
+func CreateTableFromFile(ctx data.GremelContext, database db.GremelDB, tableName string, fileType string, datafile string) error {
 	f, err := os.Open(datafile)
 	if err != nil {
 		return fmt.Errorf("CreateDB(%s): failed to open file: %w", datafile, err)
 	}
 	defer f.Close()
 
-	err = CreateTableFromReader(ctx, database, tableName, f, parser)
+	// Step 1: Work out which parser to use
+	input, closer, err := Decompressed(f)
+	if err != nil {
+		return fmt.Errorf("CreateDB(%s): failed to read file: %w", datafile, err)
+	}
+	defer closer.Close()
+	head, err := PeekHead(input)
+	if err != nil {
+		return fmt.Errorf("CreateDB(%s): failed to read file: %w", datafile, err)
+	}
+	// e.g. 'gz' for 'access.log.gz' means 'log'
+	if _, innerExt, compression, _ := util.SplitCompressedFilename(datafile); compression != "" && strings.EqualFold(fileType, compression) {
+		fileType = innerExt
+	}
+	if detectArchive(fileType, head) != "" {
+		return CreateTableFromArchive(ctx, database, tableName, datafile)
+	}
+	parser, err := ResolveParser(ctx, "", fileType, head)
+	if err != nil {
+		return fmt.Errorf("CreateDB(%s): %w", datafile, err)
+	}
+
+	// Step 2: Parse the data into rows
+	err = CreateTableFromReader(ctx, database, tableName, input, parser)
 	if err != nil {
 		return fmt.Errorf("CreateDB(%s): failed to create DB from reader: %w", datafile, err)
 	}
 	return nil
 }
 
+// ErrNoRows is returned when there is nothing to put in a table
+var ErrNoRows = errors.New("no data rows found")
+
+// CreateTableFromReader (re)creates tableName from the rows which the parser produces.
+//
+// The schema is inferred from the first 'mount.sample' rows, after which the
+// sampled rows and the remainder of the input are inserted in batches of
+// 'mount.batchsize' rows per transaction.
+//
+// If the parser splits out child tables, these are created afterwards.  If the
+// parser produces several tables (e.g. one per Excel worksheet), these are
+// created instead of tableName.  Either way, the names of the extra tables are
+// left in the context as '<tableName>.tables'.
 func CreateTableFromReader(ctx data.GremelContext, database db.GremelDB, tableName string, input io.Reader, parser data.Parser) error {
-	inputBytes, err := io.ReadAll(input)
-	if err != nil {
-		return fmt.Errorf("CreateDBFromReader(%s): failed to read input: %w", tableName, err)
+	if multiTableParser, isMultiTableParser := parser.(data.MultiTableParser); isMultiTableParser && multiTableParser.MultiTable() {
+		return createTablesFromReader(ctx, database, tableName, input, multiTableParser)
 	}
-	rows, err := parser.Parse(bytes.NewBuffer(inputBytes))
+
+	rows, err := data.ParseStream(parser, input)
 	if err != nil {
 		return fmt.Errorf("CreateDBFromReader(%s): failed to parse data: %w", tableName, err)
 	}
-	ctx.Values().SetValue(tableName+".headings", rows.Headings)
+	defer rows.Close()
 
-	// Create the database schema based on the headings in the first row
-	if len(rows.Rows) == 0 {
-		return fmt.Errorf("CreateDBFromReader(%s): no data rows found", tableName)
+	err = loadTable(ctx, database, tableName, rows, parser, nil)
+	if err != nil {
+		return fmt.Errorf("CreateDBFromReader(%s): %w", tableName, err)
+	}
+
+	// Any child tables which were split out of the rows (e.g. nested JSON arrays)
+	var childTableNames []string
+	if childTableParser, isChildTableParser := parser.(data.ChildTableParser); isChildTableParser {
+		batchSize := getIntSetting(ctx, "mount.batchsize", data.CONF_MOUNT_BATCHSIZE, db.DefaultBatchSize)
+		for _, childTable := range childTableParser.ChildTables() {
+			if len(childTable.Rows) == 0 {
+				continue
+			}
+			childTableName := tableName + ChildTableSeparator + childTable.Name
+			err = replaceTable(database, childTableName, childTable.Columns, childTable.Rows, data.NewRowSliceIterator(childTable.Rows), batchSize)
+			if err != nil {
+				return fmt.Errorf("CreateDBFromReader(%s): %s: %w", tableName, childTableName, err)
+			}
+			childTableNames = append(childTableNames, childTableName)
+		}
 	}
+	ctx.Values().SetValue(tableName+".tables", childTableNames)
+	return nil
+}
 
-	// TODO(john): Make sure that we don't already have a table of this name
-	err = database.CreateSchema(tableName, rows.Rows)
+// createTablesFromReader creates a '<tableName>_<name>' table for each of the
+// tables which the parser produces.  Tables with no rows are skipped.
+func createTablesFromReader(ctx data.GremelContext, database db.GremelDB, tableName string, input io.Reader, parser data.MultiTableParser) error {
+	tables, err := parser.ParseTables(input)
 	if err != nil {
-		return fmt.Errorf("CreateDBFromReader(%s): failed to create schema: %w", tableName, err)
+		return fmt.Errorf("CreateDBFromReader(%s): failed to parse data: %w", tableName, err)
 	}
+	defer func() {
+		for _, table := range tables {
+			table.Rows.Close()
+		}
+	}()
 
-	// Insert all the rows
-	for rowNum, rowMap := range rows.Rows {
-		// Ensure all rows have the same columns as the first row
-		err = database.InsertRows(tableName, data.NewSingleRow(rowMap))
+	var tableNames []string
+	for _, table := range tables {
+		subTableName := tableName + "_" + SanitiseTableName(table.Name)
+		err = loadTable(ctx, database, subTableName, table.Rows, parser, table.Columns)
+		if errors.Is(err, ErrNoRows) {
+			continue
+		}
 		if err != nil {
-			return fmt.Errorf("CreateDBFromReader(%s): failed to insert row %d: %w", tableName, rowNum, err)
+			return fmt.Errorf("CreateDBFromReader(%s): %s: %w", tableName, table.Name, err)
 		}
+		tableNames = append(tableNames, subTableName)
+	}
+	if len(tableNames) == 0 {
+		return fmt.Errorf("CreateDBFromReader(%s): %w", tableName, ErrNoRows)
+	}
+	ctx.Values().SetValue(tableName+".tables", tableNames)
+	return nil
+}
+
+// loadTable (re)creates tableName from a sample of the rows, then streams all of the rows into it.
+// The columns are in the order of the parser's headings, unless columns gives the order for this
+// table in particular (e.g. one of several worksheets).
+func loadTable(ctx data.GremelContext, database db.GremelDB, tableName string, rows data.RowIterator, parser data.Parser, columns *data.ColumnOrder) error {
+	rows = withContext(ctx, rows)
+
+	// Step 1: Pull a sample of rows from which to derive the schema
+	sample, err := data.ReadRows(rows, getIntSetting(ctx, "mount.sample", data.CONF_MOUNT_SAMPLE, DefaultSampleSize))
+	if err != nil {
+		return fmt.Errorf("failed to parse data: %w", err)
+	}
+	if len(sample) == 0 {
+		return ErrNoRows
+	}
+	headings := parser.GetHeadings(sample)
+	if columns != nil {
+		headings = data.OrderColumns(headings, columns.Names())
+	}
+	ctx.Values().SetValue(tableName+".headings", headings)
+
+	// Step 2: Stream the sample, followed by everything else, into the table
+	allRows := data.NewMultiRowIterator(data.NewRowSliceIterator(sample), rows)
+	return replaceTable(database, tableName, headings, sample, allRows, getIntSetting(ctx, "mount.batchsize", data.CONF_MOUNT_BATCHSIZE, db.DefaultBatchSize))
+}
+
+// Tables are loaded under this prefix, and only renamed once they are complete
+const stagingTablePrefix = "_gremel_loading_"
+
+// The loads of each table, which take turns, since they share a staging table
+var (
+	loadingMutex  sync.Mutex
+	loadingTables = make(map[string]*sync.Mutex)
+)
+
+// lockTable waits for any other load of tableName to finish, returning the
+// function which lets the next one go ahead
+func lockTable(tableName string) func() {
+	loadingMutex.Lock()
+	tableMutex, exists := loadingTables[tableName]
+	if !exists {
+		tableMutex = &sync.Mutex{}
+		loadingTables[tableName] = tableMutex
+	}
+	loadingMutex.Unlock()
+	tableMutex.Lock()
+	return tableMutex.Unlock
+}
+
+// replaceTable creates a staging table from the sample, streams all of the
+// rows into it and only then swaps it in for tableName.  A load which fails
+// part way through (e.g. a bad line in a CSV file) leaves any existing
+// tableName as it was, rather than half-loaded.
+//
+// Loads of the same table (e.g. a refresh and a remount) take turns.
+func replaceTable(database db.GremelDB, tableName string, headings []string, sample []data.Row, rows data.RowIterator, batchSize int) error {
+	unlock := lockTable(tableName)
+	defer unlock()
+	stagingName := stagingTablePrefix + tableName
+	err := database.CreateSchema(stagingName, headings, sample)
+	if err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+	_, err = database.InsertRowStream(stagingName, rows, batchSize)
+	if err != nil {
+		database.DropSchema(stagingName)
+		return fmt.Errorf("failed to insert rows: %w", err)
+	}
+	err = database.RenameSchema(stagingName, tableName)
+	if err != nil {
+		database.DropSchema(stagingName)
+		return err
 	}
 	return nil
 }
+
+// withContext stops the load if the context is cancelled, e.g. by Ctrl-C
+// during a refresh which a query has set off
+func withContext(ctx data.GremelContext, rows data.RowIterator) data.RowIterator {
+	if ctx == nil || ctx.Context() == nil {
+		return rows
+	}
+	return data.NewContextRowIterator(ctx.Context(), rows)
+}
+
+// SanitiseTableName turns a name such as 'Q3 Sales' into something which can
+// be used as (part of) a table name without quoting, e.g. 'Q3_Sales'
+func SanitiseTableName(name string) string {
+	sanitised := []rune(strings.TrimSpace(name))
+	for i, r := range sanitised {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			sanitised[i] = '_'
+		}
+	}
+	return string(sanitised)
+}
+
+// getIntSetting looks for a per-mount setting in the context, falling back to
+// the application config and then to the default
+func getIntSetting(ctx data.GremelContext, name string, configName string, defaultValue int) int {
+	if ctx != nil {
+		if value := ctx.Values().GetInt(name); value > 0 {
+			return int(value)
+		}
+	}
+	if value := viper.GetInt(configName); value > 0 {
+		return value
+	}
+	return defaultValue
+}
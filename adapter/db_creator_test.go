@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/jbirtley88/gremel/data"
@@ -160,3 +161,124 @@ ORDER BY i.datacenter`
 	assert.Equal(t, int64(0), rows[3]["latency>2000"])
 	assert.Equal(t, []string{"datacenter", "latency>2000"}, columns)
 }
+
+func TestCreateTableFromReaderStreamsBeyondSample(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	ctx.Values().SetValue("mount.sample", 10)
+	ctx.Values().SetValue("mount.batchsize", 100)
+	database := db.GetGremelDB()
+
+	f, err := os.Open("../test_resources/accounts.csv")
+	require.Nil(t, err)
+	defer f.Close()
+
+	err = CreateTableFromReader(ctx, database, "sampled_accounts", f, NewGenericCSVParser(ctx))
+	require.Nil(t, err)
+
+	rows, _, err := database.Query("SELECT COUNT(*) AS count FROM sampled_accounts")
+	require.Nil(t, err)
+	assert.Equal(t, int64(1000), rows[0]["count"])
+}
+
+func TestCreateTableFromReaderFailsWithoutHalfLoadingTheTable(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	ctx.Values().SetValue("mount.sample", 5)
+	ctx.Values().SetValue("mount.batchsize", 10)
+	database := db.GetGremelDB()
+	var good, bad strings.Builder
+	good.WriteString("id,name\n")
+	bad.WriteString("id,name\n")
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&good, "%d,name %d\n", i, i)
+		if i == 31 {
+			bad.WriteString("31,\"bad \"quote\n")
+		} else {
+			fmt.Fprintf(&bad, "%d,name %d\n", i, i)
+		}
+	}
+
+	// Nothing at all, rather than the first 30 rows
+	err := CreateTableFromReader(ctx, database, "half_loaded", strings.NewReader(bad.String()), NewGenericCSVParser(ctx))
+	require.Error(t, err)
+	tables, err := database.GetTables()
+	require.NoError(t, err)
+	assert.NotContains(t, tables, "half_loaded")
+	assert.NotContains(t, tables, stagingTablePrefix+"half_loaded")
+
+	// Reloading over a good table leaves it as it was
+	require.NoError(t, CreateTableFromReader(ctx, database, "half_loaded", strings.NewReader(good.String()), NewGenericCSVParser(ctx)))
+	err = CreateTableFromReader(ctx, database, "half_loaded", strings.NewReader(bad.String()), NewGenericCSVParser(ctx))
+	require.Error(t, err)
+	rows, _, err := database.Query("SELECT COUNT(*) AS count FROM half_loaded")
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), rows[0]["count"])
+	_, err = database.GetSchema(stagingTablePrefix + "half_loaded")
+	assert.Error(t, err)
+}
+
+func TestCreateTableFromReaderConcurrently(t *testing.T) {
+	database := db.GetGremelDB()
+	var wg sync.WaitGroup
+	errs := make([]error, 32)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := data.NewGremelContext(context.Background())
+			ctx.Values().SetValue("mount.sample", 2)
+			ctx.Values().SetValue("mount.batchsize", 5)
+			var input strings.Builder
+			input.WriteString("id,load\n")
+			for id := 1; id <= 50; id++ {
+				fmt.Fprintf(&input, "%d,%d\n", id, i)
+			}
+			errs[i] = CreateTableFromReader(ctx, database, "loaded_twice", strings.NewReader(input.String()), NewGenericCSVParser(ctx))
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	// All of one load, rather than a mixture
+	rows, _, err := database.Query("SELECT COUNT(*) AS count, COUNT(DISTINCT load) AS loads FROM loaded_twice")
+	require.NoError(t, err)
+	assert.Equal(t, data.Row{"count": int64(50), "loads": int64(1)}, rows[0])
+}
+
+func TestCreateTableFromReaderNoRows(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	database := db.GetGremelDB()
+
+	err := CreateTableFromReader(ctx, database, "no_rows", strings.NewReader("[]"), NewGenericJsonParser(ctx))
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no data rows found")
+}
+
+func TestCreateTableFromReaderKeepsColumnOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		parser func(data.GremelContext) data.Parser
+	}{
+		{"csv header", "zeta,alpha,mid\n1,2,3\n", NewGenericCSVParser},
+		{"json keys", `[{"zeta": 1, "alpha": 2}, {"alpha": 3, "mid": 4}]`, NewGenericJsonParser},
+		{"ndjson keys", "{\"zeta\": 1, \"alpha\": 2}\n{\"mid\": 4, \"alpha\": 3}\n", NewGenericNDJsonParser},
+		{"whitespace header", "zeta alpha mid\n1 2 3\n", NewGenericWhitespaceParser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := data.NewGremelContext(context.Background())
+			database := db.GetGremelDB()
+			err := CreateTableFromReader(ctx, database, "ordered", strings.NewReader(tt.input), tt.parser(ctx))
+			require.NoError(t, err)
+
+			schema, err := database.GetSchema("ordered")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"zeta", "alpha", "mid"}, schema.Names())
+			_, columns, err := database.Query("SELECT * FROM ordered")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"zeta", "alpha", "mid"}, columns)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	CompressionGzip  = "gzip"
+	CompressionBzip2 = "bzip2"
+	CompressionZstd  = "zstd"
+	CompressionXz    = "xz"
+)
+
+// The magic bytes at the start of each kind of compressed data
+var compressionMagic = []struct {
+	compression string
+	magic       []byte
+}{
+	{CompressionGzip, []byte{0x1f, 0x8b}},
+	{CompressionBzip2, []byte("BZh")},
+	{CompressionZstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{CompressionXz, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+}
+
+// Compressed data can be wrapped more than once (e.g. a gzipped tar file is
+// wrapped in gzip, then tar), but there is no good reason for it to be
+// wrapped more than this
+const maxCompressionLayers = 3
+
+// DetectCompression recognises compressed data from its first few bytes,
+// returning e.g. CompressionGzip, or "" if the data is not compressed
+func DetectCompression(head []byte) string {
+	for _, candidate := range compressionMagic {
+		if bytes.HasPrefix(head, candidate.magic) {
+			return candidate.compression
+		}
+	}
+	return ""
+}
+
+// Decompressed peels any compression off the input, returning the
+// decompressed data (which can be peeked at with PeekHead) along with a
+// closer which has to be called once the data has been read.
+//
+// Parsers are then chosen from what is inside, e.g. 'log' for 'access.log.gz'
+// (see util.SplitCompressedFilename).
+func Decompressed(input io.Reader) (*bufio.Reader, io.Closer, error) {
+	reader := bufio.NewReaderSize(input, SniffSize)
+	closers := multiCloser{}
+	for layer := 0; ; layer++ {
+		head, err := PeekHead(reader)
+		if err != nil {
+			closers.Close()
+			return nil, nil, err
+		}
+		compression := DetectCompression(head)
+		if compression == "" {
+			return reader, closers, nil
+		}
+		if layer == maxCompressionLayers {
+			closers.Close()
+			return nil, nil, fmt.Errorf("Decompressed(): compressed more than %d times", maxCompressionLayers)
+		}
+		decompressed, err := decompress(reader, compression)
+		if err != nil {
+			closers.Close()
+			return nil, nil, fmt.Errorf("Decompressed(): %s: %w", compression, err)
+		}
+		closers = append(multiCloser{decompressed}, closers...)
+		reader = bufio.NewReaderSize(decompressed, SniffSize)
+	}
+}
+
+func decompress(input io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewReader(input)
+	case CompressionBzip2:
+		return io.NopCloser(bzip2.NewReader(input)), nil
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(input)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case CompressionXz:
+		reader, err := xz.NewReader(input)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(reader), nil
+	}
+	return nil, fmt.Errorf("unsupported compression")
+}
+
+// multiCloser closes everything, in order
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var firstErr error
+	for _, closer := range m {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
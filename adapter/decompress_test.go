@@ -0,0 +1,164 @@
+package adapter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+// compressWith compresses data with one of the writers from the libraries
+// which decompress it
+func compressWith(t *testing.T, compression string, plain []byte) []byte {
+	var b bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch compression {
+	case CompressionGzip:
+		w = gzip.NewWriter(&b)
+	case CompressionZstd:
+		w, err = zstd.NewWriter(&b)
+	case CompressionXz:
+		w, err = xz.NewWriter(&b)
+	}
+	require.NoError(t, err)
+	_, err = w.Write(plain)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return b.Bytes()
+}
+
+func TestDecompressed(t *testing.T) {
+	plain, err := os.ReadFile("../test_resources/clf.log")
+	require.NoError(t, err)
+
+	for _, compression := range []string{CompressionGzip, CompressionZstd, CompressionXz} {
+		t.Run(compression, func(t *testing.T) {
+			compressed := compressWith(t, compression, plain)
+			assert.Equal(t, compression, DetectCompression(compressed))
+
+			input, closer, err := Decompressed(bytes.NewReader(compressed))
+			require.NoError(t, err)
+			defer closer.Close()
+			decompressed, err := io.ReadAll(input)
+			require.NoError(t, err)
+			assert.Equal(t, plain, decompressed)
+
+			// Cut short
+			input, closer, err = Decompressed(bytes.NewReader(compressed[:len(compressed)/2]))
+			if err == nil {
+				defer closer.Close()
+				_, err = io.ReadAll(input)
+			}
+			assert.Error(t, err)
+		})
+	}
+
+	// Not compressed at all
+	input, closer, err := Decompressed(strings.NewReader("id,name\n1,Alice\n"))
+	require.NoError(t, err)
+	defer closer.Close()
+	decompressed, err := io.ReadAll(input)
+	require.NoError(t, err)
+	assert.Equal(t, "id,name\n1,Alice\n", string(decompressed))
+}
+
+func TestCreateTableFromCompressedFile(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	database := db.GetGremelDB()
+
+	// The extension is 'gz', but the parser is picked from what's inside
+	require.NoError(t, CreateTableFromFile(ctx, database, "rotated", "gz", "../test_resources/access.log.1.gz"))
+	assert.Equal(t, int64(20), countTableRows(t, database, "rotated"))
+
+	require.NoError(t, CreateTableFromFile(ctx, database, "people_bz2", "csv", "../test_resources/people_10.csv.bz2"))
+	assert.Equal(t, int64(10), countTableRows(t, database, "people_bz2"))
+
+	// Compressed files in a union are decompressed, too
+	require.NoError(t, CreateTableFromFiles(ctx, database, "all_logs", []string{"../test_resources/clf.log", "../test_resources/access.log.1.gz"}))
+	rows, _, err := database.Query("SELECT COUNT(*) AS count FROM all_logs WHERE _source_file = '../test_resources/access.log.1.gz'")
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), rows[0]["count"])
+}
+
+func TestCreateTableFromArchive(t *testing.T) {
+	for _, archive := range []string{"../test_resources/logs.zip", "../test_resources/logs.tar.gz"} {
+		t.Run(filepath.Base(archive), func(t *testing.T) {
+			ctx := data.NewGremelContext(context.Background())
+			database := db.GetGremelDB()
+
+			// Every member (bar the __MACOSX junk), unioned
+			require.NoError(t, CreateTableFromFile(ctx, database, "archived", "", archive))
+			rows, _, err := database.Query("SELECT _source_file, COUNT(*) AS count FROM archived GROUP BY _source_file ORDER BY _source_file")
+			require.NoError(t, err)
+			require.Len(t, rows, 3)
+			assert.Equal(t, "logs/access.log", rows[0][SourceFileColumn])
+			assert.Equal(t, int64(50), rows[0]["count"])
+			assert.Equal(t, "logs/access.log.1.gz", rows[1][SourceFileColumn])
+			assert.Equal(t, int64(20), rows[1]["count"])
+			assert.Equal(t, "logs/people.csv", rows[2][SourceFileColumn])
+			assert.Equal(t, int64(10), rows[2]["count"])
+
+			// Just the logs
+			ctx.Values().SetValue("archive.member", "access.log*")
+			require.NoError(t, CreateTableFromFile(ctx, database, "archived_logs", "", archive))
+			assert.Equal(t, int64(70), countTableRows(t, database, "archived_logs"))
+
+			// A single member is just like mounting the file
+			ctx.Values().SetValue("archive.member", "logs/people.csv")
+			require.NoError(t, CreateTableFromFile(ctx, database, "archived_people", "", archive))
+			schema, err := database.GetSchema("archived_people")
+			require.NoError(t, err)
+			assert.NotContains(t, schema.Names(), SourceFileColumn)
+			assert.Equal(t, int64(10), countTableRows(t, database, "archived_people"))
+
+			ctx.Values().SetValue("archive.member", "missing.csv")
+			err = CreateTableFromFile(ctx, database, "archived_missing", "", archive)
+			assert.ErrorContains(t, err, "no members match 'missing.csv'")
+		})
+	}
+}
+
+func TestTarMembersAreReadInOnePass(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "logs.tar.gz")
+	original, err := os.ReadFile("../test_resources/logs.tar.gz")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(archive, original, 0644))
+	members, closer, err := tarMembers(archive, "access.log*")
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+
+	// The archive has gone by the time the members are read
+	require.NoError(t, os.Remove(archive))
+	for _, member := range members {
+		r, err := member.open()
+		require.NoError(t, err, member.name)
+		content, err := io.ReadAll(r)
+		r.Close()
+		require.NoError(t, err, member.name)
+		assert.NotEmpty(t, content, member.name)
+	}
+
+	// Closing cleans up the copies
+	spooled := string(closer.(tempDir))
+	require.NoError(t, closer.Close())
+	_, err = os.Stat(spooled)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestExcelIsNotAnArchive(t *testing.T) {
+	head, err := os.ReadFile("../test_resources/accounts_multiple_sheets.xlsx")
+	require.NoError(t, err)
+	assert.False(t, IsArchive("xlsx", head[:SniffSize]))
+}
@@ -3,6 +3,7 @@ package adapter
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/jbirtley88/gremel/data"
 	"github.com/xuri/excelize/v2"
@@ -15,6 +16,13 @@ import (
 //   - loads the Excel
 //   - uses the first row as headings
 //   - parses the rest of the data as rows
+//
+// By default, it reads the first worksheet (or the one named by
+// 'excel.sheetname').  With 'excel.sheets=all' it reads every worksheet into a
+// table of its own - see MultiTable().
+//
+// See excelSheetReader for the hints which pick out the headings and the data
+// within a worksheet.
 type GenericExcelParser struct {
 	BaseAdapter
 }
@@ -27,11 +35,24 @@ func NewGenericExcelParser(ctx data.GremelContext) data.Parser {
 }
 
 func (p *GenericExcelParser) Parse(input io.Reader) (*data.RowList, error) {
-	// TODO(john): check the context for any hints on how to parse the Excel.
-	// For now, we assume it's:
-	//   - Comma-separated
-	//   - First row is headings
+	it, err := p.ParseStream(input)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	rows, err := data.CollectRows(it)
+	if err != nil {
+		return nil, err
+	}
+	return data.NewRowList(rows, p.GetHeadings(rows), nil), nil
+}
+
+func (p *GenericExcelParser) ParseStream(input io.Reader) (data.RowIterator, error) {
 	spreadsheet, err := excelize.OpenReader(input)
+	if err != nil {
+		return nil, fmt.Errorf("Parse(%s): open error: %w", p.GetName(), err)
+	}
 	sheetName := spreadsheet.GetSheetList()[0]
 	if p.Ctx != nil {
 		if sn, ok := p.Ctx.Values().GetValue("excel.sheetname").(string); ok {
@@ -39,29 +60,77 @@ func (p *GenericExcelParser) Parse(input io.Reader) (*data.RowList, error) {
 		}
 	}
 
+	rows, err := p.sheetRows(spreadsheet, sheetName, &p.columns, spreadsheet.Close)
 	if err != nil {
-		return nil, fmt.Errorf("Parse(%s): open error: %w", p.GetName(), err)
+		spreadsheet.Close()
+		return nil, err
 	}
+	return rows, nil
+}
 
-	// TODO(john): deal with multiple worksheets in the Excel file
-	// For now, we assume there's only one worksheet called "Sheet1"
-	// Step 1: Parse the Excel
-	spreadsheetRows, err := spreadsheet.GetRows(sheetName)
+// MultiTable is true if 'excel.sheets' asks for every worksheet ('all'), or a
+// comma-separated list of them, to be mounted as separate tables
+func (p *GenericExcelParser) MultiTable() bool {
+	return p.Ctx != nil && p.Ctx.Values().GetString("excel.sheets") != ""
+}
+
+// ParseTables returns the rows for each of the worksheets named by 'excel.sheets'
+func (p *GenericExcelParser) ParseTables(input io.Reader) ([]data.TableRows, error) {
+	spreadsheet, err := excelize.OpenReader(input)
 	if err != nil {
-		return nil, fmt.Errorf("Parse(%s): GetRows(): %w", p.GetName(), err)
+		return nil, fmt.Errorf("Parse(%s): open error: %w", p.GetName(), err)
 	}
 
-	// Step 2: convert it to rows
-	var rows []data.Row
-	var headings []string
-	headings = append(headings, spreadsheetRows[0][0:]...)
+	sheetNames := spreadsheet.GetSheetList()
+	if sheets := p.Ctx.Values().GetString("excel.sheets"); !strings.EqualFold(sheets, "all") {
+		sheetNames = nil
+		for _, sheetName := range strings.Split(sheets, ",") {
+			sheetName = strings.TrimSpace(sheetName)
+			if index, _ := spreadsheet.GetSheetIndex(sheetName); index < 0 {
+				spreadsheet.Close()
+				return nil, fmt.Errorf("Parse(%s): no such worksheet '%s'", p.GetName(), sheetName)
+			}
+			sheetNames = append(sheetNames, sheetName)
+		}
+	}
 
-	for _, ssRow := range spreadsheetRows[1:] {
-		row := make(map[string]any)
-		for i, value := range ssRow {
-			row[headings[i]] = data.InferValue(value)
+	// The spreadsheet is closed along with the last of the worksheets
+	open := len(sheetNames)
+	closer := func() error {
+		open--
+		if open == 0 {
+			return spreadsheet.Close()
 		}
-		rows = append(rows, row)
+		return nil
 	}
-	return data.NewRowList(rows, p.GetHeadings(rows), nil), nil
+
+	tables := make([]data.TableRows, 0, len(sheetNames))
+	for _, sheetName := range sheetNames {
+		columns := &data.ColumnOrder{}
+		rows, err := p.sheetRows(spreadsheet, sheetName, columns, closer)
+		if err != nil {
+			for _, table := range tables {
+				table.Rows.Close()
+			}
+			spreadsheet.Close()
+			return nil, err
+		}
+		tables = append(tables, data.TableRows{Name: sheetName, Columns: columns, Rows: rows})
+	}
+	return tables, nil
+}
+
+// sheetRows walks the worksheet one row at a time - see excelSheetReader.
+// The order of the columns is recorded in columns, and closer is called
+// (once) when the returned iterator is closed.
+func (p *GenericExcelParser) sheetRows(spreadsheet *excelize.File, sheetName string, columns *data.ColumnOrder, closer func() error) (data.RowIterator, error) {
+	reader, err := newExcelSheetReader(p.Ctx, spreadsheet, sheetName, columns)
+	if err != nil {
+		return nil, fmt.Errorf("Parse(%s): %s: %w", p.GetName(), sheetName, err)
+	}
+	rows, err := reader.rows(closer)
+	if err != nil {
+		return nil, fmt.Errorf("Parse(%s): %s: %w", p.GetName(), sheetName, err)
+	}
+	return rows, nil
 }
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -73,3 +74,34 @@ func TestLoadExcelHappyPathMultipleWorksheets(t *testing.T) {
 	assert.Equal(t, 876, len(rows.Rows), "Expected 876 rows to be loaded")
 	assert.Equal(t, 5, len(parser.GetHeadings(rows.Rows)), "Expected 5 headings to be loaded")
 }
+
+func TestCreateTablesFromEveryWorksheet(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	ctx.Values().SetValue("excel.sheets", "all")
+	database := db.GetGremelDB()
+	require.NoError(t, CreateTableFromFile(ctx, database, "book", "xlsx", "../test_resources/accounts_multiple_sheets.xlsx"))
+	assert.Equal(t, []string{"book_Sheet1", "book_Sheet2", "book_Sheet3"}, ctx.Values().GetValue("book.tables"))
+
+	for table, expected := range map[string]int64{"book_Sheet1": 1000, "book_Sheet2": 829, "book_Sheet3": 876} {
+		rows, _, err := database.Query("SELECT COUNT(*) AS n FROM " + table)
+		require.NoError(t, err)
+		assert.Equal(t, expected, rows[0]["n"], table)
+	}
+}
+
+func TestCreateTablesFromNamedWorksheets(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	ctx.Values().SetValue("excel.sheets", "Sheet2")
+	database := db.GetGremelDB()
+	require.NoError(t, CreateTableFromFile(ctx, database, "book2", "xlsx", "../test_resources/accounts_multiple_sheets.xlsx"))
+	assert.Equal(t, []string{"book2_Sheet2"}, ctx.Values().GetValue("book2.tables"))
+
+	ctx.Values().SetValue("excel.sheets", "Sheet1, Nope")
+	err := CreateTableFromFile(ctx, database, "book3", "xlsx", "../test_resources/accounts_multiple_sheets.xlsx")
+	assert.ErrorContains(t, err, "no such worksheet 'Nope'")
+}
+
+func TestSanitiseTableName(t *testing.T) {
+	assert.Equal(t, "Sheet1", SanitiseTableName("Sheet1"))
+	assert.Equal(t, "Q3_Sales__2025_", SanitiseTableName(" Q3 Sales (2025) "))
+}
@@ -0,0 +1,302 @@
+package adapter
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/xuri/excelize/v2"
+)
+
+// excelSheetReader turns the rows of a worksheet into data.Rows.
+//
+// The following hints in the context pick out the data:
+//
+//   - excel.range:  an A1-style range such as 'B4:H200' - anything outside it is ignored
+//   - excel.header: the (1-based) row number of the headings, or 0 if there are none
+//     (default: the first row of the range, or row 1)
+//
+// Without headings (or for cells beyond the last heading) the columns are
+// named after the column letter, e.g. 'F'.  A heading which is part of a
+// merged cell is repeated across the merged columns, and duplicate headings
+// get a numeric suffix, e.g. 'Q3', 'Q3_2'.
+//
+// Cells are read as their raw (unformatted) values, which for formulas is the
+// value which Excel cached when the workbook was saved.  If there is no cached
+// value, the formula is calculated.  Numbers formatted as dates or times are
+// converted to timestamps.  Rows with no values at all are skipped.
+type excelSheetReader struct {
+	spreadsheet *excelize.File
+	sheetName   string
+	date1904    bool
+	dateStyles  map[int]bool
+
+	// The cells of interest, all 1-based.  lastColumn and lastRow are 0 if unbounded.
+	firstColumn int
+	lastColumn  int
+	firstRow    int
+	lastRow     int
+	headerRow   int
+
+	// Where the order of the columns is recorded, as they are found
+	columns *data.ColumnOrder
+}
+
+func newExcelSheetReader(ctx data.GremelContext, spreadsheet *excelize.File, sheetName string, columns *data.ColumnOrder) (*excelSheetReader, error) {
+	r := &excelSheetReader{
+		spreadsheet: spreadsheet,
+		sheetName:   sheetName,
+		columns:     columns,
+		dateStyles:  make(map[int]bool),
+		firstColumn: 1,
+		firstRow:    1,
+		headerRow:   1,
+	}
+	if props, err := spreadsheet.GetWorkbookProps(); err == nil && props.Date1904 != nil {
+		r.date1904 = *props.Date1904
+	}
+	if ctx == nil {
+		return r, nil
+	}
+
+	if cellRange := ctx.Values().GetString("excel.range"); cellRange != "" {
+		from, to, found := strings.Cut(strings.ToUpper(cellRange), ":")
+		if !found {
+			return nil, fmt.Errorf("excel.range must be in the format A1:H20, not '%s'", cellRange)
+		}
+		var err error
+		if r.firstColumn, r.firstRow, err = excelize.CellNameToCoordinates(strings.ReplaceAll(from, "$", "")); err != nil {
+			return nil, fmt.Errorf("excel.range: %w", err)
+		}
+		if r.lastColumn, r.lastRow, err = excelize.CellNameToCoordinates(strings.ReplaceAll(to, "$", "")); err != nil {
+			return nil, fmt.Errorf("excel.range: %w", err)
+		}
+		if r.lastColumn < r.firstColumn || r.lastRow < r.firstRow {
+			return nil, fmt.Errorf("excel.range '%s' is back to front", cellRange)
+		}
+		r.headerRow = r.firstRow
+	}
+	if ctx.Values().GetValue("excel.header") != nil {
+		r.headerRow = int(ctx.Values().GetInt("excel.header"))
+		if r.headerRow < 0 || (r.headerRow > 0 && r.headerRow < r.firstRow) || (r.lastRow > 0 && r.headerRow > r.lastRow) {
+			return nil, fmt.Errorf("excel.header row %d is outside of the range", r.headerRow)
+		}
+	}
+	return r, nil
+}
+
+// rows returns an iterator over the data rows of the worksheet
+func (r *excelSheetReader) rows(closer func() error) (data.RowIterator, error) {
+	spreadsheetRows, err := r.spreadsheet.Rows(r.sheetName)
+	if err != nil {
+		return nil, fmt.Errorf("Rows(): %w", err)
+	}
+	closeRows := func() error {
+		spreadsheetRows.Close()
+		return closer()
+	}
+
+	// Step 1: Find the headings (if there are any)
+	rowNumber := 0
+	headings := make(map[int]string)
+	if r.headerRow > 0 {
+		for rowNumber < r.headerRow && spreadsheetRows.Next() {
+			rowNumber++
+		}
+		if rowNumber == r.headerRow {
+			cells, err := spreadsheetRows.Columns()
+			if err != nil {
+				spreadsheetRows.Close()
+				return nil, fmt.Errorf("Columns(): %w", err)
+			}
+			headings = r.getHeadings(cells)
+		}
+	}
+
+	// Step 2: convert each subsequent row as it is read
+	next := func() (data.Row, error) {
+		for {
+			if r.lastRow > 0 && rowNumber >= r.lastRow {
+				return nil, io.EOF
+			}
+			if !spreadsheetRows.Next() {
+				if err := spreadsheetRows.Error(); err != nil {
+					return nil, fmt.Errorf("Next(): %w", err)
+				}
+				return nil, io.EOF
+			}
+			rowNumber++
+			if rowNumber < r.firstRow {
+				continue
+			}
+			cells, err := spreadsheetRows.Columns(excelize.Options{RawCellValue: true})
+			if err != nil {
+				return nil, fmt.Errorf("Columns(): %w", err)
+			}
+			row, err := r.toRow(rowNumber, cells, headings)
+			if err != nil {
+				return nil, err
+			}
+			if len(row) > 0 {
+				return row, nil
+			}
+		}
+	}
+	return data.NewRowFuncIterator(next, closeRows), nil
+}
+
+// getHeadings maps the column numbers within the range onto headings
+func (r *excelSheetReader) getHeadings(cells []string) map[int]string {
+	values := make(map[int]string)
+	for i, value := range cells {
+		if value = strings.TrimSpace(value); value != "" {
+			values[i+1] = value
+		}
+	}
+
+	// A merged heading is only in the first cell, so fill it in across the merge
+	if mergedCells, err := r.spreadsheet.GetMergeCells(r.sheetName); err == nil {
+		for _, mergedCell := range mergedCells {
+			startColumn, startRow, err := excelize.CellNameToCoordinates(mergedCell.GetStartAxis())
+			if err != nil {
+				continue
+			}
+			endColumn, endRow, err := excelize.CellNameToCoordinates(mergedCell.GetEndAxis())
+			if err != nil || r.headerRow < startRow || r.headerRow > endRow {
+				continue
+			}
+			value := strings.TrimSpace(mergedCell.GetCellValue())
+			for column := startColumn; column <= endColumn && value != ""; column++ {
+				if _, exists := values[column]; !exists {
+					values[column] = value
+				}
+			}
+		}
+	}
+
+	headings := make(map[int]string)
+	seen := make(map[string]int)
+	for column := r.firstColumn; column <= len(cells) || column <= r.lastColumn; column++ {
+		if r.lastColumn > 0 && column > r.lastColumn {
+			break
+		}
+		heading, exists := values[column]
+		if !exists {
+			heading, _ = excelize.ColumnNumberToName(column)
+		}
+		seen[heading]++
+		if seen[heading] > 1 {
+			heading = fmt.Sprintf("%s_%d", heading, seen[heading])
+		}
+		headings[column] = heading
+		r.columns.Add(heading)
+	}
+	return headings
+}
+
+func (r *excelSheetReader) toRow(rowNumber int, cells []string, headings map[int]string) (data.Row, error) {
+	row := make(data.Row)
+	for i, value := range cells {
+		column := i + 1
+		if column < r.firstColumn || (r.lastColumn > 0 && column > r.lastColumn) {
+			continue
+		}
+		cellName, err := excelize.CoordinatesToCellName(column, rowNumber)
+		if err != nil {
+			return nil, err
+		}
+		cellValue, err := r.getCellValue(cellName, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cellName, err)
+		}
+		if cellValue == nil {
+			continue
+		}
+		heading, exists := headings[column]
+		if !exists {
+			// More cells than headings
+			heading, _ = excelize.ColumnNumberToName(column)
+			r.columns.Add(heading)
+		}
+		row[heading] = cellValue
+	}
+	return row, nil
+}
+
+// getCellValue turns the raw value of a cell into a value of the right type, or nil if the cell is empty
+func (r *excelSheetReader) getCellValue(cellName string, value string) (any, error) {
+	if value == "" {
+		// No value, but there may be a formula for which Excel did not cache a value
+		formula, err := r.spreadsheet.GetCellFormula(r.sheetName, cellName)
+		if err != nil || formula == "" {
+			return nil, nil
+		}
+		value, err = r.spreadsheet.CalcCellValue(r.sheetName, cellName, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate '%s': %w", formula, err)
+		}
+		if value == "" {
+			return nil, nil
+		}
+	}
+
+	if number, err := strconv.ParseFloat(value, 64); err == nil && r.isDateCell(cellName) {
+		timestamp, err := excelize.ExcelDateToTime(number, r.date1904)
+		if err == nil {
+			return timestamp, nil
+		}
+	}
+	return data.InferValue(value), nil
+}
+
+// isDateCell is true if the cell's number format is a date and/or a time
+func (r *excelSheetReader) isDateCell(cellName string) bool {
+	styleID, err := r.spreadsheet.GetCellStyle(r.sheetName, cellName)
+	if err != nil {
+		return false
+	}
+	isDate, cached := r.dateStyles[styleID]
+	if !cached {
+		if style, err := r.spreadsheet.GetStyle(styleID); err == nil {
+			isDate = isDateFormat(style.NumFmt, style.CustomNumFmt)
+		}
+		r.dateStyles[styleID] = isDate
+	}
+	return isDate
+}
+
+// isDateFormat recognises the built-in date/time number formats, and custom
+// formats which contain any day, month, year, hour or second codes
+func isDateFormat(numFmt int, customNumFmt *string) bool {
+	if customNumFmt == nil {
+		return (numFmt >= 14 && numFmt <= 22) ||
+			(numFmt >= 27 && numFmt <= 36) ||
+			(numFmt >= 45 && numFmt <= 47) ||
+			(numFmt >= 50 && numFmt <= 58)
+	}
+
+	format := strings.ToLower(*customNumFmt)
+	inQuotes := false
+	inBrackets := false
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch {
+		case c == '\\':
+			i++
+		case c == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case c == '[':
+			// e.g. [Red] or [$-409], but [h]:mm is an elapsed time
+			inBrackets = true
+		case c == ']':
+			inBrackets = false
+		case inBrackets && c != 'h' && c != 'm' && c != 's':
+		case c == 'd' || c == 'm' || c == 'y' || c == 'h' || c == 's':
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package adapter
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+// writeFinanceWorkbook writes a workbook which looks like this:
+//
+//	   A               B           C       D       E       F
+//	1  Quarterly figures
+//	2
+//	3                  Date        Region          Amount
+//	4                  2025-03-01  North   Leeds   12.5
+//	5                  2025-04-01  South   Bath    7.25    (extra)
+//	6
+//	7                  2025-05-01  North   York    30
+//	8                  Total                       =SUM(E4:E7) (cached as 99)
+//	9                  Check                       =E8*2 (not cached)
+//
+// where 'Region' is merged across C3:D3
+func writeFinanceWorkbook(t *testing.T) string {
+	f := excelize.NewFile()
+	sheet := "Sheet1"
+	require.NoError(t, f.SetCellValue(sheet, "A1", "Quarterly figures"))
+	require.NoError(t, f.SetSheetRow(sheet, "B3", &[]any{"Date", "Region", nil, "Amount"}))
+	require.NoError(t, f.MergeCell(sheet, "C3", "D3"))
+	require.NoError(t, f.SetSheetRow(sheet, "B4", &[]any{time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), "North", "Leeds", 12.5}))
+	require.NoError(t, f.SetSheetRow(sheet, "B5", &[]any{time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), "South", "Bath", 7.25, "extra"}))
+	require.NoError(t, f.SetSheetRow(sheet, "B7", &[]any{time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), "North", "York", 30}))
+	require.NoError(t, f.SetCellValue(sheet, "B8", "Total"))
+	require.NoError(t, f.SetCellFormula(sheet, "E8", "SUM(E4:E7)"))
+	require.NoError(t, f.SetCellValue(sheet, "B9", "Check"))
+	require.NoError(t, f.SetCellFormula(sheet, "E9", "E8*2"))
+	var buffer bytes.Buffer
+	require.NoError(t, f.Write(&buffer))
+
+	// excelize doesn't cache formula values, so pretend that Excel did
+	path := filepath.Join(t.TempDir(), "finance.xlsx")
+	in, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	require.NoError(t, err)
+	out, err := os.Create(path)
+	require.NoError(t, err)
+	defer out.Close()
+	zipWriter := zip.NewWriter(out)
+	for _, file := range in.File {
+		reader, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		reader.Close()
+		if file.Name == "xl/worksheets/sheet1.xml" {
+			content = []byte(strings.Replace(string(content), "<f>SUM(E4:E7)</f>", "<f>SUM(E4:E7)</f><v>99</v>", 1))
+		}
+		writer, err := zipWriter.Create(file.Name)
+		require.NoError(t, err)
+		_, err = writer.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+	return path
+}
+
+func parseExcel(t *testing.T, path string, hints map[string]any) []data.Row {
+	t.Helper()
+	ctx := data.NewGremelContext(context.TODO())
+	for k, v := range hints {
+		ctx.Values().SetValue(k, v)
+	}
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	rowList, err := NewGenericExcelParser(ctx).Parse(f)
+	require.NoError(t, err)
+	return rowList.Rows
+}
+
+func TestExcelSheetRange(t *testing.T) {
+	path := writeFinanceWorkbook(t)
+
+	rows := parseExcel(t, path, map[string]any{"excel.range": "B3:E7"})
+	require.Len(t, rows, 3)
+	assert.Equal(t, data.Row{
+		"Date":     time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		"Region":   "North",
+		"Region_2": "Leeds",
+		"Amount":   12.5,
+	}, rows[0])
+	// The extra cell is outside of the range
+	assert.NotContains(t, rows[1], "F")
+	assert.Equal(t, int64(30), rows[2]["Amount"])
+}
+
+func TestExcelSheetHeaderRow(t *testing.T) {
+	path := writeFinanceWorkbook(t)
+
+	rows := parseExcel(t, path, map[string]any{"excel.header": 3})
+	require.Len(t, rows, 5)
+	assert.Equal(t, "extra", rows[1]["F"])
+	assert.Equal(t, data.Row{"Date": "Total", "Amount": int64(99)}, rows[3])
+	// Not cached, so calculated (which recalculates the total as 12.5 + 7.25 + 30)
+	assert.Equal(t, data.Row{"Date": "Check", "Amount": 99.5}, rows[4])
+
+	// No headings at all
+	rows = parseExcel(t, path, map[string]any{"excel.header": 0, "excel.range": "B4:C4"})
+	require.Len(t, rows, 1)
+	assert.Equal(t, data.Row{"B": time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), "C": "North"}, rows[0])
+}
+
+func TestExcelSheetBadHints(t *testing.T) {
+	path := writeFinanceWorkbook(t)
+	for _, hints := range []map[string]any{
+		{"excel.range": "B3"},
+		{"excel.range": "E7:B3"},
+		{"excel.range": "B4:E7", "excel.header": 2},
+	} {
+		ctx := data.NewGremelContext(context.TODO())
+		for k, v := range hints {
+			ctx.Values().SetValue(k, v)
+		}
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		_, err = NewGenericExcelParser(ctx).Parse(f)
+		f.Close()
+		assert.Error(t, err, hints)
+	}
+}
+
+func TestCreateTableFromExcelWithDates(t *testing.T) {
+	path := writeFinanceWorkbook(t)
+	ctx := data.NewGremelContext(context.Background())
+	ctx.Values().SetValue("excel.range", "B3:E7")
+	database := db.GetGremelDB()
+	require.NoError(t, CreateTableFromFile(ctx, database, "finance", "xlsx", path))
+
+	schema, err := database.GetSchema("finance")
+	require.NoError(t, err)
+	assert.Equal(t, "TIMESTAMP", schema.Type("Date"))
+
+	rows, _, err := database.Query("SELECT Region_2 FROM finance WHERE Date >= '2025-04-01' ORDER BY Date")
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "Bath", rows[0]["Region_2"])
+}
+
+func TestIsDateFormat(t *testing.T) {
+	custom := func(format string) *string { return &format }
+	assert.True(t, isDateFormat(14, nil))
+	assert.True(t, isDateFormat(22, nil))
+	assert.False(t, isDateFormat(2, nil))
+	assert.True(t, isDateFormat(164, custom("dd/mm/yyyy")))
+	assert.True(t, isDateFormat(164, custom("[h]:mm:ss")))
+	assert.False(t, isDateFormat(164, custom("#,##0.00;[Red]-#,##0.00")))
+	assert.False(t, isDateFormat(164, custom(`0.0 "days"`)))
+}
@@ -0,0 +1,196 @@
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+)
+
+// FollowState records how far through a followed file we have got, so that
+// only the lines written since can be appended to the table.
+//
+// The inode tells us when the file has been rotated by renaming it (and a
+// new file created in its place).  It is zero where the platform does not
+// have inodes, in which case only truncation can be detected.
+type FollowState struct {
+	Inode  uint64
+	Offset int64
+}
+
+// CreateTableFromFollowedFile (re)creates tableName from every complete line
+// in datafile, and returns where it got up to for AppendFromFollowedFile.
+//
+// Only parsers which read one line at a time (log and ndjson) can be
+// followed.  Nested JSON is kept as JSON text, since child tables cannot be
+// appended to.
+func CreateTableFromFollowedFile(ctx data.GremelContext, database db.GremelDB, tableName string, fileType string, datafile string) (FollowState, error) {
+	followCtx, err := withoutChildTables(ctx, "following a file")
+	if err != nil {
+		return FollowState{}, fmt.Errorf("CreateTableFromFollowedFile(%s): %w", datafile, err)
+	}
+	f, err := os.Open(datafile)
+	if err != nil {
+		return FollowState{}, fmt.Errorf("CreateTableFromFollowedFile(%s): failed to open file: %w", datafile, err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return FollowState{}, fmt.Errorf("CreateTableFromFollowedFile(%s): %w", datafile, err)
+	}
+	end, err := lastLineEnd(f, fi.Size())
+	if err != nil {
+		return FollowState{}, fmt.Errorf("CreateTableFromFollowedFile(%s): %w", datafile, err)
+	}
+	magic := make([]byte, 8)
+	n, _ := f.ReadAt(magic, 0)
+	if DetectCompression(magic[:n]) != "" {
+		return FollowState{}, fmt.Errorf("CreateTableFromFollowedFile(%s): compressed files can't be followed", datafile)
+	}
+
+	input := bufio.NewReaderSize(io.NewSectionReader(f, 0, end), SniffSize)
+	parser, err := resolveFollowParser(followCtx, fileType, input)
+	if err != nil {
+		return FollowState{}, fmt.Errorf("CreateTableFromFollowedFile(%s): %w", datafile, err)
+	}
+	err = CreateTableFromReader(followCtx, database, tableName, input, parser)
+	if err != nil {
+		return FollowState{}, fmt.Errorf("CreateTableFromFollowedFile(%s): %w", datafile, err)
+	}
+	ctx.Values().SetValue(tableName+".tables", followCtx.Values().GetValue(tableName+".tables"))
+	return FollowState{Inode: fileInode(fi), Offset: end}, nil
+}
+
+// AppendFromFollowedFile appends the lines written to datafile since state
+// to tableName, and returns the new state along with the number of rows added.
+//
+// Log rotation is taken care of:
+//
+//   - copytruncate leaves the file shorter than we last saw it, so we start again from the top
+//   - renaming leaves a new file (i.e. a new inode) in its place.  The rest of
+//     the old file is read first, if it can be found alongside (e.g. 'access.log.1')
+func AppendFromFollowedFile(ctx data.GremelContext, database db.GremelDB, tableName string, fileType string, datafile string, state FollowState) (FollowState, int64, error) {
+	followCtx, err := withoutChildTables(ctx, "following a file")
+	if err != nil {
+		return state, 0, fmt.Errorf("AppendFromFollowedFile(%s): %w", datafile, err)
+	}
+	fi, err := os.Stat(datafile)
+	if err != nil {
+		return state, 0, fmt.Errorf("AppendFromFollowedFile(%s): %w", datafile, err)
+	}
+
+	var appended int64
+	inode := fileInode(fi)
+	switch {
+	case state.Inode != 0 && inode != 0 && inode != state.Inode:
+		if rotated := findRotatedFile(datafile, state.Inode); rotated != "" {
+			count, _, err := appendLines(followCtx, database, tableName, fileType, rotated, state.Offset)
+			if err != nil {
+				return state, 0, fmt.Errorf("AppendFromFollowedFile(%s): %w", rotated, err)
+			}
+			appended += count
+		}
+		state = FollowState{Inode: inode}
+	case fi.Size() < state.Offset:
+		state.Offset = 0
+	}
+
+	count, offset, err := appendLines(followCtx, database, tableName, fileType, datafile, state.Offset)
+	if err != nil {
+		return state, appended, fmt.Errorf("AppendFromFollowedFile(%s): %w", datafile, err)
+	}
+	return FollowState{Inode: inode, Offset: offset}, appended + count, nil
+}
+
+// appendLines appends the complete lines after offset, returning the number
+// of rows added and the offset after the last complete line
+func appendLines(ctx data.GremelContext, database db.GremelDB, tableName string, fileType string, datafile string, offset int64) (int64, int64, error) {
+	f, err := os.Open(datafile)
+	if err != nil {
+		return 0, offset, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, offset, err
+	}
+	end, err := lastLineEnd(f, fi.Size())
+	if err != nil {
+		return 0, offset, err
+	}
+	if end <= offset {
+		return 0, offset, nil
+	}
+
+	input := bufio.NewReaderSize(io.NewSectionReader(f, offset, end-offset), SniffSize)
+	parser, err := resolveFollowParser(ctx, fileType, input)
+	if err != nil {
+		return 0, offset, err
+	}
+	rows, err := data.ParseStream(parser, input)
+	if err != nil {
+		return 0, offset, err
+	}
+	defer rows.Close()
+	count, err := database.InsertRowStream(tableName, rows, getIntSetting(ctx, "mount.batchsize", data.CONF_MOUNT_BATCHSIZE, db.DefaultBatchSize))
+	if err != nil {
+		return count, offset, err
+	}
+	return count, end, nil
+}
+
+func resolveFollowParser(ctx data.GremelContext, fileType string, input *bufio.Reader) (data.Parser, error) {
+	head, err := PeekHead(input)
+	if err != nil {
+		return nil, err
+	}
+	parser, err := ResolveParser(ctx, "", fileType, head)
+	if err != nil {
+		return nil, err
+	}
+	switch parser.(type) {
+	case *GenericLogParser, *GenericNDJsonParser:
+		return parser, nil
+	}
+	return nil, fmt.Errorf("only log and ndjson files can be followed, not '%s'", parser.GetName())
+}
+
+// lastLineEnd returns the offset just after the last newline in the first
+// size bytes of the file, so that a line which is still being written is
+// left for next time
+func lastLineEnd(f *os.File, size int64) (int64, error) {
+	buffer := make([]byte, SniffSize)
+	for end := size; end > 0; {
+		start := max(end-int64(len(buffer)), 0)
+		chunk := buffer[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// findRotatedFile looks for the file which datafile was renamed to, e.g.
+// 'access.log.1' or 'access.log-20250101'
+func findRotatedFile(datafile string, inode uint64) string {
+	candidates, _ := filepath.Glob(datafile + "?*")
+	sort.Strings(candidates)
+	for _, candidate := range candidates {
+		if fi, err := os.Stat(candidate); err == nil && !fi.IsDir() && fileInode(fi) == inode {
+			return candidate
+		}
+	}
+	return ""
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package adapter
+
+import "os"
+
+// No inodes, so rotation by renaming can't be told apart from the file
+// being rewritten.  Truncation is still detected.
+func fileInode(fi os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,129 @@
+package adapter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	clfLine1 = `165.23.106.237 - alice [04/Sep/2025:19:12:36 -0700] "GET /logout HTTP/1.1" 200 11281` + "\n"
+	clfLine2 = `237.192.154.154 - - [15/Aug/2025:05:18:10 +0000] "PUT /settings HTTP/1.1" 200 972` + "\n"
+	clfLine3 = `104.241.242.159 - alice [12/Sep/2025:20:20:11 -0700] "GET /products HTTP/1.1" 404 1369` + "\n"
+)
+
+func countTableRows(t *testing.T, database db.GremelDB, tableName string) int64 {
+	rows, _, err := database.Query("SELECT COUNT(*) AS count FROM " + tableName)
+	require.NoError(t, err)
+	return rows[0]["count"].(int64)
+}
+
+func appendToFile(t *testing.T, path string, text string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString(text)
+	require.NoError(t, err)
+}
+
+func TestFollowAppendsNewLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	// The last line is still being written, so is left for later
+	require.NoError(t, os.WriteFile(path, []byte(clfLine1+clfLine2[:20]), 0644))
+
+	ctx := data.NewGremelContext(context.Background())
+	database := db.GetGremelDB()
+	state, err := CreateTableFromFollowedFile(ctx, database, "followed", "log", path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(clfLine1)), state.Offset)
+	assert.Equal(t, int64(1), countTableRows(t, database, "followed"))
+
+	// Nothing new
+	state, appended, err := AppendFromFollowedFile(ctx, database, "followed", "log", path, state)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), appended)
+
+	appendToFile(t, path, clfLine2[20:]+clfLine3)
+	state, appended, err = AppendFromFollowedFile(ctx, database, "followed", "log", path, state)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), appended)
+	assert.Equal(t, int64(len(clfLine1+clfLine2+clfLine3)), state.Offset)
+
+	rows, _, err := database.Query("SELECT status FROM followed WHERE path = '/products'")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.EqualValues(t, 404, rows[0]["status"])
+}
+
+func TestFollowCopyTruncate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	require.NoError(t, os.WriteFile(path, []byte(clfLine1+clfLine2), 0644))
+
+	ctx := data.NewGremelContext(context.Background())
+	database := db.GetGremelDB()
+	state, err := CreateTableFromFollowedFile(ctx, database, "truncated", "log", path)
+	require.NoError(t, err)
+
+	// logrotate copies the file elsewhere then truncates it, and the logging carries on
+	require.NoError(t, os.Truncate(path, 0))
+	appendToFile(t, path, clfLine3)
+	state, appended, err := AppendFromFollowedFile(ctx, database, "truncated", "log", path, state)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), appended)
+	assert.Equal(t, int64(len(clfLine3)), state.Offset)
+	assert.Equal(t, int64(3), countTableRows(t, database, "truncated"))
+}
+
+func TestFollowRename(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	require.NoError(t, os.WriteFile(path, []byte(clfLine1), 0644))
+
+	ctx := data.NewGremelContext(context.Background())
+	database := db.GetGremelDB()
+	state, err := CreateTableFromFollowedFile(ctx, database, "renamed", "log", path)
+	require.NoError(t, err)
+	if state.Inode == 0 {
+		t.Skip("no inodes on this platform")
+	}
+
+	// A last line is written to the old file after it has been renamed
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendToFile(t, path+".1", clfLine2)
+	require.NoError(t, os.WriteFile(path, []byte(clfLine3), 0644))
+
+	state, appended, err := AppendFromFollowedFile(ctx, database, "renamed", "log", path, state)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), appended)
+	assert.Equal(t, int64(len(clfLine3)), state.Offset)
+	assert.Equal(t, int64(3), countTableRows(t, database, "renamed"))
+}
+
+func TestFollowNDJsonKeepsNestedJson(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(`{"id": 1, "tags": [{"name": "a"}]}`+"\n"), 0644))
+
+	ctx := data.NewGremelContext(context.Background())
+	database := db.GetGremelDB()
+	_, err := CreateTableFromFollowedFile(ctx, database, "followed_events", "jsonl", path)
+	require.NoError(t, err)
+	rows, _, err := database.Query("SELECT tags FROM followed_events")
+	require.NoError(t, err)
+	assert.Equal(t, `[{"name":"a"}]`, rows[0]["tags"])
+
+	ctx.Values().SetValue("json.nested", JsonNestedFlatten)
+	_, err = CreateTableFromFollowedFile(ctx, database, "followed_events", "jsonl", path)
+	assert.ErrorContains(t, err, "json.nested")
+}
+
+func TestFollowRejectsCSV(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	_, err := CreateTableFromFollowedFile(ctx, db.GetGremelDB(), "followed_csv", "csv", "../test_resources/people.csv")
+	assert.ErrorContains(t, err, "only log and ndjson files can be followed")
+}
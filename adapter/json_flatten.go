@@ -0,0 +1,361 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/helper"
+)
+
+const (
+	// Nested objects become columns, e.g. {"address": {"city": "Leeds"}} becomes 'address_city',
+	// and arrays of objects become child tables
+	JsonNestedFlatten = "flatten"
+	// Nested objects and arrays are stored as JSON text, for use with json_extract()
+	JsonNestedJson = "json"
+
+	// The separator between a child table name and its parent, e.g. 'orders__items'
+	ChildTableSeparator = "__"
+
+	// The prefix for a key in the JSON which has the same name as a generated
+	// column, e.g. '_source_id'
+	sourceColumnPrefix = "_source"
+)
+
+// The columns which are generated to join child tables to their parents
+var generatedColumns = map[string]bool{"_id": true, "_parent_id": true, "_index": true}
+
+// allRowsHeld means that none of the top-level rows are loaded until they have
+// all been flattened, e.g. because the whole document has been parsed
+const allRowsHeld = -1
+
+// jsonFlattener turns JSON objects with nested values into flat rows.
+//
+// The 'json.nested' hint in the context picks the mode (flatten or json) and
+// 'json.separator' the separator used for the flattened column names.
+//
+// When flattening, an array of objects (or an empty array) is split out
+// into a child table. Each child row has a '_parent_id' which joins to the
+// '_id' of the row it came from, and an '_index' which is its (zero-based)
+// position in the array. Only tables which have child tables get an '_id'.
+// Arrays of anything else are stored as JSON text.
+//
+// A key in the JSON with the same name as one of these generated columns
+// (e.g. the '_id' in a MongoDB export) is kept as '_source<key>', e.g.
+// '_source_id'.
+//
+// Child rows are held in memory until the parent rows have all been read.
+// Top-level rows are streamed, so the flattener keeps hold of those which
+// haven't been loaded yet (the first 'mount.sample'), and gives them their
+// '_id' if a later row turns out to have children.  Rows which have already
+// been loaded by then have no children to be joined to, so their '_id' is
+// NULL.
+//
+// Decoding JSON into maps loses the order of the keys, so the parsers hand
+// the raw JSON to RecordKeyOrder first, and keys are flattened in the order
+// they first appeared.  The columns of each table are then in that order too.
+type jsonFlattener struct {
+	mode      string
+	separator string
+
+	children    map[string][]data.Row
+	counts      map[string]int64
+	keyRanks    map[string]int
+	columns     map[string]*data.ColumnOrder
+	hasChildren map[string]bool
+
+	// The number of top-level rows which are held (e.g. as the sample) before
+	// any are loaded, and those of them which don't have an '_id' yet.
+	// numbered is set once the top-level rows are given an '_id', and
+	// sourceIDs if any with an '_id' of their own were loaded before then.
+	heldRows  int
+	held      []data.Row
+	numbered  bool
+	sourceIDs bool
+}
+
+func newJsonFlattener(ctx data.GremelContext) (*jsonFlattener, error) {
+	f := &jsonFlattener{
+		mode:        JsonNestedFlatten,
+		separator:   "_",
+		children:    make(map[string][]data.Row),
+		counts:      make(map[string]int64),
+		keyRanks:    make(map[string]int),
+		columns:     make(map[string]*data.ColumnOrder),
+		hasChildren: make(map[string]bool),
+		heldRows:    getIntSetting(ctx, "mount.sample", data.CONF_MOUNT_SAMPLE, DefaultSampleSize),
+	}
+	if ctx != nil {
+		if mode := ctx.Values().GetString("json.nested"); mode != "" {
+			f.mode = mode
+		}
+		if separator := ctx.Values().GetString("json.separator"); separator != "" {
+			f.separator = separator
+		}
+	}
+	if f.mode != JsonNestedFlatten && f.mode != JsonNestedJson {
+		return nil, fmt.Errorf("json.nested must be '%s' or '%s', not '%s'", JsonNestedFlatten, JsonNestedJson, f.mode)
+	}
+	return f, nil
+}
+
+// withoutChildTables scopes 'json.nested=json' to a mount which can't have
+// child tables (e.g. because rows are appended later, or come from several
+// files), unless the caller has asked for something else
+func withoutChildTables(ctx data.GremelContext, reason string) (data.GremelContext, error) {
+	scopedCtx := data.NewGremelContext(ctx.Context(), ctx.Values())
+	switch scopedCtx.Values().GetString("json.nested") {
+	case "":
+		scopedCtx.Values().SetValue("json.nested", JsonNestedJson)
+	case JsonNestedJson:
+	default:
+		return nil, fmt.Errorf("json.nested must be '%s' when %s", JsonNestedJson, reason)
+	}
+	return scopedCtx, nil
+}
+
+// Flatten flattens a top-level row
+func (f *jsonFlattener) Flatten(row data.Row) (data.Row, error) {
+	flattened := f.flattenRow("", row)
+	if err := f.numberRow(flattened, f.counts[""]); err != nil {
+		return nil, err
+	}
+	return flattened, nil
+}
+
+// numberRow gives a top-level row its '_id' if the table has child tables.
+// The first time it does, the rows which are being held are given theirs.
+func (f *jsonFlattener) numberRow(row data.Row, id int64) error {
+	if !f.hasChildren[""] {
+		if f.heldRows == allRowsHeld || id <= int64(f.heldRows) {
+			f.held = append(f.held, row)
+		} else if _, exists := row["_id"]; exists {
+			f.sourceIDs = true
+		}
+		return nil
+	}
+	if !f.numbered {
+		f.numbered = true
+		if f.heldRows != allRowsHeld && id > int64(f.heldRows) {
+			// Too late to move the '_id' of the rows which have been loaded
+			// out of the way
+			if f.sourceIDs {
+				return fmt.Errorf("row %d has child rows, but the rows before it have their own '_id' and have already been loaded (mount.sample is %d)", id, f.heldRows)
+			}
+			f.held = nil
+		}
+		f.renameSourceColumn("", "_id")
+		for i, heldRow := range f.held {
+			setGeneratedID(heldRow, int64(i+1))
+		}
+		f.held = nil
+	}
+	setGeneratedID(row, id)
+	return nil
+}
+
+// setGeneratedID sets the '_id' of a row, keeping any '_id' of its own as '_source_id'
+func setGeneratedID(row data.Row, id int64) {
+	if sourceID, exists := row["_id"]; exists {
+		row[sourceColumnPrefix+"_id"] = sourceID
+	}
+	row["_id"] = id
+}
+
+// renameSourceColumn records that the name column of a table has become '_source<name>'
+func (f *jsonFlattener) renameSourceColumn(tablePath string, name string) {
+	renamed := &data.ColumnOrder{}
+	for _, column := range f.tableColumns(tablePath).Names() {
+		if column == name {
+			column = sourceColumnPrefix + name
+		}
+		renamed.Add(column)
+	}
+	f.columns[tablePath] = renamed
+}
+
+// RecordKeyOrder notes the order of the object keys in jsonBytes, which
+// is then the order the keys are flattened in.  Malformed JSON is ignored
+// here, since it will fail when it is decoded.
+func (f *jsonFlattener) RecordKeyOrder(jsonBytes []byte) {
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	// Whether each of the enclosing values is an object
+	var inObject []bool
+	expectKey := false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		if delim, isDelim := token.(json.Delim); isDelim {
+			switch delim {
+			case '{', '[':
+				inObject = append(inObject, delim == '{')
+				expectKey = delim == '{'
+			default:
+				inObject = inObject[:len(inObject)-1]
+				expectKey = len(inObject) > 0 && inObject[len(inObject)-1]
+			}
+			continue
+		}
+		if expectKey {
+			if key, isString := token.(string); isString {
+				if _, seen := f.keyRanks[key]; !seen {
+					f.keyRanks[key] = len(f.keyRanks)
+				}
+			}
+			expectKey = false
+			continue
+		}
+		expectKey = len(inObject) > 0 && inObject[len(inObject)-1]
+	}
+}
+
+// Columns returns the columns of the top-level rows, in the order they were
+// first seen
+func (f *jsonFlattener) Columns() []string {
+	if f.hasChildren[""] {
+		return append([]string{"_id"}, f.tableColumns("").Names()...)
+	}
+	return f.tableColumns("").Names()
+}
+
+// ChildTables returns the child tables (sorted by name) which have been split out so far
+func (f *jsonFlattener) ChildTables() []data.ChildTable {
+	tables := make([]data.ChildTable, 0, len(f.children))
+	for name, rows := range f.children {
+		// '_parent_id' and '_index' come first
+		columns := f.tableColumns(name).Names()
+		if f.hasChildren[name] {
+			// The rows are numbered in the order they were flattened
+			for i, row := range rows {
+				row["_id"] = int64(i + 1)
+			}
+			columns = append(append(columns[:2:2], "_id"), columns[2:]...)
+		}
+		tables = append(tables, data.ChildTable{
+			Name:    name,
+			Columns: columns,
+			Rows:    helper.NormaliseNumbers(rows),
+		})
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Name < tables[j].Name
+	})
+	return tables
+}
+
+func (f *jsonFlattener) tableColumns(tablePath string) *data.ColumnOrder {
+	columns, exists := f.columns[tablePath]
+	if !exists {
+		columns = &data.ColumnOrder{}
+		f.columns[tablePath] = columns
+	}
+	return columns
+}
+
+// orderedKeys returns the keys of an object in the order they were first seen
+// by RecordKeyOrder (and any others in name order)
+func (f *jsonFlattener) orderedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		rankI, knownI := f.keyRanks[keys[i]]
+		rankJ, knownJ := f.keyRanks[keys[j]]
+		switch {
+		case knownI && knownJ:
+			return rankI < rankJ
+		case knownI != knownJ:
+			return knownI
+		default:
+			return keys[i] < keys[j]
+		}
+	})
+	return keys
+}
+
+func (f *jsonFlattener) flattenRow(tablePath string, row map[string]any) data.Row {
+	f.counts[tablePath]++
+	id := f.counts[tablePath]
+	if tablePath != "" {
+		// Set by flattenValue once the child row has been flattened, but
+		// they go first
+		f.tableColumns(tablePath).Add("_parent_id", "_index")
+	}
+
+	flattened := make(data.Row, len(row))
+	for _, key := range f.orderedKeys(row) {
+		columnName := key
+		if generatedColumns[key] && (tablePath != "" || f.numbered) {
+			columnName = sourceColumnPrefix + key
+		}
+		f.flattenValue(tablePath, id, flattened, columnName, row[key])
+	}
+	return flattened
+}
+
+// flattenValue adds value to row under columnName (or columns prefixed by it)
+func (f *jsonFlattener) flattenValue(tablePath string, id int64, row data.Row, columnName string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		if f.mode == JsonNestedJson || len(v) == 0 {
+			f.setColumn(tablePath, row, columnName, toJsonText(v))
+			return
+		}
+		for _, key := range f.orderedKeys(v) {
+			f.flattenValue(tablePath, id, row, columnName+f.separator+key, v[key])
+		}
+
+	case []any:
+		// An empty array has no child rows, rather than being '[]' in a row
+		// whose neighbours have theirs split out
+		if f.mode == JsonNestedJson || !isObjectList(v) {
+			f.setColumn(tablePath, row, columnName, toJsonText(v))
+			return
+		}
+		childPath := columnName
+		if tablePath != "" {
+			childPath = tablePath + ChildTableSeparator + columnName
+		}
+		f.hasChildren[tablePath] = f.hasChildren[tablePath] || len(v) > 0
+		for i, element := range v {
+			child := f.flattenRow(childPath, element.(map[string]any))
+			child["_parent_id"] = id
+			child["_index"] = int64(i)
+			f.children[childPath] = append(f.children[childPath], child)
+		}
+
+	default:
+		f.setColumn(tablePath, row, columnName, value)
+	}
+}
+
+func (f *jsonFlattener) setColumn(tablePath string, row data.Row, columnName string, value any) {
+	row[columnName] = value
+	f.tableColumns(tablePath).Add(columnName)
+}
+
+// isObjectList is true for an array which only contains objects (which an
+// empty array does)
+func isObjectList(values []any) bool {
+	for _, value := range values {
+		if _, isMap := value.(map[string]any); !isMap {
+			return false
+		}
+	}
+	return true
+}
+
+func toJsonText(value any) string {
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		// Can't happen for anything which came out of encoding/json
+		return fmt.Sprint(value)
+	}
+	return string(jsonBytes)
+}
@@ -0,0 +1,228 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJsonFlattenerFlattensNestedObjects(t *testing.T) {
+	f, err := newJsonFlattener(data.NewGremelContext(context.TODO()))
+	require.NoError(t, err)
+
+	row, err := f.Flatten(data.Row{
+		"id":      1.0,
+		"address": map[string]any{"city": "Leeds", "geo": map[string]any{"lat": 53.8}},
+		"tags":    []any{"a", "b"},
+		"empty":   map[string]any{},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, data.Row{
+		"id":              1.0,
+		"address_city":    "Leeds",
+		"address_geo_lat": 53.8,
+		"tags":            `["a","b"]`,
+		"empty":           "{}",
+	}, row)
+	assert.Empty(t, f.ChildTables())
+}
+
+func TestJsonFlattenerSplitsOutChildTables(t *testing.T) {
+	ctx := data.NewGremelContext(context.TODO())
+	ctx.Values().SetValue("json.separator", ".")
+	f, err := newJsonFlattener(ctx)
+	require.NoError(t, err)
+
+	first, err := f.Flatten(data.Row{"id": "a", "items": []any{
+		map[string]any{"sku": "x", "parts": []any{map[string]any{"part": "p1"}}},
+		map[string]any{"sku": "y", "meta": map[string]any{"colour": "red"}},
+	}})
+	require.NoError(t, err)
+	second, err := f.Flatten(data.Row{"id": "b"})
+	require.NoError(t, err)
+	assert.Equal(t, data.Row{"id": "a", "_id": int64(1)}, first)
+	assert.Equal(t, data.Row{"id": "b", "_id": int64(2)}, second)
+
+	tables := f.ChildTables()
+	require.Len(t, tables, 2)
+	assert.Equal(t, "items", tables[0].Name)
+	assert.Equal(t, []data.Row{
+		{"sku": "x", "_id": int64(1), "_parent_id": int64(1), "_index": int64(0)},
+		{"sku": "y", "meta.colour": "red", "_id": int64(2), "_parent_id": int64(1), "_index": int64(1)},
+	}, tables[0].Rows)
+	assert.Equal(t, []string{"_parent_id", "_index", "_id", "sku", "meta.colour"}, tables[0].Columns)
+	// No grandchildren, so no '_id'
+	assert.Equal(t, "items__parts", tables[1].Name)
+	assert.Equal(t, []data.Row{
+		{"part": "p1", "_parent_id": int64(1), "_index": int64(0)},
+	}, tables[1].Rows)
+	assert.Equal(t, []string{"_parent_id", "_index", "part"}, tables[1].Columns)
+}
+
+func TestJsonFlattenerSplitsOutEmptyArrays(t *testing.T) {
+	f, err := newJsonFlattener(data.NewGremelContext(context.TODO()))
+	require.NoError(t, err)
+
+	first, err := f.Flatten(data.Row{"id": 1.0, "items": []any{}})
+	require.NoError(t, err)
+	second, err := f.Flatten(data.Row{"id": 2.0, "items": []any{map[string]any{"sku": "a"}}})
+	require.NoError(t, err)
+	// The first row is given its '_id' once it turns out that the table has children
+	assert.Equal(t, data.Row{"id": 1.0, "_id": int64(1)}, first)
+	assert.Equal(t, data.Row{"id": 2.0, "_id": int64(2)}, second)
+	assert.Equal(t, []string{"_id", "id"}, f.Columns())
+
+	tables := f.ChildTables()
+	require.Len(t, tables, 1)
+	assert.Equal(t, []data.Row{{"sku": "a", "_parent_id": int64(2), "_index": int64(0)}}, tables[0].Rows)
+
+	// Nothing but empty arrays means no child table, and no '_id'
+	f, err = newJsonFlattener(data.NewGremelContext(context.TODO()))
+	require.NoError(t, err)
+	row, err := f.Flatten(data.Row{"id": 1.0, "items": []any{}})
+	require.NoError(t, err)
+	assert.Equal(t, data.Row{"id": 1.0}, row)
+	assert.Equal(t, []string{"id"}, f.Columns())
+	assert.Empty(t, f.ChildTables())
+}
+
+func TestJsonFlattenerKeepsSourceIDs(t *testing.T) {
+	f, err := newJsonFlattener(data.NewGremelContext(context.TODO()))
+	require.NoError(t, err)
+
+	first, err := f.Flatten(data.Row{"_id": "abc", "name": "first"})
+	require.NoError(t, err)
+	second, err := f.Flatten(data.Row{"_id": "def", "items": []any{map[string]any{"_id": "x", "_index": 9.0}}})
+	require.NoError(t, err)
+	third, err := f.Flatten(data.Row{"_id": "ghi"})
+	require.NoError(t, err)
+	assert.Equal(t, data.Row{"_id": int64(1), "_source_id": "abc", "name": "first"}, first)
+	assert.Equal(t, data.Row{"_id": int64(2), "_source_id": "def"}, second)
+	assert.Equal(t, data.Row{"_id": int64(3), "_source_id": "ghi"}, third)
+	assert.Equal(t, []string{"_id", "_source_id", "name"}, f.Columns())
+
+	tables := f.ChildTables()
+	require.Len(t, tables, 1)
+	assert.Equal(t, []data.Row{{"_source_id": "x", "_source_index": int64(9), "_parent_id": int64(2), "_index": int64(0)}}, tables[0].Rows)
+	assert.Equal(t, []string{"_parent_id", "_index", "_source_id", "_source_index"}, tables[0].Columns)
+}
+
+func TestJsonFlattenerChildrenAfterTheSample(t *testing.T) {
+	ctx := data.NewGremelContext(context.TODO())
+	ctx.Values().SetValue("mount.sample", 1)
+	f, err := newJsonFlattener(ctx)
+	require.NoError(t, err)
+
+	// The rows which have already been loaded have no children, so they can do without an '_id'
+	first, err := f.Flatten(data.Row{"id": 1.0})
+	require.NoError(t, err)
+	second, err := f.Flatten(data.Row{"id": 2.0})
+	require.NoError(t, err)
+	third, err := f.Flatten(data.Row{"id": 3.0, "items": []any{map[string]any{"sku": "a"}}})
+	require.NoError(t, err)
+	assert.Equal(t, data.Row{"id": 1.0}, first)
+	assert.Equal(t, data.Row{"id": 2.0}, second)
+	assert.Equal(t, data.Row{"id": 3.0, "_id": int64(3)}, third)
+
+	// ...but their own '_id' can't be moved out of the way
+	f, err = newJsonFlattener(ctx)
+	require.NoError(t, err)
+	for _, row := range []data.Row{{"_id": "a"}, {"_id": "b"}} {
+		_, err = f.Flatten(row)
+		require.NoError(t, err)
+	}
+	_, err = f.Flatten(data.Row{"_id": "c", "items": []any{map[string]any{"sku": "a"}}})
+	assert.ErrorContains(t, err, "row 3 has child rows")
+}
+
+func TestJsonFlattenerKeepsKeyOrder(t *testing.T) {
+	f, err := newJsonFlattener(data.NewGremelContext(context.TODO()))
+	require.NoError(t, err)
+
+	jsonBytes := []byte(`{"zeta": 1, "address": {"street": "High St", "city": "Leeds"}, "orders": [{"sku": "x", "qty": 2}], "alpha": [1, 2]}`)
+	f.RecordKeyOrder(jsonBytes)
+	var row data.Row
+	require.NoError(t, json.Unmarshal(jsonBytes, &row))
+	_, err = f.Flatten(row)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"_id", "zeta", "address_street", "address_city", "alpha"}, f.Columns())
+	tables := f.ChildTables()
+	require.Len(t, tables, 1)
+	assert.Equal(t, []string{"_parent_id", "_index", "sku", "qty"}, tables[0].Columns)
+}
+
+func TestJsonFlattenerRejectsUnknownMode(t *testing.T) {
+	ctx := data.NewGremelContext(context.TODO())
+	ctx.Values().SetValue("json.nested", "explode")
+	_, err := newJsonFlattener(ctx)
+	assert.Error(t, err)
+}
+
+func TestCreateTableFromNestedJson(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	database := db.GetGremelDB()
+	require.NoError(t, CreateTableFromFile(ctx, database, "orders", "json", "../test_resources/orders.json"))
+	assert.Equal(t, []string{"orders__items"}, ctx.Values().GetValue("orders.tables"))
+
+	rows, _, err := database.Query(`
+		SELECT orders.id, orders.customer_address_city AS city, SUM(orders__items.qty) AS qty
+		FROM orders JOIN orders__items ON orders__items._parent_id = orders._id
+		GROUP BY orders.id ORDER BY orders.id`)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, data.Row{"id": int64(1001), "city": "Leeds", "qty": int64(3)}, rows[0])
+	assert.Equal(t, data.Row{"id": int64(1002), "city": "York", "qty": int64(5)}, rows[1])
+}
+
+func TestCreateTableFromNestedJsonWithDottedColumns(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	ctx.Values().SetValue("json.separator", ".")
+	database := db.GetGremelDB()
+	require.NoError(t, CreateTableFromFile(ctx, database, "dotted_orders", "json", "../test_resources/orders.json"))
+
+	rows, _, err := database.Query(`SELECT "customer.address.city" AS city FROM dotted_orders ORDER BY id`)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "Leeds", rows[0]["city"])
+}
+
+func TestCreateTableFromNestedJsonWithSourceIDs(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	database := db.GetGremelDB()
+	input := `{"_id": "abc", "items": [{"sku": "x"}, {"sku": "y"}]}` + "\n" + `{"_id": "def", "items": []}` + "\n"
+	require.NoError(t, CreateTableFromReader(ctx, database, "mongo", strings.NewReader(input), NewGenericNDJsonParser(ctx)))
+
+	rows, _, err := database.Query(`
+		SELECT mongo._source_id AS id, COUNT(mongo__items.sku) AS items
+		FROM mongo LEFT JOIN mongo__items ON mongo__items._parent_id = mongo._id
+		GROUP BY mongo._id ORDER BY mongo._id`)
+	require.NoError(t, err)
+	assert.Equal(t, []data.Row{{"id": "abc", "items": int64(2)}, {"id": "def", "items": int64(0)}}, rows)
+}
+
+func TestCreateTableFromNestedJsonAsJsonText(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	ctx.Values().SetValue("json.nested", "json")
+	database := db.GetGremelDB()
+	require.NoError(t, CreateTableFromFile(ctx, database, "orders_json", "json", "../test_resources/orders.json"))
+	assert.Empty(t, ctx.Values().GetValue("orders_json.tables"))
+
+	schema, err := database.GetSchema("orders_json")
+	require.NoError(t, err)
+	assert.Equal(t, "TEXT", schema.Type("customer"))
+	assert.Equal(t, "TEXT", schema.Type("items"))
+
+	rows, _, err := database.Query(`
+		SELECT id, json_extract(customer, '$.address.city') AS city, json_array_length(items) AS items
+		FROM orders_json ORDER BY id`)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, data.Row{"id": int64(1001), "city": "Leeds", "items": int64(2)}, rows[0])
+}
@@ -1,6 +1,7 @@
 package adapter
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -19,8 +20,12 @@ import (
 //   - unmarshals the JSON into a map
 //   - looks for the first slice of map[string]any (breadth-first recursive)
 //   - uses that as the row data
+//
+// Nested objects and arrays within the rows are flattened (or stored as JSON
+// text) - see jsonFlattener.
 type GenericJsonParser struct {
 	BaseAdapter
+	flattener *jsonFlattener
 }
 
 func NewGenericJsonParser(ctx data.GremelContext) data.Parser {
@@ -31,12 +36,28 @@ func NewGenericJsonParser(ctx data.GremelContext) data.Parser {
 }
 
 func (p *GenericJsonParser) Parse(input io.Reader) (*data.RowList, error) {
+	if err := p.initFlattener(); err != nil {
+		e := fmt.Errorf("%s.Parse(): %s", p.Name, err.Error())
+		return data.NewRowList(nil, nil, e), e
+	}
+
+	jsonBytes, err := io.ReadAll(input)
+	if err != nil {
+		e := fmt.Errorf("%s.Parse(): %s", p.Name, err.Error())
+		return data.NewRowList(nil, nil, e), e
+	}
+	p.flattener.RecordKeyOrder(jsonBytes)
 
-	// Step 2: Check the context, see if a root for the data has been specified
-	//         via the 'root' value
+	// Step 1: Check the context, see if a root for the data has been specified
+	//         via the 'data' value
 	if p.Ctx != nil {
 		if dataLocation := p.Ctx.Values().GetString("data"); dataLocation != "" {
-			rows, err := p.getJsonObjectList(input, dataLocation)
+			rows, err := p.getJsonObjectList(bytes.NewReader(jsonBytes), dataLocation)
+			if err != nil {
+				e := fmt.Errorf("%s.Parse(): %s", p.Name, err.Error())
+				return data.NewRowList(nil, nil, e), e
+			}
+			rows, err = p.flattenRows(rows)
 			if err != nil {
 				e := fmt.Errorf("%s.Parse(): %s", p.Name, err.Error())
 				return data.NewRowList(nil, nil, e), e
@@ -46,18 +67,17 @@ func (p *GenericJsonParser) Parse(input io.Reader) (*data.RowList, error) {
 		}
 	}
 
-	// Step 1: Easy mode: try unmarshalling into a []Row
-	jsonBytes, err := io.ReadAll(input)
-	if err != nil {
-		e := fmt.Errorf("%s.Parse(): %s", p.Name, err.Error())
-		return data.NewRowList(nil, nil, e), e
-	}
-
+	// Step 2: Easy mode: try unmarshalling into a []Row
 	var sliceOfMap []data.Row
 	err = json.NewDecoder(bytes.NewReader(jsonBytes)).Decode(&sliceOfMap)
 	if err == nil {
 		// DONE
 		// It is already a []data.Row
+		sliceOfMap, err = p.flattenRows(sliceOfMap)
+		if err != nil {
+			e := fmt.Errorf("%s.Parse(): %s", p.Name, err.Error())
+			return data.NewRowList(nil, nil, e), e
+		}
 		return data.NewRowList(helper.NormaliseNumbers(sliceOfMap), p.GetHeadings(sliceOfMap), nil), nil
 	}
 
@@ -76,10 +96,131 @@ func (p *GenericJsonParser) Parse(input io.Reader) (*data.RowList, error) {
 		e := fmt.Errorf("%s.Parse(): %s", p.Name, err.Error())
 		return data.NewRowList(nil, nil, e), e
 	}
+	rows, err = p.flattenRows(rows)
+	if err != nil {
+		e := fmt.Errorf("%s.Parse(): %s", p.Name, err.Error())
+		return data.NewRowList(nil, nil, e), e
+	}
 
 	return data.NewRowList(helper.NormaliseNumbers(rows), p.GetHeadings(rows), nil), nil
 }
 
+// GetHeadings returns the columns in the order their keys first appeared
+func (p *GenericJsonParser) GetHeadings(rows []data.Row) []string {
+	if p.flattener == nil {
+		return p.BaseAdapter.GetHeadings(rows)
+	}
+	return p.orderedHeadings(rows, p.flattener.Columns())
+}
+
+// ChildTables returns the tables which were split out of the rows by flattening
+func (p *GenericJsonParser) ChildTables() []data.ChildTable {
+	if p.flattener == nil {
+		return nil
+	}
+	return p.flattener.ChildTables()
+}
+
+func (p *GenericJsonParser) initFlattener() error {
+	if p.flattener != nil {
+		return nil
+	}
+	flattener, err := newJsonFlattener(p.Ctx)
+	if err != nil {
+		return err
+	}
+	p.flattener = flattener
+	return nil
+}
+
+// flattenRows flattens the rows of a document which has been parsed in full,
+// so none of them have been loaded yet
+func (p *GenericJsonParser) flattenRows(rows []data.Row) ([]data.Row, error) {
+	p.flattener.heldRows = allRowsHeld
+	for i := range rows {
+		flattened, err := p.flattener.Flatten(rows[i])
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = flattened
+	}
+	return rows, nil
+}
+
+// ParseStream streams the rows out of a top-level JSON array one object at a
+// time.  Any other shape of document (or one where the 'data' root has been
+// given) has to be searched for the rows, so it is parsed in full via Parse().
+func (p *GenericJsonParser) ParseStream(input io.Reader) (data.RowIterator, error) {
+	if err := p.initFlattener(); err != nil {
+		return nil, fmt.Errorf("%s.ParseStream(): %s", p.Name, err.Error())
+	}
+	bufferedInput := bufio.NewReader(input)
+	isArray, err := startsWithArray(bufferedInput)
+	if err != nil {
+		return nil, fmt.Errorf("%s.ParseStream(): %s", p.Name, err.Error())
+	}
+	if !isArray || (p.Ctx != nil && p.Ctx.Values().GetString("data") != "") {
+		return data.ParseStream(p.asParser(), bufferedInput)
+	}
+
+	decoder := json.NewDecoder(bufferedInput)
+	if _, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("%s.ParseStream(): %s", p.Name, err.Error())
+	}
+	index := 0
+	next := func() (data.Row, error) {
+		if !decoder.More() {
+			return nil, io.EOF
+		}
+		var rawElement json.RawMessage
+		if err := decoder.Decode(&rawElement); err != nil {
+			return nil, fmt.Errorf("%s.ParseStream(): element %d: %s", p.Name, index, err.Error())
+		}
+		p.flattener.RecordKeyOrder(rawElement)
+		var element any
+		if err := json.Unmarshal(rawElement, &element); err != nil {
+			return nil, fmt.Errorf("%s.ParseStream(): element %d: %s", p.Name, index, err.Error())
+		}
+		row, isMap := element.(map[string]any)
+		if !isMap {
+			return nil, fmt.Errorf("%s.ParseStream(): element %d is not a JSON object", p.Name, index)
+		}
+		index++
+		flattened, err := p.flattener.Flatten(row)
+		if err != nil {
+			return nil, fmt.Errorf("%s.ParseStream(): element %d: %s", p.Name, index-1, err.Error())
+		}
+		return helper.NormaliseNumbers([]data.Row{flattened})[0], nil
+	}
+	return data.NewRowFuncIterator(next, nil), nil
+}
+
+// asParser hides ParseStream, so that data.ParseStream() falls back to Parse()
+func (p *GenericJsonParser) asParser() data.Parser {
+	return &struct{ data.Parser }{p}
+}
+
+// startsWithArray peeks (without consuming) at the first non-whitespace byte of the input
+func startsWithArray(input *bufio.Reader) (bool, error) {
+	for peekSize := 1; ; peekSize++ {
+		peeked, err := input.Peek(peekSize)
+		if len(peeked) < peekSize {
+			if err == io.EOF {
+				return false, nil
+			}
+			return false, err
+		}
+		switch peeked[peekSize-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			return true, nil
+		default:
+			return false, nil
+		}
+	}
+}
+
 // We have been told (via some parameter) where the root of the []JSONObjects are.
 func (p *GenericJsonParser) getJsonObjectList(input io.Reader, rowsRoot string) ([]data.Row, error) {
 	// Use viper, so we get dotted.name.notation for free
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/jbirtley88/gremel/data"
@@ -100,3 +101,53 @@ func TestJsonGenericWhenObjectListIsNestedAndRootIsGiven(t *testing.T) {
 	assert.Equal(t, expectedHeadings, rows.Headings)
 	assert.Equal(t, 1000, len(rows.Rows), "Should be 10 rows")
 }
+
+func TestJsonGenericParseStreamTopLevelArray(t *testing.T) {
+	f, err := os.Open("../test_resources/accounts_top_level.json")
+	require.Nil(t, err)
+	defer f.Close()
+
+	p := NewGenericJsonParser(data.NewGremelContext(context.TODO()))
+	streamParser, isStreamParser := p.(data.StreamParser)
+	require.True(t, isStreamParser, "Expected the JSON parser to be a StreamParser")
+
+	it, err := streamParser.ParseStream(f)
+	require.Nil(t, err)
+	defer it.Close()
+
+	count := 0
+	for it.Next() {
+		row := it.Row()
+		assert.Contains(t, row, "username")
+		// Numbers are normalised as they are streamed
+		assert.IsType(t, int64(0), row["id"])
+		count++
+	}
+	require.Nil(t, it.Err())
+	assert.Equal(t, 1000, count)
+}
+
+func TestJsonGenericParseStreamFallsBackForNestedRows(t *testing.T) {
+	f, err := os.Open("../test_resources/accounts_nested.json")
+	require.Nil(t, err)
+	defer f.Close()
+
+	it, err := data.ParseStream(NewGenericJsonParser(data.NewGremelContext(context.TODO())), f)
+	require.Nil(t, err)
+	defer it.Close()
+
+	rows, err := data.CollectRows(it)
+	require.Nil(t, err)
+	assert.Equal(t, 1000, len(rows))
+}
+
+func TestJsonGenericParseStreamRejectsNonObjects(t *testing.T) {
+	p := NewGenericJsonParser(data.NewGremelContext(context.TODO())).(data.StreamParser)
+	it, err := p.ParseStream(strings.NewReader(`[{"id": 1}, 2]`))
+	require.Nil(t, err)
+	defer it.Close()
+
+	_, err = data.CollectRows(it)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "element 1 is not a JSON object")
+}
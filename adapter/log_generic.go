@@ -10,6 +10,9 @@ import (
 	"github.com/jbirtley88/gremel/logparse"
 )
 
+// Lines longer than this are treated as an error
+const maxLogLineLength = 1024 * 1024
+
 // GenericLogParser is a blunt but effective instrument
 //
 // It:
@@ -29,6 +32,20 @@ func NewGenericLogParser(ctx data.GremelContext) data.Parser {
 }
 
 func (p *GenericLogParser) Parse(input io.Reader) (*data.RowList, error) {
+	it, err := p.ParseStream(input)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	rows, err := data.CollectRows(it)
+	if err != nil {
+		return nil, err
+	}
+	return data.NewRowList(rows, p.GetHeadings(rows), nil), nil
+}
+
+func (p *GenericLogParser) ParseStream(input io.Reader) (data.RowIterator, error) {
 	logFormat := ""
 	if p.Ctx != nil {
 		if sn, ok := p.Ctx.Values().GetValue("log.format").(string); ok {
@@ -38,95 +55,52 @@ func (p *GenericLogParser) Parse(input io.Reader) (*data.RowList, error) {
 
 	switch logFormat {
 	case "clf":
-		return p.parseCLF(input)
+		p.columns.Add(logparse.LogCLF.Columns()...)
+		return p.streamLines(input, logparse.ParseCLFLine, false), nil
 	case "combined":
-		return p.parseCombined(input)
+		p.columns.Add(logparse.LogCombined.Columns()...)
+		return p.streamLines(input, logparse.ParseCombinedLogLine, false), nil
 	case "syslog":
-		return p.parseSyslog(input)
+		p.columns.Add(logparse.LogSyslog.Columns()...)
+		return p.streamLines(input, logparse.ParseSyslogLine, false), nil
 	}
 
-	return p.parseGeneric(input)
-}
-
-func (p *GenericLogParser) parseGeneric(input io.Reader) (*data.RowList, error) {
-	var rows []data.Row
-
-	scanner := bufio.NewScanner(input)
-	// optionally, resize scanner's capacity for lines over 64K
-	// const maxCapacity int = longLineLen  // your required line length
-	// buf := make([]byte, maxCapacity)
-	// scanner.Buffer(buf, maxCapacity)
-	for scanner.Scan() {
-		row, err := logparse.ParseLine(scanner.Text())
-		if err != nil {
-			return nil, fmt.Errorf("Parse(%s): error parsing log entry: %v", p.GetName(), err)
-		}
-		rows = append(rows, row)
-	}
-
-	if err := scanner.Err(); err != nil {
-		log.Printf("Parse(%s): error parsing log: %v", p.GetName(), err)
-	}
-	return data.NewRowList(rows, p.GetHeadings(rows), nil), nil
-}
-
-func (p *GenericLogParser) parseCLF(input io.Reader) (*data.RowList, error) {
-	var rows []data.Row
-
-	scanner := bufio.NewScanner(input)
-	// optionally, resize scanner's capacity for lines over 64K
-	// const maxCapacity int = longLineLen  // your required line length
-	// buf := make([]byte, maxCapacity)
-	// scanner.Buffer(buf, maxCapacity)
-	for scanner.Scan() {
-		row, err := logparse.ParseCLFLine(scanner.Text())
-		if err != nil {
-			log.Printf("Parse(%s): error parsing log entry: %v", p.GetName(), err)
-			continue
+	// We don't know the format, so every line has to be recognisable
+	parseLine := func(line string) (data.Row, error) {
+		row, format, err := logparse.ParseLineFormat(line)
+		if err == nil {
+			p.columns.Add(format.Columns()...)
 		}
-		rows = append(rows, row)
-	}
-
-	if err := scanner.Err(); err != nil {
-		log.Printf("Parse(%s): error parsing CLF log: %v", p.GetName(), err)
+		return row, err
 	}
-	return data.NewRowList(rows, p.GetHeadings(rows), nil), nil
+	return p.streamLines(input, parseLine, true), nil
 }
 
-func (p *GenericLogParser) parseCombined(input io.Reader) (*data.RowList, error) {
-	var rows []data.Row
-
+// streamLines parses one row per line of input.
+//
+// If strict is false, lines which can't be parsed are logged and skipped.
+// Otherwise the first bad line stops the parse with an error.
+func (p *GenericLogParser) streamLines(input io.Reader, parseLine func(string) (data.Row, error), strict bool) data.RowIterator {
 	scanner := bufio.NewScanner(input)
-	// optionally, resize scanner's capacity for lines over 64K
-	// const maxCapacity int = longLineLen  // your required line length
-	// buf := make([]byte, maxCapacity)
-	// scanner.Buffer(buf, maxCapacity)
-	for scanner.Scan() {
-		row, err := logparse.ParseCombinedLogLine(scanner.Text())
-		if err != nil {
-			log.Printf("Parse(%s): error parsing log entry: %v", p.GetName(), err)
-			continue
+	// Allow for lines over the default 64K
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineLength)
+
+	next := func() (data.Row, error) {
+		for scanner.Scan() {
+			row, err := parseLine(scanner.Text())
+			if err != nil {
+				if strict {
+					return nil, fmt.Errorf("Parse(%s): error parsing log entry: %v", p.GetName(), err)
+				}
+				log.Printf("Parse(%s): error parsing log entry: %v", p.GetName(), err)
+				continue
+			}
+			return row, nil
 		}
-		rows = append(rows, row)
-	}
-
-	if err := scanner.Err(); err != nil {
-		log.Printf("Parse(%s): error parsing CLF log: %v", p.GetName(), err)
-	}
-	return data.NewRowList(rows, p.GetHeadings(rows), nil), nil
-}
-
-func (p *GenericLogParser) parseSyslog(input io.Reader) (*data.RowList, error) {
-	var rows []data.Row
-	scanner := bufio.NewScanner(input)
-	for scanner.Scan() {
-		row, err := logparse.ParseSyslogLine(scanner.Text())
-		if err != nil {
-			log.Printf("Parse(%s): error parsing syslog line: %v", p.GetName(), err)
-			continue
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("Parse(%s): error reading log: %w", p.GetName(), err)
 		}
-		rows = append(rows, row)
+		return nil, io.EOF
 	}
-
-	return data.NewRowList(rows, p.GetHeadings(rows), nil), nil
+	return data.NewRowFuncIterator(next, nil)
 }
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"os"
-	"sort"
 	"testing"
 
 	"github.com/jbirtley88/gremel/data"
@@ -23,7 +22,8 @@ func TestCLFGeneric(t *testing.T) {
 	p := NewGenericLogParser(ctx)
 	require.NotNil(t, p)
 
-	expectedHeadings := []string{"authuser", "host", "ident", "latency", "method", "path", "proto", "size", "status", "time"}
+	// In the order they appear in a line
+	expectedHeadings := []string{"host", "ident", "authuser", "time", "method", "path", "proto", "status", "size", "latency"}
 
 	rows, err := p.Parse(f)
 	require.Nil(t, err)
@@ -31,7 +31,6 @@ func TestCLFGeneric(t *testing.T) {
 	require.NotNil(t, rows.Rows)
 	require.NotNil(t, rows.Headings)
 
-	sort.Strings(rows.Headings)
 	assert.Equal(t, expectedHeadings, rows.Headings)
 	assert.Equal(t, 1000, len(rows.Rows))
 }
@@ -47,7 +46,7 @@ func TestCombinedGeneric(t *testing.T) {
 	p := NewGenericLogParser(ctx)
 	require.NotNil(t, p)
 
-	expectedHeadings := []string{"host", "ident", "latency", "referer", "request", "size", "status", "time", "user", "useragent"}
+	expectedHeadings := []string{"host", "ident", "user", "time", "request", "status", "size", "referer", "useragent", "latency"}
 
 	rows, err := p.Parse(f)
 	require.Nil(t, err)
@@ -55,7 +54,6 @@ func TestCombinedGeneric(t *testing.T) {
 	require.NotNil(t, rows.Rows)
 	require.NotNil(t, rows.Headings)
 
-	sort.Strings(rows.Headings)
 	assert.Equal(t, expectedHeadings, rows.Headings)
 	assert.Equal(t, 1000, len(rows.Rows))
 }
@@ -71,7 +69,7 @@ func TestSyslogGeneric(t *testing.T) {
 	p := NewGenericLogParser(ctx)
 	require.NotNil(t, p)
 
-	expectedHeadings := []string{"host", "message", "pid", "process", "raw", "timestamp"}
+	expectedHeadings := []string{"timestamp", "host", "process", "pid", "message", "raw"}
 
 	rows, err := p.Parse(f)
 	require.Nil(t, err)
@@ -79,7 +77,6 @@ func TestSyslogGeneric(t *testing.T) {
 	require.NotNil(t, rows.Rows)
 	require.NotNil(t, rows.Headings)
 
-	sort.Strings(rows.Headings)
 	assert.Equal(t, expectedHeadings, rows.Headings)
 	assert.Equal(t, 10000, len(rows.Rows))
 }
@@ -99,3 +96,25 @@ another unrecognised line`,
 	require.NotNil(t, err)
 	assert.Contains(t, err.Error(), "unrecognised log format")
 }
+
+func TestGenericLogParser_ParseStreamSkipsBadLines(t *testing.T) {
+	ctx := data.NewGremelContext(context.TODO())
+	ctx.Values().SetValue("log.format", "clf")
+	p := NewGenericLogParser(ctx).(data.StreamParser)
+
+	buf := bytes.NewBuffer([]byte(
+		`205.15.228.48 - dan [12/Sep/2025:21:03:41 +0000] "POST /search HTTP/1.1" 200 3184 670
+not a log line
+205.15.228.49 - eve [12/Sep/2025:21:03:42 +0000] "GET / HTTP/1.1" 404 12 3`,
+	))
+
+	it, err := p.ParseStream(buf)
+	require.Nil(t, err)
+	defer it.Close()
+
+	rows, err := data.CollectRows(it)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(rows))
+	assert.Equal(t, "dan", rows[0]["authuser"])
+	assert.Equal(t, "eve", rows[1]["authuser"])
+}
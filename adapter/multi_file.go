@@ -0,0 +1,217 @@
+package adapter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/jbirtley88/gremel/util"
+)
+
+// SourceFileColumn is added to tables made from several files, to say which
+// file each row came from
+const SourceFileColumn = "_source_file"
+
+// CreateTableFromFiles (re)creates tableName from the union of the rows in
+// datafiles, e.g. every file in a directory or matching a glob.
+//
+// Each file gets its own parser (so e.g. a mix of CSV and JSON is fine), and
+// the files are parsed in parallel.  The schema is derived from a sample of
+// the rows from every file, so a column which is an integer in one file and
+// a float in another becomes a float, as it would within a single file.
+//
+// Nested JSON is kept as JSON text, since child tables from different files
+// can't be told apart.
+func CreateTableFromFiles(ctx data.GremelContext, database db.GremelDB, tableName string, datafiles []string) error {
+	sources := make([]inputSource, 0, len(datafiles))
+	for _, datafile := range datafiles {
+		sources = append(sources, inputSource{
+			name: datafile,
+			open: func() (io.ReadCloser, error) {
+				return os.Open(datafile)
+			},
+		})
+	}
+	err := createTableFromSources(ctx, database, tableName, sources)
+	if err != nil {
+		return fmt.Errorf("CreateTableFromFiles(%s): %w", tableName, err)
+	}
+	return nil
+}
+
+// inputSource is somewhere that rows come from, e.g. a file or an archive member
+type inputSource struct {
+	// What goes in the _source_file column.  The extension picks the parser.
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// createTableFromSources does the work for CreateTableFromFiles, for any
+// sources of data (e.g. the members of an archive)
+func createTableFromSources(ctx data.GremelContext, database db.GremelDB, tableName string, sources []inputSource) error {
+	if len(sources) == 0 {
+		return ErrNoRows
+	}
+	unionCtx, err := withoutChildTables(ctx, "mounting several files")
+	if err != nil {
+		return err
+	}
+
+	// Step 1: sample every file, and create the table from all of the samples
+	sampleSize := getIntSetting(unionCtx, "mount.sample", data.CONF_MOUNT_SAMPLE, DefaultSampleSize)
+	samples := make([][]data.Row, len(sources))
+	headings := make([][]string, len(sources))
+	err = forEachSource(sources, func(i int, rows data.RowIterator, parser data.Parser) error {
+		sample, err := data.ReadRows(rows, sampleSize)
+		if err != nil {
+			return err
+		}
+		samples[i] = sample
+		headings[i] = parser.GetHeadings(sample)
+		return nil
+	}, unionCtx)
+	if err != nil {
+		return err
+	}
+
+	var allSamples []data.Row
+	allHeadings := []string{SourceFileColumn}
+	seenHeadings := map[string]bool{SourceFileColumn: true}
+	for i, sample := range samples {
+		for _, row := range sample {
+			row[SourceFileColumn] = sources[i].name
+			allSamples = append(allSamples, row)
+		}
+		for _, heading := range headings[i] {
+			if !seenHeadings[heading] {
+				seenHeadings[heading] = true
+				allHeadings = append(allHeadings, heading)
+			}
+		}
+	}
+	if len(allSamples) == 0 {
+		return ErrNoRows
+	}
+	unionCtx.Values().SetValue(tableName+".headings", allHeadings)
+	ctx.Values().SetValue(tableName+".headings", allHeadings)
+	ctx.Values().SetValue(tableName+".tables", []string(nil))
+
+	// Step 2: parse every file again (in parallel), streaming all of the rows
+	// into the table as they arrive
+	rowChannel := make(chan data.Row, getIntSetting(unionCtx, "mount.batchsize", data.CONF_MOUNT_BATCHSIZE, db.DefaultBatchSize))
+	stop := make(chan struct{})
+	var parseErr error
+	go func() {
+		defer close(rowChannel)
+		parseErr = forEachSource(sources, func(i int, rows data.RowIterator, _ data.Parser) error {
+			for rows.Next() {
+				row := rows.Row()
+				row[SourceFileColumn] = sources[i].name
+				select {
+				case rowChannel <- row:
+				case <-stop:
+					return nil
+				}
+			}
+			return rows.Err()
+		}, unionCtx)
+	}()
+
+	allRows := data.NewRowFuncIterator(func() (data.Row, error) {
+		row, more := <-rowChannel
+		if !more {
+			// So that a file which fails to parse fails the whole load
+			if parseErr != nil {
+				return nil, parseErr
+			}
+			return nil, io.EOF
+		}
+		return row, nil
+	}, nil)
+	err = replaceTable(database, tableName, allHeadings, allSamples, withContext(unionCtx, allRows), getIntSetting(unionCtx, "mount.batchsize", data.CONF_MOUNT_BATCHSIZE, db.DefaultBatchSize))
+
+	// Let the parsers finish (or give up, if the insert failed)
+	close(stop)
+	for range rowChannel {
+	}
+	if err != nil {
+		return err
+	}
+	return parseErr
+}
+
+// forEachSource opens and parses each of the sources (several at once), and
+// hands the rows to fn along with the index of the source
+func forEachSource(sources []inputSource, fn func(i int, rows data.RowIterator, parser data.Parser) error, ctx data.GremelContext) error {
+	// The contexts aren't safe to share between goroutines, so every source gets its own
+	sourceCtxs := make([]data.GremelContext, len(sources))
+	for i := range sources {
+		sourceCtxs[i] = data.NewGremelContext(ctx.Context(), ctx.Values())
+	}
+
+	workers := min(runtime.NumCPU(), len(sources))
+	indexes := make(chan int)
+	errs := make(chan error, len(sources))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := parseSource(sourceCtxs[i], sources[i], func(rows data.RowIterator, parser data.Parser) error {
+					return fn(i, rows, parser)
+				}); err != nil {
+					errs <- fmt.Errorf("%s: %w", sources[i].name, err)
+				}
+			}
+		}()
+	}
+	for i := range sources {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	close(errs)
+	// Just the first error - the rest are often the same thing
+	return <-errs
+}
+
+func parseSource(ctx data.GremelContext, source inputSource, fn func(rows data.RowIterator, parser data.Parser) error) error {
+	f, err := source.open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	input, closer, err := Decompressed(f)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	head, err := PeekHead(input)
+	if err != nil {
+		return err
+	}
+	// Every source gets its own parser (and so its own state, e.g. the headings)
+	_, ext, _, _ := util.SplitCompressedFilename(source.name)
+	if detectArchive(ext, head) != "" {
+		return fmt.Errorf("archives can't be mounted along with other files - mount them separately")
+	}
+	parser, err := ResolveParser(ctx, "", ext, head)
+	if err != nil {
+		return err
+	}
+	if multiTableParser, isMultiTableParser := parser.(data.MultiTableParser); isMultiTableParser && multiTableParser.MultiTable() {
+		return fmt.Errorf("several tables can't be mounted from each of several files")
+	}
+	rows, err := data.ParseStream(parser, input)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	return fn(rows, parser)
+}
@@ -0,0 +1,76 @@
+package adapter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestCreateTableFromFiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"2025-01-01.csv": "id,amount\n1,10\n2,20\n",
+		"2025-01-02.csv": "id,amount\n3,30.5\n",
+		"2025-01-03.jsonl": `{"id": 4, "amount": 40, "note": "late"}` + "\n" +
+			`{"id": 5, "amount": 50, "tags": [{"name": "x"}]}` + "\n",
+	})
+	files := []string{
+		filepath.Join(dir, "2025-01-01.csv"),
+		filepath.Join(dir, "2025-01-02.csv"),
+		filepath.Join(dir, "2025-01-03.jsonl"),
+	}
+
+	ctx := data.NewGremelContext(context.Background())
+	database := db.GetGremelDB()
+	require.NoError(t, CreateTableFromFiles(ctx, database, "daily", files))
+
+	// The schemas are merged, with integers promoted to floats
+	schema, err := database.GetSchema("daily")
+	require.NoError(t, err)
+	assert.Equal(t, "REAL", schema.Type("amount"))
+	assert.Equal(t, "TEXT", schema.Type(SourceFileColumn))
+	assert.Contains(t, schema.Names(), "note")
+	assert.Equal(t, "_source_file", ctx.Values().GetValue("daily.headings").([]string)[0])
+
+	rows, _, err := database.Query("SELECT _source_file, COUNT(*) AS count, SUM(amount) AS total FROM daily GROUP BY _source_file ORDER BY _source_file")
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, files[0], rows[0][SourceFileColumn])
+	assert.Equal(t, int64(2), rows[0]["count"])
+	assert.Equal(t, 30.5, rows[1]["total"])
+	assert.Equal(t, int64(2), rows[2]["count"])
+
+	// Nested JSON is kept as JSON text, rather than split into child tables
+	rows, _, err = database.Query("SELECT tags FROM daily WHERE id = 5")
+	require.NoError(t, err)
+	assert.Equal(t, `[{"name":"x"}]`, rows[0]["tags"])
+}
+
+func TestCreateTableFromFilesReportsBadFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"good.jsonl": `{"id": 1}` + "\n",
+		"bad.jsonl":  `{"id": 2}` + "\n" + `{"id": ` + "\n",
+	})
+	ctx := data.NewGremelContext(context.Background())
+	err := CreateTableFromFiles(ctx, db.GetGremelDB(), "bad_union", []string{
+		filepath.Join(dir, "good.jsonl"),
+		filepath.Join(dir, "bad.jsonl"),
+	})
+	assert.ErrorContains(t, err, "bad.jsonl")
+
+	err = CreateTableFromFiles(ctx, db.GetGremelDB(), "no_files", nil)
+	assert.ErrorIs(t, err, ErrNoRows)
+}
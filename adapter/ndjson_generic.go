@@ -0,0 +1,106 @@
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/helper"
+)
+
+// GenericNDJsonParser reads newline-delimited JSON (a.k.a. JSON Lines), where
+// each line is a JSON object in its own right:
+//
+//	{"level": "info", "msg": "started", "pid": 1234}
+//	{"level": "error", "msg": "failed", "pid": 1234, "code": 42}
+//
+// Lines don't have to share the same keys - the table ends up with the union
+// of all of them.  Blank lines are skipped.
+//
+// Nested objects and arrays are handled in the same way as GenericJsonParser.
+type GenericNDJsonParser struct {
+	BaseAdapter
+	flattener *jsonFlattener
+}
+
+func NewGenericNDJsonParser(ctx data.GremelContext) data.Parser {
+	p := &GenericNDJsonParser{
+		BaseAdapter: *NewBaseAdapter("ndjson", ctx),
+	}
+	return p
+}
+
+func (p *GenericNDJsonParser) Parse(input io.Reader) (*data.RowList, error) {
+	it, err := p.ParseStream(input)
+	if err != nil {
+		return data.NewRowList(nil, nil, err), err
+	}
+	defer it.Close()
+
+	rows, err := data.CollectRows(it)
+	if err != nil {
+		return data.NewRowList(nil, nil, err), err
+	}
+	return data.NewRowList(rows, p.GetHeadings(rows), nil), nil
+}
+
+func (p *GenericNDJsonParser) ParseStream(input io.Reader) (data.RowIterator, error) {
+	flattener, err := newJsonFlattener(p.Ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s.ParseStream(): %s", p.Name, err.Error())
+	}
+	p.flattener = flattener
+	reader := bufio.NewReader(input)
+	lineNumber := 0
+	next := func() (data.Row, error) {
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				return nil, fmt.Errorf("%s.ParseStream(): line %d: %s", p.Name, lineNumber+1, err.Error())
+			}
+			if len(line) == 0 && err == io.EOF {
+				return nil, io.EOF
+			}
+			lineNumber++
+
+			line = bytes.TrimSpace(line)
+			if len(line) == 0 {
+				continue
+			}
+			flattener.RecordKeyOrder(line)
+			var row data.Row
+			if jsonErr := json.Unmarshal(line, &row); jsonErr != nil {
+				return nil, fmt.Errorf("%s.ParseStream(): malformed JSON object on line %d: %s", p.Name, lineNumber, jsonErr.Error())
+			}
+			if row == nil {
+				return nil, fmt.Errorf("%s.ParseStream(): line %d is not a JSON object", p.Name, lineNumber)
+			}
+			flattened, err := flattener.Flatten(row)
+			if err != nil {
+				return nil, fmt.Errorf("%s.ParseStream(): line %d: %s", p.Name, lineNumber, err.Error())
+			}
+			return helper.NormaliseNumbers([]data.Row{flattened})[0], nil
+		}
+	}
+	return data.NewRowFuncIterator(next, nil), nil
+}
+
+// ChildTables returns the tables which were split out of the rows by flattening
+func (p *GenericNDJsonParser) ChildTables() []data.ChildTable {
+	if p.flattener == nil {
+		return nil
+	}
+	return p.flattener.ChildTables()
+}
+
+// GetHeadings is the union of the keys across all of the rows, in the order
+// they first appeared
+func (p *GenericNDJsonParser) GetHeadings(rows []data.Row) []string {
+	if p.flattener == nil {
+		return p.BaseAdapter.GetHeadings(rows)
+	}
+	return p.orderedHeadings(rows, p.flattener.Columns())
+}
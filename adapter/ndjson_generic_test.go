@@ -0,0 +1,65 @@
+package adapter
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNDJsonGenericUnionsKeys(t *testing.T) {
+	f, err := os.Open("../test_resources/events.jsonl")
+	require.NoError(t, err)
+	defer f.Close()
+
+	p := NewGenericNDJsonParser(data.NewGremelContext(context.TODO()))
+	rowList, err := p.Parse(f)
+	require.NoError(t, err)
+
+	rows := rowList.Rows
+	require.Len(t, rows, 5)
+	assert.Equal(t, "started", rows[0]["msg"])
+	assert.Equal(t, int64(8080), rows[1]["port"])
+	assert.Equal(t, 1.75, rows[2]["latency"])
+	assert.Equal(t, int64(3), rows[3]["latency"])
+	assert.ElementsMatch(t, []string{"ts", "level", "msg", "pid", "port", "latency", "code"}, rowList.Headings)
+}
+
+func TestNDJsonGenericReportsMalformedLine(t *testing.T) {
+	input := `{"a": 1}
+
+{"a": 2
+{"a": 3}
+`
+	p := NewGenericNDJsonParser(data.NewGremelContext(context.TODO()))
+	_, err := p.Parse(strings.NewReader(input))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 3")
+
+	_, err = p.Parse(strings.NewReader("{\"a\": 1}\n[1, 2]\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2")
+}
+
+func TestCreateTableFromNDJson(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	database := db.GetGremelDB()
+	require.NoError(t, CreateTableFromFile(ctx, database, "events", "jsonl", "../test_resources/events.jsonl"))
+
+	schema, err := database.GetSchema("events")
+	require.NoError(t, err)
+	// pid is always an integer, latency is promoted to REAL, and code is only ever null
+	assert.Equal(t, "INTEGER", schema.Type("pid"))
+	assert.Equal(t, "REAL", schema.Type("latency"))
+	assert.NotContains(t, schema.Names(), "code")
+
+	rows, _, err := database.Query("SELECT msg FROM events WHERE latency > 2")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "upstream failed", rows[0]["msg"])
+}
@@ -0,0 +1,114 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jbirtley88/gremel/data"
+)
+
+// The built-in parsers are registered with the data.ParserRegistry, so that
+// mounting resolves parsers by format name, file extension or content type.
+//
+// In-house formats can be added the same way - call RegisterParser() (or use
+// data.GetParserRegistry() directly) from your own main package before
+// anything is mounted.
+func init() {
+	mustRegisterParser("json", NewGenericJsonParser, []string{"json"}, []string{MimeTypeJSON, "text/json"})
+	mustRegisterParser("ndjson", NewGenericNDJsonParser, []string{"ndjson", "jsonl"}, []string{MimeTypeNDJSON, "application/jsonl", "application/x-jsonlines"})
+	mustRegisterParser("csv", NewGenericCSVParser, []string{"csv"}, []string{MimeTypeCSV, "application/csv"})
+	mustRegisterParser("log", NewGenericLogParser, []string{"log"}, []string{MimeTypeLog})
+	// Not 'xls', since excelize can't read the old binary workbooks
+	mustRegisterParser("excel", NewGenericExcelParser, []string{"xlsx"}, []string{MimeTypeExcel})
+	// Only ever chosen explicitly (or for command output), since almost anything can be read this way
+	mustRegisterParser("whitespace", NewGenericWhitespaceParser, nil, nil)
+
+	// So that 'format=combined' is the same as 'format=log log.format=combined'
+	for _, logFormat := range []string{"clf", "combined", "syslog"} {
+		mustRegisterParser(logFormat, withHint(NewGenericLogParser, "log.format", logFormat), nil, nil)
+	}
+	mustRegisterParser("tsv", withHint(NewGenericCSVParser, "csv.delimiter", "tab"), []string{"tsv", "tab"}, []string{MimeTypeTSV})
+}
+
+// withHint wraps a parser constructor so that the parser always sees the
+// hint, without it leaking into the caller's context
+func withHint(constructor func(data.GremelContext) data.Parser, key string, value string) func(data.GremelContext) data.Parser {
+	return func(ctx data.GremelContext) data.Parser {
+		hintedCtx := data.NewGremelContext(ctx.Context(), ctx.Values())
+		hintedCtx.Values().SetValue(key, value)
+		return constructor(hintedCtx)
+	}
+}
+
+// RegisterParser registers a GremelContext-aware parser constructor under
+// name, along with the file extensions and MIME types which it handles
+func RegisterParser(name string, constructor func(data.GremelContext) data.Parser, extensions []string, mimeTypes []string) error {
+	registry := data.GetParserRegistry()
+	err := registry.Register(name, func(_ string, ctx context.Context) data.Parser {
+		return constructor(data.ToGremelContext(ctx))
+	})
+	if err != nil {
+		return fmt.Errorf("RegisterParser(%s): %w", name, err)
+	}
+	for _, extension := range extensions {
+		if err := registry.RegisterExtension(extension, name); err != nil {
+			return fmt.Errorf("RegisterParser(%s): %w", name, err)
+		}
+	}
+	for _, mimeType := range mimeTypes {
+		if err := registry.RegisterMimeType(mimeType, name); err != nil {
+			return fmt.Errorf("RegisterParser(%s): %w", name, err)
+		}
+	}
+	return nil
+}
+
+func mustRegisterParser(name string, constructor func(data.GremelContext) data.Parser, extensions []string, mimeTypes []string) {
+	if err := RegisterParser(name, constructor, extensions, mimeTypes); err != nil {
+		panic(err)
+	}
+}
+
+// ResolveParser picks the parser for an input, in order of preference:
+//
+//  1. an explicit 'format' value in the context (a parser name or an extension)
+//  2. the content type we were told about (e.g. the HTTP Content-Type header)
+//  3. the file extension
+//  4. the content type sniffed from the first few bytes of the input
+//
+// contentType and extension may be empty.  Generic content types such as
+// 'text/plain' or 'application/octet-stream' are not registered to any
+// parser, so they simply fall through to the next step.
+func ResolveParser(ctx data.GremelContext, contentType string, extension string, head []byte) (data.Parser, error) {
+	registry := data.GetParserRegistry()
+
+	if format := ctx.Values().GetString("format"); format != "" {
+		if parser := registry.Get(format, ctx); !data.IsParserError(parser) {
+			return parser, nil
+		}
+		if parser := registry.GetByExtension(format, ctx); !data.IsParserError(parser) {
+			return parser, nil
+		}
+		return nil, fmt.Errorf("ResolveParser(): unknown format '%s'", format)
+	}
+
+	if contentType != "" {
+		if parser := registry.GetByMimeType(contentType, ctx); !data.IsParserError(parser) {
+			return parser, nil
+		}
+	}
+
+	if extension != "" {
+		if parser := registry.GetByExtension(extension, ctx); !data.IsParserError(parser) {
+			return parser, nil
+		}
+	}
+
+	if len(head) > 0 {
+		if parser := registry.GetByMimeType(SniffContentType(head), ctx); !data.IsParserError(parser) {
+			return parser, nil
+		}
+	}
+
+	return nil, fmt.Errorf("ResolveParser(): unsupported file type: %s", extension)
+}
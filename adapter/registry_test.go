@@ -0,0 +1,94 @@
+package adapter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffContentType(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"json array", "  [{\"a\": 1}]", MimeTypeJSON},
+		{"json object", "{\"a\": 1}", MimeTypeJSON},
+		{"compact json document", "{\"a\": [{\"b\": 1}]}\n", MimeTypeJSON},
+		{"ndjson", "{\"a\": 1}\n{\"a\": 2, \"b\": true}\n{\"a\": 3", MimeTypeNDJSON},
+		{"csv", "id,name\n1,alice\n2,bob\n", MimeTypeCSV},
+		{"clf", `165.23.106.237 - alice [04/Sep/2025:19:12:36 -0700] "GET / HTTP/1.1" 200 11281` + "\n", MimeTypeLog},
+		{"excel", "PK\x03\x04....[Content_Types].xml", MimeTypeExcel},
+		{"zip", "PK\x03\x04....something.txt", MimeTypeZip},
+		{"text", "hello world\n", MimeTypeText},
+		{"empty", "", MimeTypeText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SniffContentType([]byte(tt.head)))
+		})
+	}
+}
+
+func TestResolveParser(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+
+	parser, err := ResolveParser(ctx, "", "CSV", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "csv", parser.GetName())
+
+	parser, err = ResolveParser(ctx, "", "xlsx", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "excel", parser.GetName())
+	_, err = ResolveParser(ctx, "", "xls", nil)
+	assert.ErrorContains(t, err, "unsupported file type: xls")
+
+	// Unknown extension falls back to sniffing
+	parser, err = ResolveParser(ctx, "", "txt", []byte(`[{"a": 1}]`))
+	require.NoError(t, err)
+	assert.Equal(t, "json", parser.GetName())
+
+	// Content-Type beats the extension, but generic types are ignored
+	parser, err = ResolveParser(ctx, "text/csv; charset=utf-8", "json", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "csv", parser.GetName())
+
+	parser, err = ResolveParser(ctx, "application/octet-stream", "json", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "json", parser.GetName())
+
+	_, err = ResolveParser(ctx, "", "txt", []byte("hello world\n"))
+	assert.ErrorContains(t, err, "unsupported file type: txt")
+
+	// An explicit format trumps everything else
+	ctx.Values().SetValue("format", "log")
+	parser, err = ResolveParser(ctx, "", "json", []byte(`[{"a": 1}]`))
+	require.NoError(t, err)
+	assert.Equal(t, "log", parser.GetName())
+
+	ctx.Values().SetValue("format", "nope")
+	_, err = ResolveParser(ctx, "", "json", nil)
+	assert.ErrorContains(t, err, "unknown format 'nope'")
+}
+
+func TestCreateTableFromFile_NoExtension(t *testing.T) {
+	source, err := os.ReadFile("../test_resources/people.csv")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "people")
+	require.NoError(t, os.WriteFile(path, source, 0644))
+
+	ctx := data.NewGremelContext(context.Background())
+	database := db.GetGremelDB()
+	require.NoError(t, CreateTableFromFile(ctx, database, "sniffed_people", "", path))
+
+	rows, _, err := database.Query("SELECT COUNT(*) AS n FROM sniffed_people")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.EqualValues(t, 1000, rows[0]["n"])
+}
@@ -0,0 +1,123 @@
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/jbirtley88/gremel/logparse"
+)
+
+const (
+	MimeTypeJSON   = "application/json"
+	MimeTypeNDJSON = "application/x-ndjson"
+	MimeTypeCSV    = "text/csv"
+	MimeTypeTSV    = "text/tab-separated-values"
+	MimeTypeLog    = "text/x-log"
+	MimeTypeExcel  = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	MimeTypeZip    = "application/zip"
+	MimeTypeText   = "text/plain"
+)
+
+// SniffSize is how much of the input is examined by SniffContentType
+const SniffSize = 4096
+
+// PeekHead returns (without consuming) up to SniffSize bytes from the start of the input
+func PeekHead(input *bufio.Reader) ([]byte, error) {
+	head, err := input.Peek(SniffSize)
+	if err == io.EOF || err == bufio.ErrBufferFull {
+		err = nil
+	}
+	return head, err
+}
+
+// SniffContentType takes an educated guess at the MIME type of the content,
+// based on the first few bytes of it.
+//
+// It only knows about the formats we can parse, and falls back to text/plain.
+func SniffContentType(head []byte) string {
+	// Excel files are zip files, which have a known file layout
+	if bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+		if bytes.Contains(head, []byte("[Content_Types].xml")) || bytes.Contains(head, []byte("xl/")) {
+			return MimeTypeExcel
+		}
+		return MimeTypeZip
+	}
+
+	trimmed := bytes.TrimLeft(head, " \t\r\n\uFEFF")
+	if len(trimmed) == 0 {
+		return MimeTypeText
+	}
+	lines := completeLines(trimmed)
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		if looksLikeNDJSON(lines) {
+			return MimeTypeNDJSON
+		}
+		return MimeTypeJSON
+	}
+	if len(lines) == 0 {
+		return MimeTypeText
+	}
+	if logparse.DetectLogFormat(lines[0]) != logparse.LogUnknown {
+		return MimeTypeLog
+	}
+	if looksLikeCSV(lines) {
+		return MimeTypeCSV
+	}
+	return MimeTypeText
+}
+
+// completeLines splits the head into lines, dropping the last line if it was
+// truncated (unless it is the only line)
+func completeLines(head []byte) []string {
+	text := string(head)
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+	var nonEmpty []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			nonEmpty = append(nonEmpty, line)
+		}
+	}
+	return nonEmpty
+}
+
+// NDJSON means (at least) two lines, each of which is a JSON object.
+// A single line could just as easily be a compact JSON document.
+func looksLikeNDJSON(lines []string) bool {
+	if len(lines) < 2 {
+		return false
+	}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") || !json.Valid([]byte(line)) {
+			return false
+		}
+	}
+	return true
+}
+
+// CSV means every line has the same (non-zero) number of delimiters
+func looksLikeCSV(lines []string) bool {
+	for _, delimiter := range candidateDelimiters {
+		count := countUnquoted(lines[0], delimiter)
+		if count == 0 {
+			continue
+		}
+		consistent := true
+		for _, line := range lines[1:] {
+			if countUnquoted(line, delimiter) != count {
+				consistent = false
+				break
+			}
+		}
+		if consistent {
+			return true
+		}
+	}
+	return false
+}
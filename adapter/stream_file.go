@@ -0,0 +1,121 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/util"
+)
+
+// ErrNotStreamable is returned by StreamFile for files which have to be
+// loaded into a table to be read, e.g. archives or a workbook with
+// excel.sheets=all
+var ErrNotStreamable = errors.New("can't be read a row at a time")
+
+// FileRows are the rows parsed from a file by StreamFile
+type FileRows struct {
+	data.RowIterator
+	// The column names, in the order the parser gives them (e.g. the order of
+	// the columns in a CSV file)
+	Headings []string
+
+	closers []io.Closer
+}
+
+// Close closes the iterator and the file
+func (r *FileRows) Close() error {
+	err := r.RowIterator.Close()
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if closeErr := r.closers[i].Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// StreamFile parses a file (decompressing it if need be) a row at a time,
+// without putting it into a table, e.g. for converting it to another format.
+//
+// The headings are worked out from the first 'mount.sample' rows, so a column
+// which only appears after that won't be in them.  Nested JSON is kept as
+// JSON text, since there are no tables for arrays to be split out into.
+func StreamFile(ctx data.GremelContext, datafile string) (*FileRows, error) {
+	streamCtx, err := withoutChildTables(ctx, "reading a file a row at a time")
+	if err != nil {
+		return nil, fmt.Errorf("StreamFile(%s): %w", datafile, err)
+	}
+	_, fileType, _, err := util.SplitCompressedFilename(datafile)
+	if err != nil && !errors.Is(err, util.ErrNoExtension) {
+		return nil, fmt.Errorf("StreamFile(%s): %w", datafile, err)
+	}
+
+	f, err := os.Open(datafile)
+	if err != nil {
+		return nil, fmt.Errorf("StreamFile(%s): %w", datafile, err)
+	}
+	rows := &FileRows{closers: []io.Closer{f}}
+	fail := func(err error) (*FileRows, error) {
+		for i := len(rows.closers) - 1; i >= 0; i-- {
+			rows.closers[i].Close()
+		}
+		return nil, fmt.Errorf("StreamFile(%s): %w", datafile, err)
+	}
+
+	input, closer, err := Decompressed(f)
+	if err != nil {
+		return fail(err)
+	}
+	rows.closers = append(rows.closers, closer)
+	head, err := PeekHead(input)
+	if err != nil {
+		return fail(err)
+	}
+	if detectArchive(fileType, head) != "" {
+		return fail(ErrNotStreamable)
+	}
+	parser, err := ResolveParser(streamCtx, "", fileType, head)
+	if err != nil {
+		return fail(err)
+	}
+	if multiTableParser, isMultiTableParser := parser.(data.MultiTableParser); isMultiTableParser && multiTableParser.MultiTable() {
+		return fail(ErrNotStreamable)
+	}
+
+	it, err := data.ParseStream(parser, input)
+	if err != nil {
+		return fail(err)
+	}
+	sample, err := data.ReadRows(it, getIntSetting(streamCtx, "mount.sample", data.CONF_MOUNT_SAMPLE, DefaultSampleSize))
+	if err != nil {
+		it.Close()
+		return fail(err)
+	}
+	rows.Headings = sampleHeadings(parser, sample)
+	rows.RowIterator = data.NewMultiRowIterator(data.NewRowSliceIterator(sample), it)
+	return rows, nil
+}
+
+// sampleHeadings are the parser's headings, followed by any other columns
+// (in name order) which turn up in the sample
+func sampleHeadings(parser data.Parser, sample []data.Row) []string {
+	headings := parser.GetHeadings(sample)
+	seen := make(map[string]bool, len(headings))
+	for _, heading := range headings {
+		seen[heading] = true
+	}
+	var extra []string
+	for _, row := range sample {
+		for column := range row {
+			if !seen[column] {
+				seen[column] = true
+				extra = append(extra, column)
+			}
+		}
+	}
+	sort.Strings(extra)
+	return append(headings, extra...)
+}
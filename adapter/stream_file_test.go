@@ -0,0 +1,41 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamFile(t *testing.T) {
+	rows, err := StreamFile(data.NewGremelContext(context.TODO()), "../test_resources/people_10.csv.bz2")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	assert.ElementsMatch(t, []string{"id", "fullname", "email", "gender", "ip_address"}, rows.Headings)
+	count := 0
+	for rows.Next() {
+		count++
+		assert.IsType(t, int64(0), rows.Row()["id"])
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, 10, count)
+}
+
+func TestStreamFileNotStreamable(t *testing.T) {
+	_, err := StreamFile(data.NewGremelContext(context.TODO()), "../test_resources/logs.zip")
+	assert.ErrorIs(t, err, ErrNotStreamable)
+
+	ctx := data.NewGremelContext(context.TODO())
+	ctx.Values().SetValue("excel.sheets", "all")
+	_, err = StreamFile(ctx, "../test_resources/accounts_multiple_sheets.xlsx")
+	assert.ErrorIs(t, err, ErrNotStreamable)
+
+	// Child tables would be lost
+	ctx = data.NewGremelContext(context.TODO())
+	ctx.Values().SetValue("json.nested", JsonNestedFlatten)
+	_, err = StreamFile(ctx, "../test_resources/orders.json")
+	assert.Error(t, err)
+}
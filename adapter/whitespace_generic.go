@@ -0,0 +1,113 @@
+package adapter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jbirtley88/gremel/data"
+)
+
+// GenericWhitespaceParser reads columns separated by runs of whitespace, as
+// printed by commands such as 'ps', 'df' or 'ls -l'
+//
+// It:
+//
+//   - uses the first line as headings (unless 'whitespace.header' is false,
+//     in which case the columns are named col1..N)
+//   - makes the headings safe to use as column names, e.g. '%CPU' becomes '_CPU'
+//   - puts the rest of a line which has more fields than there are headings
+//     into the last column, since that is usually a command line or file name
+//     with spaces in it
+//
+// Blank lines are skipped.
+type GenericWhitespaceParser struct {
+	BaseAdapter
+}
+
+func NewGenericWhitespaceParser(ctx data.GremelContext) data.Parser {
+	p := &GenericWhitespaceParser{
+		BaseAdapter: *NewBaseAdapter("whitespace", ctx),
+	}
+	return p
+}
+
+func (p *GenericWhitespaceParser) Parse(input io.Reader) (*data.RowList, error) {
+	it, err := p.ParseStream(input)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	rows, err := data.CollectRows(it)
+	if err != nil {
+		return nil, err
+	}
+	return data.NewRowList(rows, p.GetHeadings(rows), nil), nil
+}
+
+func (p *GenericWhitespaceParser) ParseStream(input io.Reader) (data.RowIterator, error) {
+	header := true
+	if p.Ctx != nil && p.Ctx.Values().GetValue("whitespace.header") != nil {
+		header = p.Ctx.Values().GetBool("whitespace.header")
+	}
+
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, SniffSize), maxLogLineLength)
+	nextFields := func() ([]string, error) {
+		for scanner.Scan() {
+			if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+				return fields, nil
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("Parse(%s): read error: %w", p.GetName(), err)
+		}
+		return nil, io.EOF
+	}
+
+	var headings []string
+	if header {
+		fields, err := nextFields()
+		if err == io.EOF {
+			return data.NewRowSliceIterator(nil), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for i, field := range fields {
+			heading := SanitiseTableName(field)
+			if heading == "" || seen[heading] {
+				heading = fmt.Sprintf("col%d", i+1)
+			}
+			seen[heading] = true
+			headings = append(headings, heading)
+		}
+		p.columns.Add(headings...)
+	}
+
+	next := func() (data.Row, error) {
+		fields, err := nextFields()
+		if err != nil {
+			return nil, err
+		}
+		row := make(data.Row)
+		for i, field := range fields {
+			switch {
+			case len(headings) == 0:
+				column := fmt.Sprintf("col%d", i+1)
+				p.columns.Add(column)
+				row[column] = data.InferValue(field)
+			case i < len(headings)-1:
+				row[headings[i]] = data.InferValue(field)
+			default:
+				row[headings[len(headings)-1]] = data.InferValue(strings.Join(fields[i:], " "))
+				return row, nil
+			}
+		}
+		return row, nil
+	}
+	return data.NewRowFuncIterator(next, nil), nil
+}
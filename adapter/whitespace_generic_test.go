@@ -0,0 +1,54 @@
+package adapter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhitespaceGenericWithHeader(t *testing.T) {
+	input := `  PID COMMAND         %CPU  ARGS
+    1 systemd          0.0  /sbin/init splash
+
+  812 sshd             1.5  sshd: /usr/sbin/sshd -D
+`
+	p := NewGenericWhitespaceParser(data.NewGremelContext(context.TODO()))
+	rowList, err := p.Parse(strings.NewReader(input))
+	require.NoError(t, err)
+
+	rows := rowList.Rows
+	require.Len(t, rows, 2)
+	assert.Equal(t, int64(1), rows[0]["PID"])
+	assert.Equal(t, "systemd", rows[0]["COMMAND"])
+	assert.Equal(t, 1.5, rows[1]["_CPU"])
+	// The leftover fields end up in the last column
+	assert.Equal(t, "/sbin/init splash", rows[0]["ARGS"])
+	assert.Equal(t, "sshd: /usr/sbin/sshd -D", rows[1]["ARGS"])
+}
+
+func TestWhitespaceGenericWithoutHeader(t *testing.T) {
+	ctx := data.NewGremelContext(context.TODO())
+	ctx.Values().SetValue("whitespace.header", false)
+	p := NewGenericWhitespaceParser(ctx)
+	rowList, err := p.Parse(strings.NewReader("a 1\nb 2 extra\n"))
+	require.NoError(t, err)
+
+	rows := rowList.Rows
+	require.Len(t, rows, 2)
+	assert.Equal(t, "a", rows[0]["col1"])
+	assert.Equal(t, int64(1), rows[0]["col2"])
+	assert.Equal(t, "extra", rows[1]["col3"])
+}
+
+func TestWhitespaceGenericDuplicateHeadings(t *testing.T) {
+	p := NewGenericWhitespaceParser(data.NewGremelContext(context.TODO()))
+	rowList, err := p.Parse(strings.NewReader("name name %\nx y z\n"))
+	require.NoError(t, err)
+
+	require.Len(t, rowList.Rows, 1)
+	assert.Equal(t, data.Row{"name": "x", "col2": "y", "_": "z"}, rowList.Rows[0])
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sort"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jbirtley88/gremel/apiimpl"
 	"github.com/jbirtley88/gremel/data"
+	"github.com/spf13/viper"
 )
 
-// PUT /api/v1/mount ? name=xxx & source=yyy
+// PUT /api/v1/mount ? name=xxx & source=yyy [& key=value ...]
+//
+// Any other query parameters are options for this mount, e.g. format=csv
 func MountTable(c *gin.Context) {
 	table := c.Request.URL.Query().Get("table")
 	source := c.Request.URL.Query().Get("source")
@@ -18,12 +22,27 @@ func MountTable(c *gin.Context) {
 		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("table and source query parameters are required"))
 		return
 	}
+	if apiimpl.IsStdinSource(source) {
+		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("stdin can't be mounted through the REST API"))
+		return
+	}
+	// Anyone who can reach the API could run anything they like
+	if apiimpl.IsExecSource(source) && !viper.GetBool(data.CONF_API_EXEC) {
+		c.AbortWithError(http.StatusForbidden, fmt.Errorf("exec: sources are disabled for the REST API (see %s)", data.CONF_API_EXEC))
+		return
+	}
 
 	ctx := data.NewGremelContext(context.Background())
 	if gremelContext, _ := c.Get("gremelcontext"); gremelContext != nil {
 		ctx = gremelContext.(data.GremelContext)
 	}
-	err := apiimpl.Mount(ctx, table, source)
+	options := make(map[string]string)
+	for key, values := range c.Request.URL.Query() {
+		if key != "table" && key != "source" && len(values) > 0 {
+			options[key] = values[len(values)-1]
+		}
+	}
+	err := apiimpl.MountWithOptions(ctx, table, source, options)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("error mounting table: %v", err))
 		return
@@ -42,12 +61,17 @@ func GetMount(c *gin.Context) {
 	table := c.Request.URL.Query().Get("table")
 	tables := []string{}
 	if table == "" {
-		var err error
-		tables, err = apiimpl.GetTables(ctx)
+		// Every mount, including those (e.g. 'book' for 'book.xlsx' with
+		// excel.sheets=all) which are not tables in their own right
+		allMounts, err := apiimpl.GetMount(ctx, "")
 		if err != nil {
-			c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("error getting tables: %v", err))
+			c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("error getting mounts: %v", err))
 			return
 		}
+		for mountName := range allMounts {
+			tables = append(tables, mountName)
+		}
+		sort.Strings(tables)
 	} else {
 		tables = append(tables, table)
 	}
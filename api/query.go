@@ -2,12 +2,14 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jbirtley88/gremel/apiimpl"
 	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
 )
 
 // GET /api/v1/query ? q=xxxxxx
@@ -24,6 +26,14 @@ func Query(c *gin.Context) {
 	}
 
 	rows, headings, err := apiimpl.Query(ctx, c.Request.URL.Query().Get("q"))
+	if errors.Is(err, apiimpl.ErrQueryTimeout) {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": fmt.Sprintf("error executing query: %v", err)})
+		return
+	}
+	if errors.Is(err, db.ErrReadOnly) {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("error executing query: %v", err)})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error executing query: %v", err)})
 		return
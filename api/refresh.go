@@ -0,0 +1,40 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jbirtley88/gremel/apiimpl"
+	"github.com/jbirtley88/gremel/data"
+)
+
+// POST /api/v1/refresh [? table=xxx]
+//
+// Re-reads the table from the source it was mounted from, or every mount if
+// no table is given
+func Refresh(c *gin.Context) {
+	ctx := data.NewGremelContext(context.Background())
+	if gremelContext, _ := c.Get("gremelcontext"); gremelContext != nil {
+		ctx = gremelContext.(data.GremelContext)
+	}
+
+	table := c.Request.URL.Query().Get("table")
+	if table == "" {
+		tables, err := apiimpl.RefreshAll(ctx)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("error refreshing tables: %v", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": fmt.Sprintf("refreshed %d mounts", len(tables)), "tables": tables})
+		return
+	}
+
+	err := apiimpl.Refresh(ctx, table)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("error refreshing table: %v", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": fmt.Sprintf("refreshed '%s'", table)})
+}
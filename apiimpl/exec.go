@@ -0,0 +1,119 @@
+package apiimpl
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jbirtley88/gremel/adapter"
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/jbirtley88/gremel/helper"
+	"github.com/spf13/viper"
+)
+
+// ExecPrefix marks a source which is the output of a command, e.g.
+// 'exec:ps -eo pid,comm,%cpu'
+const ExecPrefix = "exec:"
+
+// DefaultExecTimeout is how long a command may run for, unless 'exec.timeout'
+// or 'config.exec.timeout' say otherwise
+const DefaultExecTimeout = 60 * time.Second
+
+// IsExecSource reports whether source is a command to run, rather than a file or URL
+func IsExecSource(source string) bool {
+	return strings.HasPrefix(source, ExecPrefix)
+}
+
+// MountExec runs the command in an 'exec:COMMAND' source through the shell
+// (so pipelines work), and mounts its output.
+//
+// The parser is picked in the usual way (e.g. 'format=csv'), falling back to
+// whitespace-separated columns if the output isn't recognisable.
+//
+// The exit code and stderr are recorded against the mount ('exit_code' and
+// 'stderr'), and a command which fails is only an error if there was no
+// output to mount.
+func MountExec(ctx data.GremelContext, name string, source string) error {
+	command := strings.TrimSpace(strings.TrimPrefix(source, ExecPrefix))
+	if command == "" {
+		return fmt.Errorf("MountExec(%s): no command given", source)
+	}
+	timeout, err := execTimeout(ctx)
+	if err != nil {
+		return fmt.Errorf("MountExec(%s): %w", source, err)
+	}
+
+	result, err := helper.GetCommandOutput(shellCommand(command), timeout)
+	if err != nil {
+		return fmt.Errorf("MountExec(%s): %w", source, err)
+	}
+	if result.IsTimeout {
+		return fmt.Errorf("MountExec(%s): timed out after %s", source, timeout)
+	}
+
+	body, closer, err := adapter.Decompressed(strings.NewReader(result.Stdout + "\n"))
+	if err != nil {
+		return fmt.Errorf("MountExec(%s): %w", source, err)
+	}
+	defer closer.Close()
+	head, err := adapter.PeekHead(body)
+	if err != nil {
+		return fmt.Errorf("MountExec(%s): %w", source, err)
+	}
+	parser, err := adapter.ResolveParser(ctx, "", "", head)
+	if err != nil && ctx.Values().GetString("format") == "" {
+		parser, err = adapter.NewGenericWhitespaceParser(ctx), nil
+	}
+	if err != nil {
+		return fmt.Errorf("MountExec(%s): %w", source, err)
+	}
+
+	database := db.GetGremelDB()
+	err = adapter.CreateTableFromReader(ctx, database, name, body, parser)
+	if err != nil {
+		if result.ExitCode != 0 {
+			return fmt.Errorf("MountExec(%s): exit code %d: %s: %w", source, result.ExitCode, result.Stderr, err)
+		}
+		return fmt.Errorf("MountExec(%s): %w", source, err)
+	}
+	err = database.Mount(name, source)
+	if err != nil {
+		return fmt.Errorf("MountExec(%s): %w", source, err)
+	}
+	err = mountChildTables(ctx, database, name, source)
+	if err != nil {
+		return fmt.Errorf("MountExec(%s): %w", source, err)
+	}
+	err = database.SetMountInfo(name, "exit_code", int64(result.ExitCode))
+	if err != nil {
+		return fmt.Errorf("MountExec(%s): %w", source, err)
+	}
+	err = database.SetMountInfo(name, "stderr", result.Stderr)
+	if err != nil {
+		return fmt.Errorf("MountExec(%s): %w", source, err)
+	}
+	return nil
+}
+
+func execTimeout(ctx data.GremelContext) (time.Duration, error) {
+	for _, setting := range []string{ctx.Values().GetString("exec.timeout"), viper.GetString(data.CONF_EXEC_TIMEOUT)} {
+		if setting == "" {
+			continue
+		}
+		timeout, err := time.ParseDuration(setting)
+		if err != nil || timeout <= 0 {
+			return 0, fmt.Errorf("invalid exec.timeout '%s'", setting)
+		}
+		return timeout, nil
+	}
+	return DefaultExecTimeout, nil
+}
+
+func shellCommand(command string) []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C", command}
+	}
+	return []string{"sh", "-c", command}
+}
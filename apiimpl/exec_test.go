@@ -0,0 +1,66 @@
+package apiimpl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMountExecWhitespaceOutput(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	require.NoError(t, Mount(ctx, "exec_procs", `exec:printf 'PID COMMAND\n1 init\n42 sshd -D\n'`))
+
+	rows, _, err := Query(ctx, "SELECT PID, COMMAND FROM exec_procs ORDER BY PID")
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, int64(42), rows[1]["PID"])
+	assert.Equal(t, "sshd -D", rows[1]["COMMAND"])
+
+	mountInfo, err := GetMount(ctx, "exec_procs")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), mountInfo["exit_code"])
+	assert.Equal(t, "", mountInfo["stderr"])
+}
+
+func TestMountExecWithFormat(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	// A pipeline, as the command goes through the shell
+	source := `exec:printf 'name,age\nAlice,30\nBob,25\n' | sort -r`
+	require.NoError(t, MountWithOptions(ctx, "exec_csv", source, map[string]string{"format": "csv", "refresh.ttl": "1h"}))
+	assert.Equal(t, int64(2), countRows(t, ctx, "exec_csv"))
+}
+
+func TestMountExecRecordsFailure(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	// Output and a non-zero exit is still mounted...
+	require.NoError(t, Mount(ctx, "exec_partial", `exec:printf 'a b\n1 2\n'; echo oops >&2; exit 3`))
+	mountInfo, err := GetMount(ctx, "exec_partial")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), mountInfo["exit_code"])
+	assert.Equal(t, "oops", mountInfo["stderr"])
+
+	// ...but no output is an error which says why
+	err = Mount(ctx, "exec_failed", `exec:echo 'no such thing' >&2; exit 2`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exit code 2")
+	assert.Contains(t, err.Error(), "no such thing")
+}
+
+func TestMountExecTimeout(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	err := MountWithOptions(ctx, "exec_slow", "exec:sleep 5", map[string]string{"exec.timeout": "100ms"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+
+	err = MountWithOptions(ctx, "exec_slow", "exec:sleep 5", map[string]string{"exec.timeout": "soon"})
+	require.Error(t, err)
+}
+
+func TestMountExecRejectsFollowAndRefreshCheck(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	assert.Error(t, MountWithOptions(ctx, "exec_follow", "exec:echo a", map[string]string{Follow: "true"}))
+	assert.Error(t, MountWithOptions(ctx, "exec_check", "exec:echo a", map[string]string{RefreshCheck: "hash"}))
+}
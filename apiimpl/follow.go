@@ -0,0 +1,199 @@
+package apiimpl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jbirtley88/gremel/adapter"
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/jbirtley88/gremel/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// Mount options for following a log file (like 'tail -f'):
+//
+//   - follow=true appends any new lines on '.refresh', and before a query touches the table
+//   - follow.interval=5s also appends new lines in the background, every interval
+const (
+	Follow         = "follow"
+	FollowInterval = "follow.interval"
+)
+
+// Extra mount info recorded for followed files
+const (
+	mountInfoFollowInode  = "follow.inode"
+	mountInfoFollowOffset = "follow.offset"
+)
+
+// Only one catch-up at a time, so that the same lines can't be appended twice
+var followMutex sync.Mutex
+
+// The background followers, by table name
+var (
+	followersMutex sync.Mutex
+	followers      = make(map[string]*follower)
+)
+
+type follower struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func isFollowed(options map[string]string) bool {
+	following, _ := strconv.ParseBool(options[Follow])
+	return following
+}
+
+// validateFollowOptions catches bad follow options before anything is mounted
+func validateFollowOptions(source string, options map[string]string) error {
+	if options[Follow] != "" {
+		if _, err := strconv.ParseBool(options[Follow]); err != nil {
+			return fmt.Errorf("invalid %s '%s': must be true or false", Follow, options[Follow])
+		}
+	}
+	if interval := options[FollowInterval]; interval != "" {
+		if _, err := time.ParseDuration(interval); err != nil {
+			return fmt.Errorf("invalid %s '%s': %w", FollowInterval, interval, err)
+		}
+		if !isFollowed(options) {
+			return fmt.Errorf("%s needs %s=true", FollowInterval, Follow)
+		}
+	}
+	if isFollowed(options) {
+		if isHttpSource(source) || IsExecSource(source) || IsStdinSource(source) {
+			return fmt.Errorf("only files can be followed")
+		}
+		if fi, err := os.Stat(source); (err == nil && fi.IsDir()) || (err != nil && isGlob(source)) {
+			return fmt.Errorf("only single files can be followed, not directories or globs")
+		}
+	}
+	return nil
+}
+
+// mountFollowedFile is MountFile for a file which is being followed
+func mountFollowedFile(ctx data.GremelContext, database db.GremelDB, name string, path string, ext string) error {
+	state, err := adapter.CreateTableFromFollowedFile(ctx, database, name, ext, path)
+	if err != nil {
+		return err
+	}
+	if err := database.Mount(name, path); err != nil {
+		return err
+	}
+	if err := mountChildTables(ctx, database, name, path); err != nil {
+		return err
+	}
+	return recordFollowState(database, name, state)
+}
+
+// CatchUp appends any lines which have been written to a followed file since
+// it was last read, and returns the number of rows added
+func CatchUp(ctx data.GremelContext, name string) (int64, error) {
+	followMutex.Lock()
+	defer followMutex.Unlock()
+
+	database := db.GetGremelDB()
+	mountInfo, err := database.GetMount(name)
+	if err != nil {
+		return 0, fmt.Errorf("CatchUp(%s): %w", name, err)
+	}
+	options, _ := mountInfo["options"].(map[string]string)
+	if !isFollowed(options) {
+		return 0, fmt.Errorf("CatchUp(%s): not mounted with %s=true", name, Follow)
+	}
+	source, _ := mountInfo[name].(string)
+	_, ext, err := util.SplitFilename(source)
+	if err != nil && !errors.Is(err, util.ErrNoExtension) {
+		return 0, fmt.Errorf("CatchUp(%s): %w", name, err)
+	}
+
+	followCtx := data.NewGremelContext(ctx.Context(), ctx.Values())
+	for key, value := range options {
+		followCtx.Values().SetValue(key, value)
+	}
+	state := adapter.FollowState{
+		Inode:  uint64(toInt64(mountInfo[mountInfoFollowInode])),
+		Offset: toInt64(mountInfo[mountInfoFollowOffset]),
+	}
+	newState, appended, err := adapter.AppendFromFollowedFile(followCtx, database, name, ext, source, state)
+	if newState != state {
+		if err := recordFollowState(database, name, newState); err != nil {
+			return appended, fmt.Errorf("CatchUp(%s): %w", name, err)
+		}
+	}
+	if err != nil {
+		return appended, fmt.Errorf("CatchUp(%s): %w", name, err)
+	}
+	return appended, nil
+}
+
+func recordFollowState(database db.GremelDB, name string, state adapter.FollowState) error {
+	if err := database.SetMountInfo(name, mountInfoFollowInode, int64(state.Inode)); err != nil {
+		return err
+	}
+	return database.SetMountInfo(name, mountInfoFollowOffset, state.Offset)
+}
+
+// startFollower starts catching up in the background every follow.interval,
+// until the table is remounted
+func startFollower(name string, options map[string]string) {
+	interval, err := time.ParseDuration(options[FollowInterval])
+	if err != nil || interval <= 0 || !isFollowed(options) {
+		return
+	}
+
+	followersMutex.Lock()
+	defer followersMutex.Unlock()
+	if _, running := followers[name]; running {
+		return
+	}
+	followCtx, cancel := context.WithCancel(context.Background())
+	f := &follower{cancel: cancel, done: make(chan struct{})}
+	followers[name] = f
+
+	go func() {
+		defer close(f.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-followCtx.Done():
+				return
+			case <-ticker.C:
+				if _, err := CatchUp(data.NewGremelContext(followCtx), name); err != nil {
+					log.Warnf("could not follow '%s': %v", name, err)
+				}
+			}
+		}
+	}()
+}
+
+// stopFollower stops the background follower (if any), and waits for it to finish
+func stopFollower(name string) {
+	followersMutex.Lock()
+	f, running := followers[name]
+	delete(followers, name)
+	followersMutex.Unlock()
+	if running {
+		f.cancel()
+		<-f.done
+	}
+}
+
+// Numbers in the mount info come back from the database as int64 (or, failing that, float64)
+func toInt64(value any) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
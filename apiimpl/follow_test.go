@@ -0,0 +1,73 @@
+package apiimpl
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	followLine1 = `165.23.106.237 - alice [04/Sep/2025:19:12:36 -0700] "GET /logout HTTP/1.1" 200 11281` + "\n"
+	followLine2 = `237.192.154.154 - - [15/Aug/2025:05:18:10 +0000] "PUT /settings HTTP/1.1" 200 972` + "\n"
+)
+
+func appendLine(t *testing.T, path string, line string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString(line)
+	require.NoError(t, err)
+}
+
+func TestFollowCatchesUpBeforeQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	require.NoError(t, os.WriteFile(path, []byte(followLine1), 0644))
+
+	ctx := data.NewGremelContext(context.Background())
+	require.NoError(t, MountWithOptions(ctx, "followed_access", path, map[string]string{Follow: "true"}))
+	assert.Equal(t, int64(1), countRows(t, ctx, "followed_access"))
+
+	mountInfo, err := GetMount(ctx, "followed_access")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(followLine1)), mountInfo["follow.offset"])
+
+	appendLine(t, path, followLine2)
+	assert.Equal(t, int64(2), countRows(t, ctx, "followed_access"))
+
+	// An explicit refresh only appends, too
+	appendLine(t, path, followLine1)
+	require.NoError(t, Refresh(ctx, "followed_access"))
+	assert.Equal(t, int64(3), countRows(t, ctx, "followed_access"))
+}
+
+func TestFollowInBackground(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	require.NoError(t, os.WriteFile(path, []byte(followLine1), 0644))
+
+	ctx := data.NewGremelContext(context.Background())
+	options := map[string]string{Follow: "true", FollowInterval: "10ms"}
+	require.NoError(t, MountWithOptions(ctx, "background_access", path, options))
+	defer stopFollower("background_access")
+
+	appendLine(t, path, followLine2)
+	assert.Eventually(t, func() bool {
+		mountInfo, err := GetMount(ctx, "background_access")
+		return err == nil && mountInfo["follow.offset"] == int64(len(followLine1+followLine2))
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestFollowOptionsAreValidated(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	err := MountWithOptions(ctx, "bad_follow", "../test_resources/clf.log", map[string]string{Follow: "maybe"})
+	assert.ErrorContains(t, err, "invalid follow")
+	err = MountWithOptions(ctx, "bad_follow", "../test_resources/clf.log", map[string]string{FollowInterval: "1s"})
+	assert.ErrorContains(t, err, "needs follow=true")
+	err = MountWithOptions(ctx, "bad_follow", "https://example.com/access.log", map[string]string{Follow: "true"})
+	assert.ErrorContains(t, err, "only files can be followed")
+}
@@ -1,12 +1,15 @@
 package apiimpl
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/jbirtley88/gremel/adapter"
@@ -17,6 +20,13 @@ import (
 )
 
 func Mount(ctx data.GremelContext, name string, source string) error {
+	if IsExecSource(source) {
+		return MountExec(ctx, name, source)
+	}
+	if IsStdinSource(source) {
+		return MountStdin(ctx, name, source)
+	}
+
 	// See if this is a local path
 	fi, err := os.Stat(source)
 	if err != nil {
@@ -25,17 +35,181 @@ func Mount(ctx data.GremelContext, name string, source string) error {
 			// This at least looks like a URL
 			return MountUrl(ctx, name, source)
 		}
+		if isGlob(source) {
+			return MountFiles(ctx, name, source)
+		}
 
 		// We don't know what to do with this
 		return fmt.Errorf("Mount(%s): unsupported data source", source)
 	}
 
 	if fi.IsDir() {
-		return fmt.Errorf("Mount(%s): directories not supported", source)
+		return MountFiles(ctx, name, source)
 	}
 	return MountFile(ctx, name, source)
 }
 
+// MountFiles mounts every file in a directory, or matching a glob (e.g.
+// '/var/log/app/*.log'), as a single table.  A '_source_file' column says
+// which file each row came from.
+//
+// The files are listed again whenever the mount is refreshed.
+func MountFiles(ctx data.GremelContext, name string, source string) error {
+	files, err := listFiles(source)
+	if err != nil {
+		return fmt.Errorf("MountFiles(%s): %w", source, err)
+	}
+	database := db.GetGremelDB()
+	err = adapter.CreateTableFromFiles(ctx, database, name, files)
+	if err != nil {
+		return fmt.Errorf("MountFiles(%s): %w", source, err)
+	}
+	err = database.Mount(name, source)
+	if err != nil {
+		return fmt.Errorf("MountFiles(%s): %w", source, err)
+	}
+	err = database.SetMountInfo(name, "files", files)
+	if err != nil {
+		return fmt.Errorf("MountFiles(%s): %w", source, err)
+	}
+	return nil
+}
+
+// listFiles lists the (non-hidden) files in a directory, or the files which
+// match a glob, in name order
+func listFiles(source string) ([]string, error) {
+	var candidates []string
+	if fi, err := os.Stat(source); err == nil && fi.IsDir() {
+		entries, err := os.ReadDir(source)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), ".") {
+				candidates = append(candidates, filepath.Join(source, entry.Name()))
+			}
+		}
+	} else {
+		candidates, err = filepath.Glob(source)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var files []string
+	for _, candidate := range candidates {
+		if fi, err := os.Stat(candidate); err == nil && fi.Mode().IsRegular() {
+			files = append(files, candidate)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files found")
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func isGlob(source string) bool {
+	return strings.ContainsAny(source, "*?[")
+}
+
+// MountWithOptions mounts source as name, with options (e.g. format=csv,
+// data=results.items, excel.sheetname=Sheet2) which only apply to this mount.
+//
+// The options are recorded against the mount, so that '.mount name' shows them.
+func MountWithOptions(ctx data.GremelContext, name string, source string, options map[string]string) error {
+	if len(options) == 0 {
+		stopFollower(name)
+		return Mount(ctx, name, source)
+	}
+
+	if err := validateRefreshOptions(source, options); err != nil {
+		return fmt.Errorf("MountWithOptions(%s): %w", source, err)
+	}
+	if err := validateFollowOptions(source, options); err != nil {
+		return fmt.Errorf("MountWithOptions(%s): %w", source, err)
+	}
+	// Don't append to the old table while the new one is being mounted
+	stopFollower(name)
+
+	// Scope the options to this mount, rather than leaking them into ctx
+	mountCtx := data.NewGremelContext(ctx.Context(), ctx.Values())
+	for key, value := range options {
+		mountCtx.Values().SetValue(key, value)
+	}
+	err := Mount(mountCtx, name, source)
+	if err != nil {
+		return err
+	}
+	database := db.GetGremelDB()
+	err = database.SetMountInfo(name, "options", options)
+	if err != nil {
+		return fmt.Errorf("MountWithOptions(%s): %w", source, err)
+	}
+	mountInfo, err := database.GetMount(name)
+	if err != nil {
+		return fmt.Errorf("MountWithOptions(%s): %w", source, err)
+	}
+	// file:// URLs are recorded as the path, which is what we want to check
+	recordedSource, _ := mountInfo[name].(string)
+	err = recordRefreshInfo(database, name, recordedSource, options)
+	if err != nil {
+		return fmt.Errorf("MountWithOptions(%s): %w", source, err)
+	}
+	startFollower(name, options)
+	return nil
+}
+
+// ParseMountOptions parses 'key=value' tokens, e.g. from '.mount NAME PATH format=csv data=items'
+func ParseMountOptions(tokens []string) (map[string]string, error) {
+	options := make(map[string]string)
+	for _, token := range tokens {
+		if token == "" {
+			continue
+		}
+		key, value, found := strings.Cut(token, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("ParseMountOptions(%s): options must be in the format key=value", token)
+		}
+		options[key] = value
+	}
+	return options, nil
+}
+
+// SplitMountSource separates any options from a '--mount name=SOURCE' source:
+//
+//   - files take a query string, e.g. 'file.json?data=results.items&select=id,name'
+//   - URLs take a fragment (the query string belongs to the URL), e.g. 'https://host/api?page=1#data=items'
+//
+// A file which really does have a '?' in its name (or a glob using the '?'
+// wildcard) is left alone, as is an 'exec:' command.  Stdin takes a query
+// string, e.g. '-?format=csv'.
+func SplitMountSource(source string) (string, map[string]string, error) {
+	if _, err := os.Stat(source); err == nil || IsExecSource(source) {
+		return source, nil, nil
+	}
+
+	separator := "?"
+	if strings.Contains(source, "://") {
+		separator = "#"
+	}
+	path, query, found := strings.Cut(source, separator)
+	// e.g. the '?' wildcard in 'access.log.?'
+	if !found || !strings.Contains(query, "=") {
+		return source, nil, nil
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", nil, fmt.Errorf("SplitMountSource(%s): %w", source, err)
+	}
+	options := make(map[string]string)
+	for key, value := range values {
+		options[key] = value[len(value)-1]
+	}
+	return path, options, nil
+}
+
 // Only used for testing - allows us to mock out HTTP calls
 var httpHelper = helper.NewHttpHelperBuilder().Build()
 
@@ -54,24 +228,38 @@ func MountUrl(ctx data.GremelContext, name string, sourceUrl string) error {
 	}
 
 	// Defer to the HTTP helper to fetch the content
-	code, body, err := httpHelper.Get(sourceUrl)
+	resp, err := httpHelper.Fetch(sourceUrl)
 	if err != nil {
 		return fmt.Errorf("MountUrl(%s): %w", sourceUrl, err)
 	}
-	defer body.Close()
-	if code != http.StatusOK {
-		return fmt.Errorf("MountUrl(%s): GET returned HTTP %d", sourceUrl, code)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("MountUrl(%s): GET returned HTTP %d", sourceUrl, resp.StatusCode)
 	}
-	bodyBytes, err := io.ReadAll(body)
+
+	// Pick the parser from the Content-Type, then the extension on the URL
+	// path, then by sniffing the body (unless the caller told us the format).
+	// A compressed body (e.g. 'export.csv.gz') is decompressed first.
+	body, closer, err := adapter.Decompressed(resp.Body)
 	if err != nil {
 		return fmt.Errorf("MountUrl(%s): %w", sourceUrl, err)
 	}
-
-	// Now pass it to the JSON adapter for parsing
-	// TODO(john): will we ever want to mount over HTTP when the content is not JSON?
-	// If so, we need to detect the type somehow and pass it to the appropriate parser
-	// For now, we just assume JSON
-	err = adapter.CreateTableFromReader(ctx, db.GetGremelDB(), name, bytes.NewBuffer(bodyBytes), adapter.NewGenericJsonParser(ctx))
+	defer closer.Close()
+	head, err := adapter.PeekHead(body)
+	if err != nil {
+		return fmt.Errorf("MountUrl(%s): %w", sourceUrl, err)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	_, ext, compression, _ := util.SplitCompressedFilename(path.Base(u.Path))
+	if compression != "" || adapter.DetectCompression(head) != "" {
+		// The Content-Type is that of the compressed data
+		contentType = ""
+	}
+	if adapter.IsArchive(ext, head) {
+		err = mountUrlArchive(ctx, name, body)
+	} else {
+		err = mountUrlBody(ctx, name, contentType, ext, body, head)
+	}
 	if err != nil {
 		return fmt.Errorf("MountUrl(%s): %w", sourceUrl, err)
 	}
@@ -79,15 +267,54 @@ func MountUrl(ctx data.GremelContext, name string, sourceUrl string) error {
 	if err != nil {
 		return fmt.Errorf("MountUrl(%s): %w", sourceUrl, err)
 	}
+	err = mountChildTables(ctx, db.GetGremelDB(), name, sourceUrl)
+	if err != nil {
+		return fmt.Errorf("MountUrl(%s): %w", sourceUrl, err)
+	}
 	return nil
 }
 
-func MountFile(ctx data.GremelContext, name, path string) error {
-	_, ext, err := util.SplitFilename(path)
+func mountUrlBody(ctx data.GremelContext, name string, contentType string, ext string, body io.Reader, head []byte) error {
+	parser, err := adapter.ResolveParser(ctx, contentType, ext, head)
+	if err != nil {
+		return err
+	}
+	return adapter.CreateTableFromReader(ctx, db.GetGremelDB(), name, body, parser)
+}
+
+// Archives have to be read more than once (or, for zip files, from the end),
+// so the download is spooled to a temporary file first
+func mountUrlArchive(ctx data.GremelContext, name string, body io.Reader) error {
+	f, err := os.CreateTemp("", "gremel-*.archive")
 	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := io.Copy(f, body); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return adapter.CreateTableFromArchive(ctx, db.GetGremelDB(), name, f.Name())
+}
+
+func MountFile(ctx data.GremelContext, name, path string) error {
+	// No extension is fine - the parser is chosen by sniffing the content instead.
+	// For a compressed file (e.g. 'access.log.gz'), it's the extension inside which counts.
+	_, ext, _, err := util.SplitCompressedFilename(path)
+	if err != nil && !errors.Is(err, util.ErrNoExtension) {
 		return fmt.Errorf("MountFile(%s): %w", path, err)
 	}
 	database := db.GetGremelDB()
+	if ctx.Values().GetBool(Follow) {
+		err = mountFollowedFile(ctx, database, name, path, ext)
+		if err != nil {
+			return fmt.Errorf("MountFile(%s): %w", path, err)
+		}
+		return nil
+	}
 	err = adapter.CreateTableFromFile(ctx, database, name, ext, path)
 	if err != nil {
 		return fmt.Errorf("MountFile(%s): %w", path, err)
@@ -96,9 +323,32 @@ func MountFile(ctx data.GremelContext, name, path string) error {
 	if err != nil {
 		return fmt.Errorf("MountFile(%s): %w", path, err)
 	}
+	err = mountChildTables(ctx, database, name, path)
+	if err != nil {
+		return fmt.Errorf("MountFile(%s): %w", path, err)
+	}
 	return nil
 }
 
+// mountChildTables registers any tables which were split out of the mounted
+// data (e.g. nested JSON arrays) as mounts in their own right, so that they
+// show up in '.mount'
+func mountChildTables(ctx data.GremelContext, database db.GremelDB, name string, source string) error {
+	childTables, _ := ctx.Values().GetValue(name + ".tables").([]string)
+	if len(childTables) == 0 {
+		return nil
+	}
+	for _, childTable := range childTables {
+		if err := database.Mount(childTable, source); err != nil {
+			return err
+		}
+		if err := database.SetMountInfo(childTable, "parent", name); err != nil {
+			return err
+		}
+	}
+	return database.SetMountInfo(name, "tables", childTables)
+}
+
 func GetMount(ctx data.GremelContext, tableName string) (data.Row, error) {
 	database := db.GetGremelDB()
 	mountInfo, err := database.GetMount(tableName)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -49,6 +50,8 @@ func TestMountFileUrl(t *testing.T) {
 type mockHttpHelper struct {
 	responseCode int
 	responseBody string
+	contentType  string
+	etag         string
 	shouldError  bool
 	errorMsg     string
 }
@@ -63,6 +66,37 @@ func (m *mockHttpHelper) Get(url string) (code int, body io.ReadCloser, err erro
 	return m.responseCode, io.NopCloser(strings.NewReader(m.responseBody)), nil
 }
 
+func (m *mockHttpHelper) Fetch(url string) (*http.Response, error) {
+	code, body, err := m.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	if m.contentType != "" {
+		header.Set("Content-Type", m.contentType)
+	}
+	return &http.Response{
+		StatusCode: code,
+		Header:     header,
+		Body:       body,
+	}, nil
+}
+
+func (m *mockHttpHelper) Head(url string) (*http.Response, error) {
+	if m.shouldError {
+		return nil, errors.New(m.errorMsg)
+	}
+	header := http.Header{}
+	if m.etag != "" {
+		header.Set("ETag", m.etag)
+	}
+	return &http.Response{
+		StatusCode: m.responseCode,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader("")),
+	}, nil
+}
+
 func TestMountHttpUrl(t *testing.T) {
 	// Read the contents of people.json to use as mock response
 	peopleJsonPath := "../test_resources/people.json"
@@ -180,3 +214,205 @@ ORDER BY i.datacenter`
 	assert.Equal(t, int64(0), rows[3]["latency>2000"])
 	assert.Equal(t, []string{"datacenter", "latency>2000"}, columns)
 }
+
+func TestMountHttpUrlChoosesParser(t *testing.T) {
+	peopleCsvBytes, err := os.ReadFile("../test_resources/people.csv")
+	require.NoError(t, err)
+	clfBytes, err := os.ReadFile("../test_resources/clf.log")
+	require.NoError(t, err)
+
+	originalHttpHelper := httpHelper
+	defer func() {
+		httpHelper = originalHttpHelper
+	}()
+
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		body        []byte
+		format      string
+	}{
+		{"content type", "https://api.example.com/export", "text/csv; charset=utf-8", peopleCsvBytes, ""},
+		{"extension", "https://api.example.com/export.csv?page=1", "application/octet-stream", peopleCsvBytes, ""},
+		{"sniffed csv", "https://api.example.com/export", "", peopleCsvBytes, ""},
+		{"sniffed log", "https://api.example.com/access", "text/plain", clfBytes, ""},
+		{"format override", "https://api.example.com/export.json", "application/json", peopleCsvBytes, "csv"},
+	}
+	database := db.GetGremelDB()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpHelper = &mockHttpHelper{
+				responseCode: 200,
+				responseBody: string(tt.body),
+				contentType:  tt.contentType,
+			}
+			ctx := data.NewGremelContext(context.Background())
+			if tt.format != "" {
+				ctx.Values().SetValue("format", tt.format)
+			}
+			require.NoError(t, Mount(ctx, "http_export", tt.url))
+
+			rows, _, err := database.Query("SELECT COUNT(*) AS count FROM http_export")
+			require.NoError(t, err)
+			require.Len(t, rows, 1)
+			assert.Greater(t, rows[0]["count"], int64(0))
+		})
+	}
+}
+
+func TestMountWithOptions(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+
+	err := MountWithOptions(ctx, "combined_logs", "../test_resources/combined.log", map[string]string{"format": "combined"})
+	require.NoError(t, err)
+
+	// The options are recorded against the mount...
+	mountInfo, err := GetMount(ctx, "combined_logs")
+	require.NoError(t, err)
+	assert.Equal(t, "../test_resources/combined.log", mountInfo["combined_logs"])
+	assert.Equal(t, map[string]string{"format": "combined"}, mountInfo["options"])
+
+	// ...but don't leak into the caller's context
+	assert.Empty(t, ctx.Values().GetString("format"))
+
+	// Remounting without options forgets them
+	require.NoError(t, MountWithOptions(ctx, "combined_logs", "../test_resources/combined.log", nil))
+	mountInfo, err = GetMount(ctx, "combined_logs")
+	require.NoError(t, err)
+	assert.NotContains(t, mountInfo, "options")
+}
+
+func TestParseMountOptions(t *testing.T) {
+	options, err := ParseMountOptions([]string{"format=csv", "", "data=results.items", "select=a=b"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"format": "csv", "data": "results.items", "select": "a=b"}, options)
+
+	_, err = ParseMountOptions([]string{"format"})
+	assert.Error(t, err)
+	_, err = ParseMountOptions([]string{"=csv"})
+	assert.Error(t, err)
+}
+
+func TestSplitMountSource(t *testing.T) {
+	tests := []struct {
+		source          string
+		expectedSource  string
+		expectedOptions map[string]string
+	}{
+		{"file.json", "file.json", nil},
+		{"file.json?data=results.items&format=json", "file.json", map[string]string{"data": "results.items", "format": "json"}},
+		{"https://host/api?page=1", "https://host/api?page=1", nil},
+		{"https://host/api?page=1#data=items", "https://host/api?page=1", map[string]string{"data": "items"}},
+		{"../test_resources/people.csv", "../test_resources/people.csv", nil},
+		{"/var/log/access.log.?", "/var/log/access.log.?", nil},
+		{"/var/log/*.log?format=clf", "/var/log/*.log", map[string]string{"format": "clf"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			source, options, err := SplitMountSource(tt.source)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSource, source)
+			assert.Equal(t, tt.expectedOptions, options)
+		})
+	}
+}
+
+func TestMountNestedJsonRegistersChildTables(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	require.NoError(t, Mount(ctx, "orders", "../test_resources/orders.json"))
+
+	mountInfo, err := GetMount(ctx, "orders")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orders__items"}, mountInfo["tables"])
+
+	mountInfo, err = GetMount(ctx, "orders__items")
+	require.NoError(t, err)
+	assert.Equal(t, "../test_resources/orders.json", mountInfo["orders__items"])
+	assert.Equal(t, "orders", mountInfo["parent"])
+}
+
+func TestMountEveryWorksheet(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	err := MountWithOptions(ctx, "sheets", "../test_resources/accounts_multiple_sheets.xlsx", map[string]string{"excel.sheets": "all"})
+	require.NoError(t, err)
+
+	mountInfo, err := GetMount(ctx, "sheets")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sheets_Sheet1", "sheets_Sheet2", "sheets_Sheet3"}, mountInfo["tables"])
+
+	mountInfo, err = GetMount(ctx, "sheets_Sheet2")
+	require.NoError(t, err)
+	assert.Equal(t, "sheets", mountInfo["parent"])
+
+	tables, err := GetTables(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, tables, "sheets_Sheet1")
+	assert.NotContains(t, tables, "sheets")
+}
+
+func TestMountDirectoryAndGlob(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "access.log.1"), []byte(followLine1+followLine2), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "access.log.2"), []byte(followLine1), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "people.csv"), []byte("id,name\n1,Alice\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".hidden"), []byte("ignored"), 0644))
+
+	ctx := data.NewGremelContext(context.Background())
+	glob := filepath.Join(dir, "access.log.*")
+	require.NoError(t, Mount(ctx, "rotated_logs", glob))
+	assert.Equal(t, int64(3), countRows(t, ctx, "rotated_logs"))
+
+	mountInfo, err := GetMount(ctx, "rotated_logs")
+	require.NoError(t, err)
+	assert.Equal(t, glob, mountInfo["rotated_logs"])
+	assert.Equal(t, []string{filepath.Join(dir, "access.log.1"), filepath.Join(dir, "access.log.2")}, mountInfo["files"])
+
+	// The whole directory, log files and CSV alike
+	require.NoError(t, Mount(ctx, "everything", dir))
+	rows, _, err := Query(ctx, "SELECT COUNT(DISTINCT _source_file) AS files, COUNT(*) AS count FROM everything")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), rows[0]["files"])
+	assert.Equal(t, int64(4), rows[0]["count"])
+
+	// New files turn up when the mount is refreshed
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "access.log.3"), []byte(followLine2), 0644))
+	require.NoError(t, Refresh(ctx, "rotated_logs"))
+	assert.Equal(t, int64(4), countRows(t, ctx, "rotated_logs"))
+
+	err = Mount(ctx, "nothing", filepath.Join(dir, "*.xml"))
+	assert.ErrorContains(t, err, "no files found")
+}
+
+func TestMountCompressedFileAndUrl(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	require.NoError(t, Mount(ctx, "rotated_log", "../test_resources/access.log.1.gz"))
+	assert.Equal(t, int64(20), countRows(t, ctx, "rotated_log"))
+
+	gzipped, err := os.ReadFile("../test_resources/access.log.1.gz")
+	require.NoError(t, err)
+	zipped, err := os.ReadFile("../test_resources/logs.zip")
+	require.NoError(t, err)
+
+	originalHttpHelper := httpHelper
+	defer func() {
+		httpHelper = originalHttpHelper
+	}()
+	httpHelper = &mockHttpHelper{
+		responseCode: 200,
+		responseBody: string(gzipped),
+		contentType:  "application/gzip",
+	}
+	require.NoError(t, Mount(ctx, "rotated_url", "https://logs.example.com/access.log.1.gz"))
+	assert.Equal(t, int64(20), countRows(t, ctx, "rotated_url"))
+
+	// Archives are downloaded before they are mounted
+	httpHelper = &mockHttpHelper{
+		responseCode: 200,
+		responseBody: string(zipped),
+		contentType:  "application/zip",
+	}
+	err = MountWithOptions(ctx, "archived_url", "https://logs.example.com/logs.zip", map[string]string{"archive.member": "people.csv"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), countRows(t, ctx, "archived_url"))
+}
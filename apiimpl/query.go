@@ -1,17 +1,87 @@
 package apiimpl
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/jbirtley88/gremel/data"
 	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/spf13/viper"
 )
 
+// ErrQueryCancelled is returned (wrapped) by Query when the context is
+// cancelled before the query finishes, e.g. by Ctrl-C or a client hanging up
+var ErrQueryCancelled = errors.New("query cancelled")
+
+// ErrQueryTimeout is returned (wrapped) by Query for a query which runs for
+// longer than 'config.query.timeout'
+var ErrQueryTimeout = errors.New("query timed out")
+
 // sqlQuery MUST be sanitized before calling this function
 // cf. Bobby Tables: https://xkcd.com/327/
+//
+// The query (along with any refresh of the stale tables it mentions) is
+// interrupted if ctx is cancelled, or if it runs for longer than
+// 'config.query.timeout'.  Anything other than reading the mounted tables
+// fails with db.ErrReadOnly.
 func Query(ctx data.GremelContext, sqlQuery string) ([]data.Row, []string, error) {
+	timeout, err := queryTimeout()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Query(): %w", err)
+	}
+	parent := context.Background()
+	if ctx != nil {
+		parent = ctx.Context()
+	}
+	var queryCtx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		queryCtx, cancel = context.WithTimeout(parent, timeout)
+	} else {
+		queryCtx, cancel = context.WithCancel(parent)
+	}
+	defer cancel()
+
+	// Mounts with refresh.ttl or refresh.check are reloaded first, if stale
+	refreshCtx := data.NewGremelContext(queryCtx)
+	if ctx != nil {
+		refreshCtx = data.NewGremelContext(queryCtx, ctx.Values())
+	}
+	if err := refreshStaleTables(refreshCtx, sqlQuery); err != nil {
+		return nil, nil, queryError(parent, queryCtx, timeout, fmt.Errorf("Query(): %w", err))
+	}
+
 	database := db.GetGremelDB()
-	rows, columns, err := database.Query(sqlQuery)
+	rows, columns, err := database.QueryContext(queryCtx, sqlQuery)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, queryError(parent, queryCtx, timeout, err)
 	}
 	return rows, columns, nil
 }
+
+// queryError says whether err was down to the query being cancelled or
+// timing out, rather than the query itself
+func queryError(parent context.Context, queryCtx context.Context, timeout time.Duration, err error) error {
+	switch {
+	case parent.Err() != nil:
+		return fmt.Errorf("Query(): %w", ErrQueryCancelled)
+	case errors.Is(queryCtx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("Query(): %w after %s (see %s)", ErrQueryTimeout, timeout, data.CONF_QUERY_TIMEOUT)
+	}
+	return err
+}
+
+// queryTimeout is 'config.query.timeout', or 0 if queries can run for as long as they like
+func queryTimeout() (time.Duration, error) {
+	setting := viper.GetString(data.CONF_QUERY_TIMEOUT)
+	if setting == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(setting)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid %s '%s'", data.CONF_QUERY_TIMEOUT, setting)
+	}
+	return timeout, nil
+}
@@ -0,0 +1,68 @@
+package apiimpl
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A query which never finishes by itself
+const runawayQuery = "WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n) SELECT COUNT(*) FROM n"
+
+func TestQueryIsCancelledWithTheContext(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, _, err := Query(data.NewGremelContext(parent), runawayQuery)
+	assert.ErrorIs(t, err, ErrQueryCancelled)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// The database is still usable afterwards
+	rows, _, err := Query(data.NewGremelContext(context.Background()), "SELECT 1 AS one")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), rows[0]["one"])
+}
+
+func TestQueryCancelsTheRefreshItSetsOff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "people.csv")
+	require.NoError(t, os.WriteFile(path, []byte("id,name\n1,Alice\n2,Bob\n"), 0644))
+	require.NoError(t, MountWithOptions(data.NewGremelContext(context.Background()), "cancelled_refresh", path, map[string]string{RefreshTTL: "1ns"}))
+	require.NoError(t, os.WriteFile(path, []byte("id,name\n1,Alice\n2,Bob\n3,Carol\n"), 0644))
+
+	parent, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := Query(data.NewGremelContext(parent), "SELECT COUNT(*) FROM cancelled_refresh")
+	assert.ErrorIs(t, err, ErrQueryCancelled)
+
+	// The refresh didn't happen
+	rows, _, err := db.GetGremelDB().Query("SELECT COUNT(*) AS count FROM cancelled_refresh")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rows[0]["count"])
+}
+
+func TestQueryTimeout(t *testing.T) {
+	defer viper.Set(data.CONF_QUERY_TIMEOUT, "")
+	ctx := data.NewGremelContext(context.Background())
+
+	viper.Set(data.CONF_QUERY_TIMEOUT, "100ms")
+	_, _, err := Query(ctx, runawayQuery)
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+	assert.ErrorContains(t, err, "after 100ms")
+
+	// Plenty of time for a quick one
+	_, _, err = Query(ctx, "SELECT 1")
+	assert.NoError(t, err)
+
+	viper.Set(data.CONF_QUERY_TIMEOUT, "soon")
+	_, _, err = Query(ctx, "SELECT 1")
+	assert.ErrorContains(t, err, "invalid config.query.timeout 'soon'")
+}
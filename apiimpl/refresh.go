@@ -0,0 +1,443 @@
+package apiimpl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// Mount options which make a table reload itself when it goes stale:
+//
+//   - refresh.ttl=10m reloads the table once it is older than the duration
+//   - refresh.check=mtime|size|hash reloads a file when it changes
+//   - refresh.check=etag|lastmodified reloads a URL when the server says it has changed
+//   - refresh.interval=5s is how often refresh.check looks at the source (at most)
+const (
+	RefreshTTL      = "refresh.ttl"
+	RefreshCheck    = "refresh.check"
+	RefreshInterval = "refresh.interval"
+)
+
+// DefaultRefreshInterval is how often refresh.check looks at the source, if
+// refresh.interval isn't given.  Otherwise a busy table would have its file
+// hashed (or its URL sent a HEAD request) on every query.
+const DefaultRefreshInterval = 5 * time.Second
+
+// When refresh.check last looked at each mount's source
+var (
+	lastCheckedMutex sync.Mutex
+	lastChecked      = make(map[string]time.Time)
+)
+
+// The refreshes of each mount, which take turns, so that a stale table is
+// only reloaded once however many queries find it stale at the same time
+var (
+	refreshingMutex sync.Mutex
+	refreshing      = make(map[string]*sync.Mutex)
+)
+
+// lockMount waits for any other refresh of the mount to finish, returning the
+// function which lets the next one go ahead
+func lockMount(name string) func() {
+	refreshingMutex.Lock()
+	mountMutex, exists := refreshing[name]
+	if !exists {
+		mountMutex = &sync.Mutex{}
+		refreshing[name] = mountMutex
+	}
+	refreshingMutex.Unlock()
+	mountMutex.Lock()
+	return mountMutex.Unlock
+}
+
+// Extra mount info recorded for mounts with refresh options
+const (
+	mountInfoMounted     = "mounted"
+	mountInfoFingerprint = "fingerprint"
+)
+
+// Refresh re-reads a mounted table from the source (and with the options)
+// which it was mounted with.  Refreshing a child table (e.g. a worksheet or
+// nested JSON array) refreshes the whole of its parent mount.
+//
+// Followed files (see the 'follow' option) just have any new lines appended.
+func Refresh(ctx data.GremelContext, name string) error {
+	name, mountInfo, err := getParentMount(name)
+	if err != nil {
+		return fmt.Errorf("Refresh(%s): %w", name, err)
+	}
+	source, _ := mountInfo[name].(string)
+	if IsStdinSource(source) {
+		return fmt.Errorf("Refresh(%s): stdin can only be read once", name)
+	}
+	options, _ := mountInfo["options"].(map[string]string)
+	if isFollowed(options) {
+		// Only the new lines, rather than the whole file.  This also restarts
+		// the background follower after e.g. reopening a '--db' database
+		if _, err := CatchUp(ctx, name); err != nil {
+			return fmt.Errorf("Refresh(%s): %w", name, err)
+		}
+		startFollower(name, options)
+		return nil
+	}
+	if err := MountWithOptions(ctx, name, source, options); err != nil {
+		return fmt.Errorf("Refresh(%s): %w", name, err)
+	}
+	return nil
+}
+
+// RefreshAll refreshes every mount (child tables come along with their
+// parents), apart from those read from stdin, which are left as they are
+func RefreshAll(ctx data.GremelContext) ([]string, error) {
+	names, err := topLevelMounts()
+	if err != nil {
+		return nil, fmt.Errorf("RefreshAll(): %w", err)
+	}
+	var refreshed []string
+	for _, name := range names {
+		if mountInfo, err := db.GetGremelDB().GetMount(name); err == nil {
+			if source, _ := mountInfo[name].(string); IsStdinSource(source) {
+				continue
+			}
+		}
+		if err := Refresh(ctx, name); err != nil {
+			return nil, fmt.Errorf("RefreshAll(): %w", err)
+		}
+		refreshed = append(refreshed, name)
+	}
+	return refreshed, nil
+}
+
+// RefreshIfStale refreshes the mount if its refresh.ttl has expired, or its
+// refresh.check says that the source has changed.  Mounts without either
+// option are never stale.
+//
+// Followed files are always caught up with any new lines.
+//
+// Only one refresh of a mount happens at a time, and a table which has been
+// refreshed while waiting for its turn isn't refreshed again.
+func RefreshIfStale(ctx data.GremelContext, name string) (bool, error) {
+	name, mountInfo, err := getParentMount(name)
+	if err != nil {
+		return false, fmt.Errorf("RefreshIfStale(%s): %w", name, err)
+	}
+	if options, _ := mountInfo["options"].(map[string]string); isFollowed(options) {
+		appended, err := CatchUp(ctx, name)
+		if err != nil {
+			return false, fmt.Errorf("RefreshIfStale(%s): %w", name, err)
+		}
+		return appended > 0, nil
+	}
+
+	// Another query may have refreshed it while this one waited, so the mount
+	// info is looked at afresh
+	unlock := lockMount(name)
+	defer unlock()
+	mountInfo, err = db.GetGremelDB().GetMount(name)
+	if err != nil {
+		return false, fmt.Errorf("RefreshIfStale(%s): %w", name, err)
+	}
+	stale, err := isStale(mountInfo, name)
+	if err != nil {
+		return false, fmt.Errorf("RefreshIfStale(%s): %w", name, err)
+	}
+	if !stale {
+		return false, nil
+	}
+	if err := Refresh(ctx, name); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// refreshStaleTables refreshes any stale mounts which sqlQuery mentions.
+//
+// A refresh which fails leaves the table as it was, but fails the query too,
+// rather than answering it from data which is known to be out of date.
+func refreshStaleTables(ctx data.GremelContext, sqlQuery string) error {
+	allMounts, err := db.GetGremelDB().GetMount("")
+	if err != nil {
+		return err
+	}
+	words := sqlWords(sqlQuery)
+	for name := range allMounts {
+		if !mentionsTable(sqlQuery, words, name) {
+			continue
+		}
+		refreshed, err := RefreshIfStale(ctx, name)
+		if err != nil {
+			return fmt.Errorf("could not refresh stale table '%s': %w", name, err)
+		}
+		if refreshed {
+			log.Infof("refreshed stale table '%s'", name)
+		}
+	}
+	return nil
+}
+
+// mentionsTable is deliberately crude - at worst we check a mount which the
+// query doesn't actually use.  words are the sqlWords() of sqlQuery.
+func mentionsTable(sqlQuery string, words map[string]bool, name string) bool {
+	if strings.IndexFunc(name, func(r rune) bool { return !isWordRune(r) }) == -1 {
+		return words[strings.ToLower(name)]
+	}
+	// e.g. a name which has to be quoted
+	return strings.Contains(strings.ToLower(sqlQuery), strings.ToLower(name))
+}
+
+// sqlWords is the set of (lower-cased) words in sqlQuery
+func sqlWords(sqlQuery string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(sqlQuery, func(r rune) bool { return !isWordRune(r) }) {
+		words[strings.ToLower(word)] = true
+	}
+	return words
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// getParentMount returns the mount which name came from (which may be name itself)
+func getParentMount(name string) (string, data.Row, error) {
+	database := db.GetGremelDB()
+	mountInfo, err := database.GetMount(name)
+	if err != nil {
+		return name, nil, err
+	}
+	if parent, isChild := mountInfo["parent"].(string); isChild && parent != "" {
+		name = parent
+		mountInfo, err = database.GetMount(name)
+		if err != nil {
+			return name, nil, err
+		}
+	}
+	return name, mountInfo, nil
+}
+
+func topLevelMounts() ([]string, error) {
+	database := db.GetGremelDB()
+	allMounts, err := database.GetMount("")
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name := range allMounts {
+		mountInfo, err := database.GetMount(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, isChild := mountInfo["parent"]; !isChild {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func isStale(mountInfo data.Row, name string) (bool, error) {
+	options, _ := mountInfo["options"].(map[string]string)
+	if ttlOption := options[RefreshTTL]; ttlOption != "" {
+		ttl, err := time.ParseDuration(ttlOption)
+		if err != nil {
+			return false, fmt.Errorf("invalid %s '%s': %w", RefreshTTL, ttlOption, err)
+		}
+		mountedAt, err := time.Parse(time.RFC3339Nano, fmt.Sprint(mountInfo[mountInfoMounted]))
+		if err != nil || time.Since(mountedAt) >= ttl {
+			return true, nil
+		}
+	}
+	if check := options[RefreshCheck]; check != "" {
+		checkNow, err := dueForCheck(name, options)
+		if err != nil || !checkNow {
+			return false, err
+		}
+		source, _ := mountInfo[name].(string)
+		fingerprint, err := sourceFingerprint(source, check)
+		if err != nil {
+			return false, err
+		}
+		if fingerprint != fmt.Sprint(mountInfo[mountInfoFingerprint]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// dueForCheck is true if refresh.check hasn't looked at the source within
+// refresh.interval, in which case it is noted as being looked at now
+func dueForCheck(name string, options map[string]string) (bool, error) {
+	interval := DefaultRefreshInterval
+	if intervalOption := options[RefreshInterval]; intervalOption != "" {
+		var err error
+		interval, err = time.ParseDuration(intervalOption)
+		if err != nil {
+			return false, fmt.Errorf("invalid %s '%s': %w", RefreshInterval, intervalOption, err)
+		}
+	}
+	lastCheckedMutex.Lock()
+	defer lastCheckedMutex.Unlock()
+	if checkedAt, checked := lastChecked[name]; checked && time.Since(checkedAt) < interval {
+		return false, nil
+	}
+	lastChecked[name] = time.Now()
+	return true, nil
+}
+
+// validateRefreshOptions catches bad refresh options before anything is mounted
+func validateRefreshOptions(source string, options map[string]string) error {
+	if IsStdinSource(source) && (options[RefreshTTL] != "" || options[RefreshCheck] != "") {
+		return fmt.Errorf("stdin can only be read once, so can't be refreshed")
+	}
+	for _, durationOption := range []string{RefreshTTL, RefreshInterval} {
+		if value := options[durationOption]; value != "" {
+			if _, err := time.ParseDuration(value); err != nil {
+				return fmt.Errorf("invalid %s '%s': %w", durationOption, value, err)
+			}
+		}
+	}
+	check := strings.ToLower(options[RefreshCheck])
+	switch {
+	case check == "":
+	case IsExecSource(source):
+		return fmt.Errorf("%s can't tell whether a command's output has changed - use %s instead", RefreshCheck, RefreshTTL)
+	case isHttpSource(source):
+		if check != "etag" && check != "lastmodified" && check != "last-modified" {
+			return fmt.Errorf("%s must be one of etag or lastmodified for a URL (not '%s')", RefreshCheck, check)
+		}
+	default:
+		if check != "mtime" && check != "size" && check != "hash" {
+			return fmt.Errorf("%s must be one of mtime, size or hash for a file (not '%s')", RefreshCheck, check)
+		}
+	}
+	return nil
+}
+
+// recordRefreshInfo notes when the mount was loaded and, if it is to be
+// checked for changes, what the source looked like at the time
+func recordRefreshInfo(database db.GremelDB, name string, source string, options map[string]string) error {
+	if options[RefreshTTL] == "" && options[RefreshCheck] == "" {
+		return nil
+	}
+	err := database.SetMountInfo(name, mountInfoMounted, time.Now().UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return err
+	}
+	if check := options[RefreshCheck]; check != "" {
+		fingerprint, err := sourceFingerprint(source, check)
+		if err != nil {
+			return err
+		}
+		lastCheckedMutex.Lock()
+		lastChecked[name] = time.Now()
+		lastCheckedMutex.Unlock()
+		return database.SetMountInfo(name, mountInfoFingerprint, fingerprint)
+	}
+	return nil
+}
+
+func isHttpSource(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// sourceFingerprint summarises the source such that, if the fingerprint
+// changes, so has the source
+func sourceFingerprint(source string, check string) (string, error) {
+	check = strings.ToLower(check)
+	if isHttpSource(source) {
+		return urlFingerprint(source, check)
+	}
+	return fileFingerprint(source, check)
+}
+
+// fileFingerprint covers every file in a directory or glob mount
+func fileFingerprint(source string, check string) (string, error) {
+	paths := []string{source}
+	if fi, err := os.Stat(source); (err == nil && fi.IsDir()) || (err != nil && isGlob(source)) {
+		files, err := listFiles(source)
+		if err != nil {
+			return "", err
+		}
+		paths = files
+	}
+
+	fingerprints := make([]string, 0, len(paths))
+	for _, path := range paths {
+		fingerprint, err := singleFileFingerprint(path, check)
+		if err != nil {
+			return "", err
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	if len(paths) == 1 && paths[0] == source {
+		return fingerprints[0], nil
+	}
+	// Files coming and going changes the fingerprint, too
+	hash := sha256.New()
+	for i, path := range paths {
+		fmt.Fprintf(hash, "%s=%s\n", path, fingerprints[i])
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func singleFileFingerprint(path string, check string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	switch check {
+	case "mtime":
+		return fmt.Sprintf("%d:%d", fi.ModTime().UnixNano(), fi.Size()), nil
+	case "size":
+		return fmt.Sprintf("%d", fi.Size()), nil
+	case "hash":
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		hash := sha256.New()
+		if _, err := io.Copy(hash, f); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+	return "", fmt.Errorf("%s must be one of mtime, size or hash for a file (not '%s')", RefreshCheck, check)
+}
+
+func urlFingerprint(sourceUrl string, check string) (string, error) {
+	var header string
+	switch check {
+	case "etag":
+		header = "ETag"
+	case "lastmodified", "last-modified":
+		header = "Last-Modified"
+	default:
+		return "", fmt.Errorf("%s must be one of etag or lastmodified for a URL (not '%s')", RefreshCheck, check)
+	}
+	resp, err := httpHelper.Head(sourceUrl)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HEAD returned HTTP %d", resp.StatusCode)
+	}
+	value := resp.Header.Get(header)
+	if value == "" {
+		return "", fmt.Errorf("%s did not send a %s header", sourceUrl, header)
+	}
+	return value, nil
+}
@@ -0,0 +1,189 @@
+package apiimpl
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func countRows(t *testing.T, ctx data.GremelContext, table string) int64 {
+	rows, _, err := Query(ctx, "SELECT COUNT(*) AS count FROM "+table)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	return rows[0]["count"].(int64)
+}
+
+func TestRefreshCheckReloadsChangedFile(t *testing.T) {
+	for _, check := range []string{"mtime", "size", "hash"} {
+		t.Run(check, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "people.csv")
+			require.NoError(t, os.WriteFile(path, []byte("id,name\n1,Alice\n2,Bob\n"), 0644))
+
+			ctx := data.NewGremelContext(context.Background())
+			table := "refresh_" + check
+			options := map[string]string{RefreshCheck: check, RefreshInterval: "0s"}
+			require.NoError(t, MountWithOptions(ctx, table, path, options))
+			assert.Equal(t, int64(2), countRows(t, ctx, table))
+
+			// Unchanged, so not stale
+			refreshed, err := RefreshIfStale(ctx, table)
+			require.NoError(t, err)
+			assert.False(t, refreshed)
+
+			// The query sees the new row without an explicit refresh
+			require.NoError(t, os.WriteFile(path, []byte("id,name\n1,Alice\n2,Bob\n3,Carol\n"), 0644))
+			later := time.Now().Add(time.Minute)
+			require.NoError(t, os.Chtimes(path, later, later))
+			assert.Equal(t, int64(3), countRows(t, ctx, table))
+
+			// The options survive the refresh
+			mountInfo, err := GetMount(ctx, table)
+			require.NoError(t, err)
+			assert.Equal(t, options, mountInfo["options"])
+		})
+	}
+}
+
+func TestFailedRefreshFailsTheQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "people.csv")
+	require.NoError(t, os.WriteFile(path, []byte("id,name\n1,Alice\n2,Bob\n"), 0644))
+	ctx := data.NewGremelContext(context.Background())
+	require.NoError(t, MountWithOptions(ctx, "refresh_broken", path, map[string]string{RefreshCheck: "hash", RefreshInterval: "0s"}))
+
+	require.NoError(t, os.WriteFile(path, []byte("id,name\n1,Alice\n2,\"Bob\n3,Carol\n"), 0644))
+	_, _, err := Query(ctx, "SELECT COUNT(*) FROM refresh_broken")
+	assert.ErrorContains(t, err, "could not refresh stale table 'refresh_broken'")
+
+	// The table is as it was
+	rows, _, err := db.GetGremelDB().Query("SELECT COUNT(*) AS count FROM refresh_broken")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rows[0]["count"])
+}
+
+func TestRefreshCheckInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "people.csv")
+	require.NoError(t, os.WriteFile(path, []byte("id,name\n1,Alice\n2,Bob\n"), 0644))
+	ctx := data.NewGremelContext(context.Background())
+	require.NoError(t, MountWithOptions(ctx, "refresh_interval", path, map[string]string{RefreshCheck: "size", RefreshInterval: "1h"}))
+
+	// The source isn't looked at again until the interval is up
+	require.NoError(t, os.WriteFile(path, []byte("id,name\n1,Alice\n2,Bob\n3,Carol\n"), 0644))
+	refreshed, err := RefreshIfStale(ctx, "refresh_interval")
+	require.NoError(t, err)
+	assert.False(t, refreshed)
+	assert.Equal(t, int64(2), countRows(t, ctx, "refresh_interval"))
+
+	lastCheckedMutex.Lock()
+	lastChecked["refresh_interval"] = time.Now().Add(-2 * time.Hour)
+	lastCheckedMutex.Unlock()
+	refreshed, err = RefreshIfStale(ctx, "refresh_interval")
+	require.NoError(t, err)
+	assert.True(t, refreshed)
+	assert.Equal(t, int64(3), countRows(t, ctx, "refresh_interval"))
+}
+
+func TestMentionsTable(t *testing.T) {
+	query := `SELECT * FROM People JOIN "odd-name" ON people.id = "odd-name".id WHERE note = 'orders_2024'`
+	words := sqlWords(query)
+	assert.True(t, mentionsTable(query, words, "people"))
+	assert.True(t, mentionsTable(query, words, "odd-name"))
+	assert.True(t, mentionsTable(query, words, "orders_2024"))
+	assert.False(t, mentionsTable(query, words, "peop"))
+	assert.False(t, mentionsTable(query, words, "orders"))
+}
+
+func TestRefreshTTL(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	require.NoError(t, MountWithOptions(ctx, "ttl_long", "../test_resources/people.csv", map[string]string{RefreshTTL: "1h"}))
+	refreshed, err := RefreshIfStale(ctx, "ttl_long")
+	require.NoError(t, err)
+	assert.False(t, refreshed)
+
+	require.NoError(t, MountWithOptions(ctx, "ttl_short", "../test_resources/people.csv", map[string]string{RefreshTTL: "1ns"}))
+	refreshed, err = RefreshIfStale(ctx, "ttl_short")
+	require.NoError(t, err)
+	assert.True(t, refreshed)
+
+	err = MountWithOptions(ctx, "ttl_bad", "../test_resources/people.csv", map[string]string{RefreshTTL: "soon"})
+	assert.ErrorContains(t, err, "invalid refresh.ttl")
+	err = MountWithOptions(ctx, "interval_bad", "../test_resources/people.csv", map[string]string{RefreshInterval: "often"})
+	assert.ErrorContains(t, err, "invalid refresh.interval")
+	err = MountWithOptions(ctx, "check_bad", "../test_resources/people.csv", map[string]string{RefreshCheck: "etag"})
+	assert.ErrorContains(t, err, "must be one of mtime, size or hash")
+}
+
+func TestRefreshTTLOnlyOnceAtATime(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	require.NoError(t, MountWithOptions(ctx, "ttl_busy", "../test_resources/people.csv", map[string]string{RefreshTTL: "200ms"}))
+	time.Sleep(250 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	var refreshes atomic.Int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			refreshed, err := RefreshIfStale(ctx, "ttl_busy")
+			assert.NoError(t, err)
+			if refreshed {
+				refreshes.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), refreshes.Load())
+}
+
+func TestRefreshCheckUsesETag(t *testing.T) {
+	peopleCsvBytes, err := os.ReadFile("../test_resources/people.csv")
+	require.NoError(t, err)
+
+	originalHttpHelper := httpHelper
+	defer func() {
+		httpHelper = originalHttpHelper
+	}()
+	mock := &mockHttpHelper{
+		responseCode: 200,
+		responseBody: string(peopleCsvBytes),
+		contentType:  "text/csv",
+		etag:         `"v1"`,
+	}
+	httpHelper = mock
+
+	ctx := data.NewGremelContext(context.Background())
+	require.NoError(t, MountWithOptions(ctx, "http_people", "https://api.example.com/people", map[string]string{RefreshCheck: "etag", RefreshInterval: "0s"}))
+	refreshed, err := RefreshIfStale(ctx, "http_people")
+	require.NoError(t, err)
+	assert.False(t, refreshed)
+
+	mock.etag = `"v2"`
+	refreshed, err = RefreshIfStale(ctx, "http_people")
+	require.NoError(t, err)
+	assert.True(t, refreshed)
+
+	mountInfo, err := GetMount(ctx, "http_people")
+	require.NoError(t, err)
+	assert.Equal(t, `"v2"`, mountInfo["fingerprint"])
+}
+
+func TestRefreshChildTableRefreshesParent(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	require.NoError(t, Mount(ctx, "refresh_orders", "../test_resources/orders.json"))
+	require.NoError(t, Refresh(ctx, "refresh_orders__items"))
+
+	mountInfo, err := GetMount(ctx, "refresh_orders")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"refresh_orders__items"}, mountInfo["tables"])
+	assert.Greater(t, countRows(t, ctx, "refresh_orders__items"), int64(0))
+
+	assert.Error(t, Refresh(ctx, "no_such_mount"))
+}
@@ -0,0 +1,21 @@
+package apiimpl
+
+import (
+	"fmt"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+)
+
+// SaveDatabase writes the current tables, schemas and mounts to an SQLite
+// file, so that the session can be picked up again with '--db path'
+func SaveDatabase(ctx data.GremelContext, path string) error {
+	if path == "" {
+		return fmt.Errorf("SaveDatabase(): path cannot be empty")
+	}
+	database := db.GetGremelDB()
+	if err := database.SaveAs(path); err != nil {
+		return fmt.Errorf("SaveDatabase(%s): %w", path, err)
+	}
+	return nil
+}
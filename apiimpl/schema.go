@@ -5,11 +5,12 @@ import (
 	"github.com/jbirtley88/gremel/facade/db"
 )
 
-func GetSchema(ctx data.GremelContext, tableName string) (data.Row, error) {
+// GetSchema returns the columns of the table (and their types), in order
+func GetSchema(ctx data.GremelContext, tableName string) (data.Schema, error) {
 	database := db.GetGremelDB()
 	schema, err := database.GetSchema(tableName)
 	if err != nil {
-		return data.Row{}, err
+		return data.Schema{}, err
 	}
 	return schema, nil
 }
@@ -26,7 +26,7 @@ func TestGetSchema_ValidTable(t *testing.T) {
 	_ = database.DropSchema(tableName)
 
 	// Create a test table with schema
-	err := database.CreateSchema(tableName, []data.Row{sampleRow})
+	err := database.CreateSchema(tableName, nil, []data.Row{sampleRow})
 	assert.NoError(t, err)
 
 	// Test GetSchema
@@ -39,7 +39,7 @@ func TestGetSchema_ValidTable(t *testing.T) {
 	// Schema should match the sample row structure
 	// The schema contains the field names and their inferred types
 	for key := range sampleRow {
-		assert.Contains(t, schema, key, "Schema should contain field %s", key)
+		assert.Contains(t, schema.Names(), key, "Schema should contain field %s", key)
 	}
 
 	// Clean up
@@ -100,7 +100,7 @@ func TestGetSchema_MultipleFieldTypes(t *testing.T) {
 	_ = database.DropSchema(tableName)
 
 	// Create a test table with various field types
-	err := database.CreateSchema(tableName, []data.Row{sampleRow})
+	err := database.CreateSchema(tableName, nil, []data.Row{sampleRow})
 	assert.NoError(t, err)
 
 	// Test GetSchema
@@ -113,7 +113,7 @@ func TestGetSchema_MultipleFieldTypes(t *testing.T) {
 	// Verify all fields are present in schema
 	expectedFields := []string{"string_field", "int_field", "float_field", "bool_field"}
 	for _, field := range expectedFields {
-		assert.Contains(t, schema, field, "Schema should contain field %s", field)
+		assert.Contains(t, schema.Names(), field, "Schema should contain field %s", field)
 	}
 
 	// Clean up
@@ -135,7 +135,7 @@ func TestGetSchema_SchemaContent(t *testing.T) {
 
 	// Clean up and create table
 	_ = database.DropSchema(tableName)
-	err := database.CreateSchema(tableName, []data.Row{sampleRow})
+	err := database.CreateSchema(tableName, nil, []data.Row{sampleRow})
 	assert.NoError(t, err)
 
 	// Test GetSchema
@@ -149,31 +149,16 @@ func TestGetSchema_SchemaContent(t *testing.T) {
 	assert.Greater(t, len(schema), 0, "Schema should not be empty")
 
 	// Check that the schema contains the expected field names
-	assert.Contains(t, schema, "id", "Schema should contain id field")
-	assert.Contains(t, schema, "name", "Schema should contain name field")
-	assert.Contains(t, schema, "score", "Schema should contain score field")
-	assert.Contains(t, schema, "active", "Schema should contain active field")
+	assert.Contains(t, schema.Names(), "id", "Schema should contain id field")
+	assert.Contains(t, schema.Names(), "name", "Schema should contain name field")
+	assert.Contains(t, schema.Names(), "score", "Schema should contain score field")
+	assert.Contains(t, schema.Names(), "active", "Schema should contain active field")
 
 	// Check that the values are SQL type strings
-	if idType, exists := schema["id"]; exists {
-		assert.IsType(t, "", idType, "Schema field type should be string")
-		assert.Contains(t, []string{"INTEGER", "INT"}, idType, "id field should be INTEGER type")
-	}
-
-	if nameType, exists := schema["name"]; exists {
-		assert.IsType(t, "", nameType, "Schema field type should be string")
-		assert.Equal(t, "TEXT", nameType, "name field should be TEXT type")
-	}
-
-	if scoreType, exists := schema["score"]; exists {
-		assert.IsType(t, "", scoreType, "Schema field type should be string")
-		assert.Equal(t, "REAL", scoreType, "score field should be REAL type")
-	}
-
-	if activeType, exists := schema["active"]; exists {
-		assert.IsType(t, "", activeType, "Schema field type should be string")
-		assert.Equal(t, "BOOLEAN", activeType, "active field should be BOOLEAN type")
-	}
+	assert.Contains(t, []string{"INTEGER", "INT"}, schema.Type("id"), "id field should be INTEGER type")
+	assert.Equal(t, "TEXT", schema.Type("name"), "name field should be TEXT type")
+	assert.Equal(t, "REAL", schema.Type("score"), "score field should be REAL type")
+	assert.Equal(t, "BOOLEAN", schema.Type("active"), "active field should be BOOLEAN type")
 
 	// Clean up
 	_ = database.DropSchema(tableName)
@@ -189,7 +174,7 @@ func TestGetSchema_ContextParameter(t *testing.T) {
 
 	// Clean up and create table
 	_ = database.DropSchema(tableName)
-	err := database.CreateSchema(tableName, []data.Row{sampleRow})
+	err := database.CreateSchema(tableName, nil, []data.Row{sampleRow})
 	assert.NoError(t, err)
 
 	// Test with nil context (though not recommended in practice)
@@ -216,15 +201,15 @@ func TestGetSchema_ReturnType(t *testing.T) {
 
 	// Clean up and create table
 	_ = database.DropSchema(tableName)
-	err := database.CreateSchema(tableName, []data.Row{sampleRow})
+	err := database.CreateSchema(tableName, nil, []data.Row{sampleRow})
 	assert.NoError(t, err)
 
 	// Test return type
 	schema, err := GetSchema(ctx, tableName)
 
-	// Should return data.Row and no error
+	// Should return data.Schema and no error
 	assert.NoError(t, err)
-	assert.IsType(t, data.Row{}, schema)
+	assert.IsType(t, data.Schema{}, schema)
 	assert.NotNil(t, schema)
 
 	// Clean up
@@ -245,7 +230,7 @@ func TestGetSchema_ConsistencyAcrossMultipleCalls(t *testing.T) {
 
 	// Clean up and create table
 	_ = database.DropSchema(tableName)
-	err := database.CreateSchema(tableName, []data.Row{sampleRow})
+	err := database.CreateSchema(tableName, nil, []data.Row{sampleRow})
 	assert.NoError(t, err)
 
 	// Call GetSchema multiple times
@@ -0,0 +1,88 @@
+package apiimpl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jbirtley88/gremel/adapter"
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+)
+
+// Sources which read the data from stdin, e.g. 'kubectl get pods -o json | gremel -m pods=stdin:json'.
+// The format can be given after 'stdin:', or as the 'format' option.
+const (
+	StdinSource = "-"
+	StdinPrefix = "stdin:"
+)
+
+// Only used for testing - allows us to mock out stdin
+var (
+	stdin     io.Reader = os.Stdin
+	stdinUsed sync.Mutex
+	stdinRead bool
+)
+
+// IsStdinSource reports whether source is '-' or 'stdin:[FORMAT]'
+func IsStdinSource(source string) bool {
+	return source == StdinSource || strings.HasPrefix(source, StdinPrefix)
+}
+
+// MountStdin mounts whatever is piped into stdin.
+//
+// There's no file extension to go on, and sniffing isn't always right about
+// e.g. a CSV file with one column, so the format has to be given.  Since stdin
+// can only be read once, neither can it be mounted twice or refreshed.
+func MountStdin(ctx data.GremelContext, name string, source string) error {
+	format := strings.TrimPrefix(source, StdinPrefix)
+	if source == StdinSource {
+		format = ""
+	}
+	if format == "" {
+		format = ctx.Values().GetString("format")
+	}
+	if format == "" {
+		return fmt.Errorf("MountStdin(%s): a format is needed for stdin, e.g. '%sjson' or format=csv", source, StdinPrefix)
+	}
+
+	stdinUsed.Lock()
+	defer stdinUsed.Unlock()
+	if stdinRead {
+		return fmt.Errorf("MountStdin(%s): stdin has already been read", source)
+	}
+	stdinRead = true
+
+	stdinCtx := data.NewGremelContext(ctx.Context(), ctx.Values())
+	stdinCtx.Values().SetValue("format", format)
+	body, closer, err := adapter.Decompressed(stdin)
+	if err != nil {
+		return fmt.Errorf("MountStdin(%s): %w", source, err)
+	}
+	defer closer.Close()
+	head, err := adapter.PeekHead(body)
+	if err != nil {
+		return fmt.Errorf("MountStdin(%s): %w", source, err)
+	}
+	parser, err := adapter.ResolveParser(stdinCtx, "", "", head)
+	if err != nil {
+		return fmt.Errorf("MountStdin(%s): %w", source, err)
+	}
+
+	database := db.GetGremelDB()
+	err = adapter.CreateTableFromReader(stdinCtx, database, name, body, parser)
+	if err != nil {
+		return fmt.Errorf("MountStdin(%s): %w", source, err)
+	}
+	err = database.Mount(name, source)
+	if err != nil {
+		return fmt.Errorf("MountStdin(%s): %w", source, err)
+	}
+	err = mountChildTables(stdinCtx, database, name, source)
+	if err != nil {
+		return fmt.Errorf("MountStdin(%s): %w", source, err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package apiimpl
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withStdin stands in for whatever was piped into gremel
+func withStdin(t *testing.T, input string) {
+	stdin = strings.NewReader(input)
+	stdinRead = false
+	t.Cleanup(func() {
+		stdin = nil
+		stdinRead = true
+	})
+}
+
+func TestMountStdin(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+
+	withStdin(t, `[{"name": "web-1", "ready": true}, {"name": "web-2", "ready": false}]`)
+	require.NoError(t, Mount(ctx, "stdin_pods", "stdin:json"))
+	assert.Equal(t, int64(2), countRows(t, ctx, "stdin_pods"))
+
+	withStdin(t, "name,age\nAlice,30\n")
+	require.NoError(t, MountWithOptions(ctx, "stdin_people", StdinSource, map[string]string{"format": "csv"}))
+	assert.Equal(t, int64(1), countRows(t, ctx, "stdin_people"))
+
+	mountInfo, err := GetMount(ctx, "stdin_people")
+	require.NoError(t, err)
+	assert.Equal(t, StdinSource, mountInfo["stdin_people"])
+}
+
+func TestMountStdinErrors(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	withStdin(t, "name,age\nAlice,30\n")
+
+	// No format
+	assert.Error(t, Mount(ctx, "stdin_unknown", StdinSource))
+
+	// Can't be refreshed or followed
+	assert.Error(t, MountWithOptions(ctx, "stdin_ttl", "stdin:csv", map[string]string{RefreshTTL: "1m"}))
+	assert.Error(t, MountWithOptions(ctx, "stdin_follow", "stdin:csv", map[string]string{Follow: "true"}))
+
+	// Can only be read once
+	require.NoError(t, Mount(ctx, "stdin_once", "stdin:csv"))
+	assert.Error(t, Mount(ctx, "stdin_twice", "stdin:csv"))
+	assert.Error(t, Refresh(ctx, "stdin_once"))
+}
+
+func TestSplitMountSourceStdin(t *testing.T) {
+	source, options, err := SplitMountSource("-?format=csv")
+	require.NoError(t, err)
+	assert.Equal(t, StdinSource, source)
+	assert.Equal(t, map[string]string{"format": "csv"}, options)
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jbirtley88/gremel/adapter"
+	"github.com/jbirtley88/gremel/apiimpl"
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/jbirtley88/gremel/helper"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// The table which the input is mounted as for 'convert --query'
+const convertTable = "input"
+
+// Parquet can only be written by convert (and not, say, by '.mode'), since it
+// isn't something to look at
+const convertFormatParquet = "parquet"
+
+var convertCmd = &cobra.Command{
+	Use:   "convert <input> <output>",
+	Short: "Converts a file from one format to another, e.g. people.xlsx to people.json",
+	Long: `Converts a file from one format to another, e.g. people.xlsx to people.json.
+
+The input is read with the same parsers as '.mount' (so it takes the same
+options, e.g. 'people.txt?format=csv&csv.delimiter=;'), and the output format
+goes with its extension (or --format).  An output of '-' is stdout.
+
+A single file is converted a row at a time.  With --query, the input is
+mounted as the table 'input' and the results of the query are written instead.`,
+	Args: cobra.ExactArgs(2),
+	Run:  RunConvert,
+}
+
+var convertSheet string
+var convertQuery string
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+	convertCmd.Flags().StringVar(&convertSheet, "sheet", "", "The worksheet to read from an Excel input (and the name of the worksheet in an Excel output)")
+	convertCmd.Flags().StringVar(&convertQuery, "query", "", "SQL to run against the input (mounted as the table 'input'), whose results are written instead")
+}
+
+func RunConvert(cmd *cobra.Command, args []string) {
+	format := ""
+	if cmd.Flags().Changed("format") {
+		format = outputFormat
+	}
+	err := convert(args[0], args[1], format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(ExitError)
+	}
+}
+
+func convert(input string, output string, format string) error {
+	source, options, err := apiimpl.SplitMountSource(input)
+	if err != nil {
+		return fmt.Errorf("convert(%s): %w", input, err)
+	}
+	if options == nil {
+		options = make(map[string]string)
+	}
+	if convertSheet != "" {
+		options["excel.sheetname"] = convertSheet
+	}
+	ctx := data.NewGremelContext(context.Background())
+	for key, value := range options {
+		ctx.Values().SetValue(key, value)
+	}
+
+	if convertQuery == "" {
+		if fi, err := os.Stat(source); err == nil && fi.Mode().IsRegular() {
+			rows, err := adapter.StreamFile(ctx, source)
+			if err == nil {
+				err = writeConverted(output, format, rows.Headings, rows)
+				rows.Close()
+				if err == nil {
+					return nil
+				}
+				// A file can be written again from the start, once the
+				// input has been mounted, but stdout can't
+				if !errors.Is(err, errLateColumn) || output == "-" {
+					return fmt.Errorf("convert(%s): %w", input, err)
+				}
+				log.Infof("convert(%s): %v, so mounting it instead", input, err)
+			} else if !errors.Is(err, adapter.ErrNotStreamable) {
+				return fmt.Errorf("convert(%s): %w", input, err)
+			}
+		}
+	}
+
+	// Anything else (a URL, an archive, a directory and so on) is mounted
+	// first, which also lets us run the query.  It goes in a database of its
+	// own, rather than the workspace in --db.
+	restoreDB := db.UsePrivateGremelDB()
+	defer restoreDB()
+	silentMode = true
+	if err := apiimpl.MountWithOptions(ctx, convertTable, source, options); err != nil {
+		return fmt.Errorf("convert(%s): %w", input, err)
+	}
+	query := convertQuery
+	if query == "" {
+		query = "SELECT * FROM " + convertTable
+	}
+	rows, columns, err := apiimpl.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("convert(%s): %w", input, err)
+	}
+	return writeConverted(output, format, columns, data.NewRowSliceIterator(rows))
+}
+
+// writeConverted writes every row to output, in the format which goes with
+// its extension (or format, if that is given)
+func writeConverted(output string, format string, columns []string, rows data.RowIterator) error {
+	ext := strings.ToLower(filepath.Ext(output))
+	if format == "" {
+		switch {
+		case output == "-":
+			format = helper.ResultFormatCSV
+		case ext == ".xlsx":
+			return writeConvertedWorkbook(output, columns, rows)
+		case ext == ".parquet":
+			format = convertFormatParquet
+		case outputFormats[ext] != "":
+			format = outputFormats[ext]
+		default:
+			return fmt.Errorf("writeConverted(%s): can't tell what format to write from the extension (use --format)", output)
+		}
+	}
+	var writer helper.ResultWriter = &helper.ParquetWriter{}
+	if format != convertFormatParquet {
+		var err error
+		if writer, err = helper.GetResultWriter(format); err != nil {
+			return fmt.Errorf("writeConverted(%s): %w", output, err)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "-" {
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("writeConverted(%s): %w", output, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	rowWriter, err := helper.StartResults(writer, w, columns, true)
+	if err != nil {
+		return fmt.Errorf("writeConverted(%s): %w", output, err)
+	}
+	if err := copyRows(rowWriter, columns, rows); err != nil {
+		return fmt.Errorf("writeConverted(%s): %w", output, err)
+	}
+	if err := rowWriter.Close(); err != nil {
+		return fmt.Errorf("writeConverted(%s): %w", output, err)
+	}
+	if f, isFile := w.(*os.File); isFile && f != os.Stdout {
+		return f.Close()
+	}
+	return nil
+}
+
+func writeConvertedWorkbook(output string, columns []string, rows data.RowIterator) error {
+	workbook, err := helper.NewExcelWorkbook()
+	if err != nil {
+		return fmt.Errorf("writeConverted(%s): %w", output, err)
+	}
+	defer workbook.Close()
+	sheet, err := workbook.StartSheet(convertSheet, columns, true)
+	if err != nil {
+		return fmt.Errorf("writeConverted(%s): %w", output, err)
+	}
+	if err := copyRows(sheet, columns, rows); err != nil {
+		return fmt.Errorf("writeConverted(%s): %w", output, err)
+	}
+	if err := sheet.Close(); err != nil {
+		return fmt.Errorf("writeConverted(%s): %w", output, err)
+	}
+	return workbook.SaveAs(output)
+}
+
+// errLateColumn is returned by copyRows for a column which wasn't known when
+// the output was started
+var errLateColumn = errors.New("a column first appears after the rows used to work out the columns (see mount.sample)")
+
+// copyRows writes every row to rowWriter, failing with errLateColumn if a row
+// has a value for a column which isn't in columns
+func copyRows(rowWriter helper.RowWriter, columns []string, rows data.RowIterator) error {
+	known := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		known[column] = true
+	}
+	for rows.Next() {
+		row := rows.Row()
+		for column, value := range row {
+			if !known[column] && value != nil {
+				return fmt.Errorf("copyRows(%s): %w", column, errLateColumn)
+			}
+		}
+		if err := rowWriter.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
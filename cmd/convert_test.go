@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jbirtley88/gremel/apiimpl"
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/facade/db"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func resetConvertFlags(t *testing.T) {
+	t.Cleanup(func() {
+		convertSheet = ""
+		convertQuery = ""
+	})
+}
+
+func TestConvertCSVToJSONLines(t *testing.T) {
+	resetConvertFlags(t)
+	output := filepath.Join(t.TempDir(), "accounts.jsonl")
+	require.NoError(t, convert("../test_resources/accounts.csv", output, ""))
+
+	f, err := os.Open(output)
+	require.NoError(t, err)
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	require.True(t, scanner.Scan())
+	var first map[string]any
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &first))
+	// Types are kept
+	assert.Equal(t, float64(1), first["id"])
+	assert.Equal(t, "krawnsley0", first["username"])
+	lines := 1
+	for scanner.Scan() {
+		lines++
+	}
+	assert.Equal(t, 1000, lines)
+}
+
+func TestConvertExcelSheetToWorkbook(t *testing.T) {
+	resetConvertFlags(t)
+	convertSheet = "Sheet1"
+	output := filepath.Join(t.TempDir(), "accounts.xlsx")
+	require.NoError(t, convert("../test_resources/accounts_multiple_sheets.xlsx", output, ""))
+
+	f, err := excelize.OpenFile(output)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, []string{"Sheet1"}, f.GetSheetList())
+	rows, err := f.GetRows("Sheet1")
+	require.NoError(t, err)
+	assert.Greater(t, len(rows), 1)
+}
+
+func TestConvertWithQuery(t *testing.T) {
+	resetConvertFlags(t)
+	convertQuery = "SELECT _source_file, COUNT(*) AS n FROM input GROUP BY _source_file ORDER BY _source_file"
+	output := filepath.Join(t.TempDir(), "counts.txt")
+	// An archive can't be streamed, and the query needs a table anyway
+	require.NoError(t, convert("../test_resources/logs.zip", output, "csv"))
+
+	contents, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "_source_file,n\nlogs/access.log,50\nlogs/access.log.1.gz,20\nlogs/people.csv,10\n", string(contents))
+}
+
+func TestConvertToParquet(t *testing.T) {
+	resetConvertFlags(t)
+	for _, output := range []string{"accounts.parquet", "accounts.pq"} {
+		format := ""
+		if filepath.Ext(output) != ".parquet" {
+			format = convertFormatParquet
+		}
+		path := filepath.Join(t.TempDir(), output)
+		require.NoError(t, convert("../test_resources/accounts.csv", path, format))
+		contents, err := os.ReadFile(path)
+		require.NoError(t, err)
+		file, err := parquet.OpenFile(bytes.NewReader(contents), int64(len(contents)))
+		require.NoError(t, err, output)
+		assert.Equal(t, int64(1000), file.NumRows(), output)
+		assert.Equal(t, "id", file.Schema().Fields()[0].Name(), output)
+	}
+}
+
+func TestConvertColumnAfterTheSample(t *testing.T) {
+	resetConvertFlags(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "late.jsonl")
+	require.NoError(t, os.WriteFile(input, []byte(`{"id":1}
+{"id":2}
+{"id":3,"name":"late"}
+`), 0644))
+
+	// Only the first two rows are sampled, so the file is mounted instead of
+	// losing 'name'
+	output := filepath.Join(dir, "late.csv")
+	require.NoError(t, convert(input+"?mount.sample=2", output, ""))
+	contents, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "id,name\n1,\n2,\n3,late\n", string(contents))
+
+	// stdout can't be started again
+	assert.ErrorIs(t, convert(input+"?mount.sample=2", "-", "json"), errLateColumn)
+}
+
+func TestConvertLeavesTheWorkspaceAlone(t *testing.T) {
+	resetConvertFlags(t)
+	ctx := data.NewGremelContext(context.Background())
+	require.NoError(t, apiimpl.Mount(ctx, convertTable, "../test_resources/people.csv"))
+	before, _, err := db.GetGremelDB().Query("SELECT COUNT(*) AS n FROM " + convertTable)
+	require.NoError(t, err)
+
+	convertQuery = "SELECT COUNT(*) AS n FROM input"
+	output := filepath.Join(t.TempDir(), "count.csv")
+	require.NoError(t, convert("../test_resources/logs.zip", output, ""))
+	contents, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "n\n80\n", string(contents))
+
+	// The workspace's own 'input' is as it was
+	after, _, err := db.GetGremelDB().Query("SELECT COUNT(*) AS n FROM " + convertTable)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+	mounted, err := db.GetGremelDB().GetMount(convertTable)
+	require.NoError(t, err)
+	assert.Equal(t, "../test_resources/people.csv", mounted[convertTable])
+}
+
+func TestConvertErrors(t *testing.T) {
+	resetConvertFlags(t)
+	dir := t.TempDir()
+	assert.Error(t, convert("../test_resources/people.csv", filepath.Join(dir, "people.unknown"), ""))
+	assert.Error(t, convert("../test_resources/no_such_file.csv", filepath.Join(dir, "people.json"), ""))
+}
@@ -41,6 +41,7 @@ func RunDaemon(cmd *cobra.Command, args []string) {
 	// Set the route handlers
 	ginRouter.PUT("/api/v1/mount", api.MountTable)
 	ginRouter.GET("/api/v1/mount", api.GetMount)
+	ginRouter.POST("/api/v1/refresh", api.Refresh)
 	ginRouter.GET("/api/v1/query", api.Query)
 	ginRouter.GET("/api/v1/schema", api.Schema)
 	ginRouter.GET("/api/v1/tables", api.Tables)
@@ -3,8 +3,10 @@ package cmd
 import (
 	"strings"
 
+	"github.com/jbirtley88/gremel/data"
 	"github.com/jbirtley88/gremel/util"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
 func initialise() {
@@ -17,14 +19,15 @@ func initialise() {
 
 	// Override any config from the command-line with '--set foo.bar=123 ...'
 	for _, kv := range configOverrides {
-		fields := strings.Split(kv, "=")
+		fields := strings.SplitN(kv, "=", 2)
 		if len(fields) < 2 {
 			log.Fatalf("'--set' needs a 'name=VALUE' argument")
 		}
-		value := fields[1]
-		if len(fields) > 2 {
-			value = strings.Join(fields[1:len(fields)-1], "=")
-		}
-		log.Infof("Setting config: %s = %s", fields[0], value)
+		log.Infof("Setting config: %s = %s", fields[0], fields[1])
+		viper.Set(fields[0], fields[1])
+	}
+
+	if dbPath != "" {
+		viper.Set(data.CONF_DB, dbPath)
 	}
 }
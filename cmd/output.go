@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/helper"
+)
+
+// outputFormats maps the extension of an '.output' file to the format it is written in
+var outputFormats = map[string]string{
+	".csv":      helper.ResultFormatCSV,
+	".tsv":      helper.ResultFormatTSV,
+	".tab":      helper.ResultFormatTSV,
+	".json":     helper.ResultFormatJSON,
+	".jsonl":    helper.ResultFormatJSONL,
+	".ndjson":   helper.ResultFormatJSONL,
+	".md":       helper.ResultFormatMarkdown,
+	".markdown": helper.ResultFormatMarkdown,
+	".html":     helper.ResultFormatHTML,
+	".htm":      helper.ResultFormatHTML,
+	".txt":      helper.ResultFormatTable,
+}
+
+// fileOutput is a file which query results are written to (see '.output'),
+// in the format which goes with its extension.
+//
+// An Excel workbook gets a worksheet per query, and is only saved when the
+// output is closed.
+type fileOutput struct {
+	path     string
+	file     *os.File
+	writer   helper.ResultWriter
+	workbook *helper.ExcelWorkbook
+}
+
+// openOutput creates (or truncates) path.  If the extension doesn't say what
+// format to write, defaultWriter is used.
+func openOutput(path string, defaultWriter helper.ResultWriter) (*fileOutput, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".xlsx" {
+		workbook, err := helper.NewExcelWorkbook()
+		if err != nil {
+			return nil, fmt.Errorf("openOutput(%s): %w", path, err)
+		}
+		return &fileOutput{path: path, workbook: workbook}, nil
+	}
+
+	writer := defaultWriter
+	if format, known := outputFormats[ext]; known {
+		var err error
+		if writer, err = helper.GetResultWriter(format); err != nil {
+			return nil, fmt.Errorf("openOutput(%s): %w", path, err)
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("openOutput(%s): %w", path, err)
+	}
+	return &fileOutput{path: path, file: file, writer: writer}, nil
+}
+
+// write adds the results of a query to the file, always with the headings
+func (o *fileOutput) write(columns []string, rows []data.Row) error {
+	if o.workbook != nil {
+		return o.workbook.WriteSheet("", columns, rows, true)
+	}
+	return o.writer.WriteResults(o.file, columns, rows, true)
+}
+
+func (o *fileOutput) close() error {
+	if o.workbook != nil {
+		defer o.workbook.Close()
+		return o.workbook.SaveAs(o.path)
+	}
+	if err := o.file.Close(); err != nil {
+		return fmt.Errorf("close(%s): %w", o.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestOutputToFiles(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "results.csv")
+	jsonPath := filepath.Join(dir, "once.json")
+	otherPath := filepath.Join(dir, "results.out")
+
+	session := newSQLSession(data.NewGremelContext(context.Background()))
+	script := strings.Join([]string{
+		".output " + csvPath,
+		"SELECT 1 AS a, 'x, y' AS b;",
+		// Only the next query goes to once.json, then back to results.csv
+		".once " + jsonPath,
+		"SELECT 2 AS a;",
+		"SELECT 3 AS a, 'z' AS b;",
+		// No extension we know, so the current mode is used
+		".mode markdown",
+		".output " + otherPath,
+		"SELECT 4 AS a;",
+		".output",
+	}, "\n")
+	require.NoError(t, session.run(strings.NewReader(script)))
+
+	contents, err := os.ReadFile(csvPath)
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n1,\"x, y\"\na,b\n3,z\n", string(contents))
+	contents, err = os.ReadFile(jsonPath)
+	require.NoError(t, err)
+	assert.Equal(t, "[\n{\"a\":2}\n]\n", string(contents))
+	contents, err = os.ReadFile(otherPath)
+	require.NoError(t, err)
+	assert.Equal(t, "| a |\n|---|\n| 4 |\n", string(contents))
+}
+
+func TestOutputToWorkbook(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.xlsx")
+	session := newSQLSession(data.NewGremelContext(context.Background()))
+	require.NoError(t, session.setOutput(path))
+	require.NoError(t, session.run(strings.NewReader("SELECT 1 AS a, 'one' AS b;\nSELECT 2.5 AS c;\n")))
+
+	f, err := excelize.OpenFile(path)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, []string{"Sheet1", "Sheet2"}, f.GetSheetList())
+	rows, err := f.GetRows("Sheet1")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b"}, {"1", "one"}}, rows)
+	rows, err = f.GetRows("Sheet2")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"c"}, {"2.5"}}, rows)
+}
+
+func TestOnceNeedsAFile(t *testing.T) {
+	session := newSQLSession(data.NewGremelContext(context.Background()))
+	_, err := session.runLine(".once")
+	assert.Error(t, err)
+}
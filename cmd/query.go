@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes for 'gremel query'
+const (
+	// A mount failed, or a statement couldn't be parsed or run
+	ExitError = 1
+	// A query tripped --fail-if-empty or --fail-if-rows
+	ExitFailedQuery = 2
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query [script.sql ...]",
+	Short: "Runs SQL statements (from --execute, script files or stdin) and writes the results to stdout",
+	Long: `Runs SQL statements (from --execute, script files or stdin) and writes the results to stdout.
+
+Unlike the interactive shell, the first failure stops everything and gremel exits
+with status 1 (for a mount, parse or SQL error) or 2 (for --fail-if-empty or
+--fail-if-rows).`,
+	Run: RunQuery,
+}
+
+var failIfEmpty bool
+var failIfRows bool
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+	queryCmd.Flags().BoolVar(&failIfEmpty, "fail-if-empty", false, "Exit with status 2 if a query returns no rows")
+	queryCmd.Flags().BoolVar(&failIfRows, "fail-if-rows", false, "Exit with status 2 if a query returns any rows")
+}
+
+func RunQuery(cmd *cobra.Command, args []string) {
+	err := runQuery(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		var failedQuery *failedQueryError
+		if errors.As(err, &failedQuery) {
+			os.Exit(ExitFailedQuery)
+		}
+		os.Exit(ExitError)
+	}
+}
+
+func runQuery(args []string) error {
+	if failIfEmpty && failIfRows {
+		return fmt.Errorf("--fail-if-empty and --fail-if-rows can't both be given")
+	}
+	// Nothing but the results goes to stdout
+	silentMode = true
+
+	ctx := data.NewGremelContext(context.Background())
+	ctx.Values().SetValue("silent", silentMode)
+	session := newSQLSession(ctx)
+	session.stopOnError = true
+	session.queryMode = true
+	session.failIfEmpty = failIfEmpty
+	session.failIfRows = failIfRows
+	// Catch a bad --format before mounting anything
+	if err := session.setFormat(outputFormat); err != nil {
+		return err
+	}
+	if err := session.setOutput(outputPath); err != nil {
+		return err
+	}
+
+	stdinMounted, err := mountFlags(ctx, true)
+	if err != nil {
+		return err
+	}
+	input, err := sqlInput(args, execute)
+	if err != nil {
+		return err
+	}
+	if input == nil {
+		if stdinMounted {
+			return fmt.Errorf("stdin is mounted as a table, so the SQL has to come from --execute or a script file")
+		}
+		input = os.Stdin
+	}
+	return session.run(input)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newQuerySession() *sqlSession {
+	session := newSQLSession(data.NewGremelContext(context.Background()))
+	session.stopOnError = true
+	session.queryMode = true
+	_ = session.setFormat(helper.ResultFormatCSV)
+	return session
+}
+
+func TestQuerySessionStopsOnError(t *testing.T) {
+	session := newQuerySession()
+	err := session.run(strings.NewReader(".mount query_people ../test_resources/people.csv\nSELECT * FROM no_such_table;\nSELECT 1;\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no_such_table")
+
+	err = newQuerySession().run(strings.NewReader("DELETE FROM query_people;"))
+	assert.Error(t, err)
+}
+
+func TestQuerySessionLastStatementWithoutSemicolon(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.csv")
+	session := newQuerySession()
+	require.NoError(t, session.run(strings.NewReader(".output "+path+"\nSELECT 1 AS a -- the first\n, 2 AS b /* and the\nsecond */")))
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n1,2\n", string(contents))
+
+	err = newQuerySession().run(strings.NewReader("SELECT 1;\n-- harmless\nDELETE FROM query_people"))
+	assert.ErrorContains(t, err, "only read-only statements")
+}
+
+func TestQuerySessionFailIf(t *testing.T) {
+	session := newQuerySession()
+	session.failIfEmpty = true
+	require.NoError(t, session.run(strings.NewReader("SELECT 1")))
+	err := session.run(strings.NewReader("SELECT 1 WHERE 1 = 0"))
+	var failedQuery *failedQueryError
+	require.True(t, errors.As(err, &failedQuery))
+
+	session = newQuerySession()
+	session.failIfRows = true
+	require.NoError(t, session.run(strings.NewReader("SELECT 1 WHERE 1 = 0")))
+	err = session.run(strings.NewReader("SELECT 1 UNION SELECT 2"))
+	require.True(t, errors.As(err, &failedQuery))
+	assert.Contains(t, err.Error(), "returned 2 rows")
+}
+
+func TestModeCommand(t *testing.T) {
+	session := newSQLSession(data.NewGremelContext(context.Background()))
+	_, err := session.runLine(".mode markdown")
+	require.NoError(t, err)
+	assert.Equal(t, helper.ResultFormatMarkdown, session.format)
+
+	_, err = session.runLine(".mode xml")
+	assert.Error(t, err)
+	assert.Equal(t, helper.ResultFormatMarkdown, session.format)
+}
@@ -5,8 +5,10 @@ import (
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "gremel",
+	Use:   "gremel [script.sql ...]",
 	Short: "Gremel is a swiss-army knife for interrogating disparate data sources with SQL",
+	// Anything which isn't a subcommand is a script to run
+	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		RunSQL(cmd, args)
 	},
@@ -23,8 +25,10 @@ func Execute() error {
 
 var cfgFile string
 var configOverrides []string
+var dbPath string
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "f", "config.yml", "config file (default is config.yml)")
 	rootCmd.PersistentFlags().StringArrayVarP(&configOverrides, "set", "s", []string{}, "Override config values (e.g. 'config.loglevel=debug')")
+	rootCmd.PersistentFlags().StringVar(&dbPath, "db", "", "SQLite file in which to keep mounted tables across restarts (default is in-memory)")
 }
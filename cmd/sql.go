@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"strings"
 	"text/tabwriter"
 
@@ -18,25 +19,27 @@ import (
 
 // sqlCmd represents the sql command
 var sqlCmd = &cobra.Command{
-	Use:   "sql",
-	Short: "Runs an interactive SQL shell",
+	Use:   "sql [script.sql ...]",
+	Short: "Runs an interactive SQL shell, or the statements in script files",
 	Run:   RunSQL,
 }
 
 var silentMode bool
 var mount []string
+var execute []string
+var outputFormat string
+var outputPath string
 
 func init() {
 	rootCmd.AddCommand(sqlCmd)
 	rootCmd.PersistentFlags().BoolVarP(&silentMode, "silent", "q", false, "Silent mode - suppress output except for query results")
 	rootCmd.PersistentFlags().StringArrayVarP(&mount, "mount", "m", []string{}, "Mount a data source in the format tablename=path (can be specified multiple times)")
+	rootCmd.PersistentFlags().StringArrayVarP(&execute, "execute", "e", []string{}, "Run this SQL (or dot-command) instead of reading from stdin (can be specified multiple times)")
+	rootCmd.PersistentFlags().StringVarP(&outputPath, "output", "o", "", "Write query results to this file, in the format which goes with its extension (e.g. .csv, .json, .xlsx)")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "format", helper.ResultFormatTable, "How to write query results: "+strings.Join(helper.ResultWriterNames(), ", "))
 }
 
 func RunSQL(cmd *cobra.Command, args []string) {
-
-	if !silentMode {
-		fmt.Println("Type '.help' or '?' for help")
-	}
 	err := runSQL(args)
 	if err != nil {
 		log.Errorf("%s: Error running SQL command: %v", cmd.Name(), err)
@@ -46,34 +49,184 @@ func RunSQL(cmd *cobra.Command, args []string) {
 func runSQL(args []string) error {
 	ctx := data.NewGremelContext(context.Background())
 	ctx.Values().SetValue("silent", silentMode)
-	// First, validate the mount args
-	// Mount args must be in the format tablename=path
+	session := newSQLSession(ctx)
+	if err := session.setFormat(outputFormat); err != nil {
+		return err
+	}
+	if err := session.setOutput(outputPath); err != nil {
+		return err
+	}
+	stdinMounted, err := mountFlags(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	// The statements come from the script files and --execute, or else stdin
+	input, err := sqlInput(args, execute)
+	if err != nil {
+		return err
+	}
+	if input == nil {
+		if stdinMounted {
+			return fmt.Errorf("stdin is mounted as a table, so the SQL has to come from --execute or a script file")
+		}
+		input = os.Stdin
+		session.interactive = true
+		if !silentMode {
+			fmt.Println("Type '.help' or '?' for help")
+		}
+	}
+	return session.run(input)
+}
+
+// mountFlags mounts the tables given with --mount, and reports whether one of
+// them was stdin.  Unless stopOnError, a failed mount is reported and the
+// rest are carried on with.
+func mountFlags(ctx data.GremelContext, stopOnError bool) (bool, error) {
+	// Mount args must be in the format tablename=path, optionally followed by
+	// options as a query string (tablename=path?key=value&...)
+	stdinMounted := false
 	for _, m := range mount {
 		parts := strings.SplitN(m, "=", 2)
 		if len(parts) != 2 {
-			return fmt.Errorf("invalid --mount argument: %s (must be in the format tablename=path)", m)
+			return false, fmt.Errorf("invalid --mount argument: %s (must be in the format tablename=path)", m)
 		}
+		source, options, err := apiimpl.SplitMountSource(parts[1])
+		if err != nil {
+			return false, fmt.Errorf("invalid --mount argument: %s: %w", m, err)
+		}
+		stdinMounted = stdinMounted || apiimpl.IsStdinSource(source)
+		tokens := []string{".mount", parts[0], source}
+		for key, value := range options {
+			tokens = append(tokens, key+"="+value)
+		}
+		if err := doMount(ctx, tokens); err != nil {
+			if stopOnError {
+				return false, err
+			}
+			fmt.Printf("Error: %v\n", err)
+		}
+	}
+	return stdinMounted, nil
+}
+
+// sqlSession runs dot-commands and SQL statements, one line at a time
+type sqlSession struct {
+	ctx data.GremelContext
+	// Prompt for each line, and keep going until told to quit
+	interactive bool
+	// Return the first error, rather than reporting it and carrying on
+	stopOnError bool
+	// How the results are written, e.g. 'csv' (see '.mode')
+	format string
+	writer helper.ResultWriter
+	// Where the results go instead of stdout (see '.output'), and where just
+	// the next query's results go (see '.once')
+	output     *fileOutput
+	onceOutput *fileOutput
+	// Only write the results (always with headings), for 'gremel query'
+	queryMode bool
+	// Turn queries which do (or don't) return rows into errors
+	failIfEmpty bool
+	failIfRows  bool
+
+	sqlBuffer []string
+}
 
-		doMount(ctx, []string{".mount", parts[0], parts[1]})
+func newSQLSession(ctx data.GremelContext) *sqlSession {
+	s := &sqlSession{ctx: ctx}
+	_ = s.setFormat(helper.ResultFormatTable)
+	return s
+}
+
+// setOutput closes the current output file (if any) and sends results to
+// path instead, or back to stdout if path is empty
+func (s *sqlSession) setOutput(path string) error {
+	var err error
+	if s.output != nil {
+		err = s.output.close()
+		s.output = nil
+	}
+	if path == "" || path == "stdout" {
+		return err
+	}
+	output, openErr := openOutput(path, s.writer)
+	if openErr != nil {
+		return openErr
 	}
+	s.output = output
+	return err
+}
+
+// setOnce sends the results of the next query (only) to path
+func (s *sqlSession) setOnce(path string) error {
+	err := s.closeOnce()
+	output, openErr := openOutput(path, s.writer)
+	if openErr != nil {
+		return openErr
+	}
+	s.onceOutput = output
+	return err
+}
 
-	// Read one line of text at a time from stdin
-	reader := bufio.NewReader(os.Stdin)
+func (s *sqlSession) closeOnce() error {
+	if s.onceOutput == nil {
+		return nil
+	}
+	err := s.onceOutput.close()
+	s.onceOutput = nil
+	return err
+}
+
+// setFormat picks the result writer which query results are written with
+func (s *sqlSession) setFormat(format string) error {
+	writer, err := helper.GetResultWriter(format)
+	if err != nil {
+		return err
+	}
+	s.format = strings.ToLower(format)
+	s.writer = writer
+	return nil
+}
+
+// run reads and runs every line of input, then closes any '.output' file
+func (s *sqlSession) run(input io.Reader) error {
+	err := s.runLines(input)
+	if closeErr := s.closeOnce(); err == nil {
+		err = closeErr
+	}
+	if closeErr := s.setOutput(""); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *sqlSession) runLines(input io.Reader) error {
+	reader := bufio.NewReader(input)
 	prompt := "gremel> "
-	var sqlBuffer []string
 	for {
 		// Use continuation prompt if we're building a multi-line SQL statement
 		currentPrompt := prompt
-		if len(sqlBuffer) > 0 {
+		if len(s.sqlBuffer) > 0 {
 			currentPrompt = "    ...> "
 		}
 
-		if !silentMode {
+		if s.interactive && !silentMode {
 			fmt.Print(currentPrompt)
 		}
 		line, err := reader.ReadString('\n')
-		if err != nil {
+		if err != nil && !(err == io.EOF && line != "") {
 			if err == io.EOF {
+				if !s.interactive {
+					// The last statement doesn't need a ';', but otherwise
+					// goes through the same checks as the others
+					lastSQL, err := helper.ProcessNextSQLLine(s.ctx, ";", &s.sqlBuffer)
+					s.sqlBuffer = nil
+					if err != nil {
+						return s.report(err)
+					}
+					return s.report(s.executeSQL(lastSQL))
+				}
 				if !silentMode {
 					fmt.Println("\nExiting...")
 				}
@@ -81,60 +234,133 @@ func runSQL(args []string) error {
 			}
 			return err
 		}
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+
+		quit, err := s.runLine(strings.TrimSpace(line))
+		if err := s.report(err); err != nil {
+			return err
 		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// report returns err if the session stops on errors, and otherwise prints it
+func (s *sqlSession) report(err error) error {
+	if err == nil || s.stopOnError {
+		return err
+	}
+	fmt.Printf("Error: %v\n", err)
+	return nil
+}
 
-		// Process the command
-		tokens := strings.Split(line, " ")
-		firstToken := strings.ToLower(tokens[0])
+// runLine runs a single line, returning true if it was a request to quit
+func (s *sqlSession) runLine(line string) (bool, error) {
+	if line == "" {
+		return false, nil
+	}
+
+	// Process the command.  Dot-commands are split the way a shell would,
+	// so that e.g. an 'exec:' command can be quoted
+	tokens := strings.Split(line, " ")
+	if strings.HasPrefix(line, ".") {
+		var err error
+		tokens, err = helper.SplitWords(line)
+		if err != nil {
+			return false, err
+		}
+	}
+	ctx := s.ctx
+	firstToken := strings.ToLower(tokens[0])
 
-		switch firstToken {
-		case "exit", "quit", ".exit", ".quit", ".q":
+	switch firstToken {
+	case "exit", "quit", ".exit", ".quit", ".q":
+		if !silentMode {
 			fmt.Println("Exiting...")
-			return nil
-		case ".mount":
-			err := doMount(ctx, tokens)
-			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-			}
-		case ".help", "help", "?":
-			err := doHelp(ctx, tokens)
-			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-			}
-		case ".tables":
-			err := doTables(ctx, tokens)
-			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-			}
-		case ".schema":
-			err := doSchema(ctx, tokens)
-			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-			}
-		case ".silent":
-			err := doSilent(ctx, tokens)
-			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-			}
-		default:
-			// Handle SQL statements
-			completeSQL, err := helper.ProcessNextSQLLine(ctx, line, &sqlBuffer)
-			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-				sqlBuffer = []string{} // Reset buffer on error
-			}
-			err = executeSQL(ctx, completeSQL)
-			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-				sqlBuffer = []string{} // Reset buffer on error
-			}
 		}
+		return true, nil
+	case ".mount":
+		return false, doMount(ctx, tokens)
+	case ".help", "help", "?":
+		return false, doHelp(ctx, tokens)
+	case ".tables":
+		return false, doTables(ctx, tokens)
+	case ".schema":
+		return false, doSchema(ctx, tokens)
+	case ".refresh":
+		return false, doRefresh(ctx, tokens)
+	case ".save":
+		return false, doSave(ctx, tokens)
+	case ".silent":
+		return false, doSilent(ctx, tokens)
+	case ".mode":
+		return false, s.doMode(tokens)
+	case ".output", ".once":
+		return false, s.doOutput(tokens)
+	default:
+		// Handle SQL statements
+		completeSQL, err := helper.ProcessNextSQLLine(ctx, line, &s.sqlBuffer)
+		if err != nil {
+			s.sqlBuffer = []string{} // Reset buffer on error
+			return false, err
+		}
+		return false, s.executeSQL(completeSQL)
 	}
 }
 
+// sqlInput reads the statements in the script files, followed by any given
+// with --execute.  It returns nil if there are neither, for stdin to be used.
+func sqlInput(scripts []string, statements []string) (io.Reader, error) {
+	if len(scripts) == 0 && len(statements) == 0 {
+		return nil, nil
+	}
+	var buffer strings.Builder
+	for _, script := range scripts {
+		contents, err := os.ReadFile(script)
+		if err != nil {
+			return nil, fmt.Errorf("error reading script: %w", err)
+		}
+		buffer.Write(contents)
+		buffer.WriteString("\n")
+	}
+	for _, statement := range statements {
+		// Each one is a statement of its own, whether or not it ends in ';'
+		statement = strings.TrimSpace(statement)
+		if !strings.HasPrefix(statement, ".") && !strings.HasSuffix(statement, ";") {
+			statement += ";"
+		}
+		buffer.WriteString(statement + "\n")
+	}
+	return strings.NewReader(buffer.String()), nil
+}
+
+// doMode handles the .mode command
+func (s *sqlSession) doMode(tokens []string) error {
+	switch len(tokens) {
+	case 1:
+		fmt.Printf("%s (one of %s)\n", s.format, strings.Join(helper.ResultWriterNames(), ", "))
+		return nil
+	case 2:
+		return s.setFormat(tokens[1])
+	}
+	return fmt.Errorf("usage: .mode [%s]", strings.Join(helper.ResultWriterNames(), "|"))
+}
+
+// doOutput handles the .output and .once commands
+func (s *sqlSession) doOutput(tokens []string) error {
+	switch {
+	case strings.ToLower(tokens[0]) == ".once" && len(tokens) == 2:
+		return s.setOnce(tokens[1])
+	case strings.ToLower(tokens[0]) == ".once":
+		return fmt.Errorf("usage: .once <file_path>")
+	case len(tokens) == 1:
+		return s.setOutput("")
+	case len(tokens) == 2:
+		return s.setOutput(tokens[1])
+	}
+	return fmt.Errorf("usage: .output [<file_path>]")
+}
+
 // doSilent handles the .silent command
 func doSilent(ctx data.GremelContext, tokens []string) error {
 	if len(tokens) != 2 {
@@ -154,16 +380,49 @@ func doSilent(ctx data.GremelContext, tokens []string) error {
 	return nil
 }
 
+// doRefresh handles the .refresh command
+func doRefresh(ctx data.GremelContext, tokens []string) error {
+	switch len(tokens) {
+	case 1:
+		tables, err := apiimpl.RefreshAll(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Refreshed %d mounts\n", len(tables))
+		return nil
+	case 2:
+		return apiimpl.Refresh(ctx, tokens[1])
+	}
+	return fmt.Errorf("usage: .refresh [tablename]")
+}
+
+// doSave handles the .save command
+func doSave(ctx data.GremelContext, tokens []string) error {
+	if len(tokens) != 2 {
+		return fmt.Errorf("usage: .save <file_path>")
+	}
+	if err := apiimpl.SaveDatabase(ctx, tokens[1]); err != nil {
+		return err
+	}
+	fmt.Printf("Saved to %s (reopen with '--db %s')\n", tokens[1], tokens[1])
+	return nil
+}
+
 func doHelp(ctx data.GremelContext, tokens []string) error {
 	w := tabwriter.NewWriter(os.Stdout, 1, 1, 4, ' ', 0)
 	w.Write([]byte("Available commands:\n"))
 	w.Write([]byte(".help\tShow this help message\n"))
 	w.Write([]byte(".quit or .exit or .q\tExit the shell\n"))
-	w.Write([]byte(".mount [tablename [<file_path>]]\tMount a data source\n"))
+	w.Write([]byte(".mount [tablename [<file_path> [key=value ...]]]\tMount a data source, e.g. format=csv\n"))
+	w.Write([]byte(".refresh [tablename]\tRe-read a mounted table (or every mount) from its source\n"))
 	w.Write([]byte(".tables\tList all tables\n"))
 	w.Write([]byte(".schema <tablename>\tShow schema of a table\n"))
+	w.Write([]byte(".save <file_path>\tSave all tables and mounts to an SQLite file\n"))
 	w.Write([]byte(".headings on|off\tEnable or disable column headings\n"))
 	w.Write([]byte(".silent on|off\tEnable or disable silent mode\n"))
+	w.Write([]byte(".output [<file_path>]\tWrite query results to a file (e.g. .csv, .json, .xlsx), or back to stdout\n"))
+	w.Write([]byte(".once <file_path>\tWrite the results of the next query to a file\n"))
+	w.Write([]byte(".mode [format]\tShow or set how query results are written, e.g. csv, json, markdown or box\n"))
 	w.Write([]byte("SELECT ...;\tExecute a SQL SELECT statement\n"))
 	w.Flush()
 	return nil
@@ -196,23 +455,32 @@ func doMount(ctx data.GremelContext, tokens []string) error {
 		}
 		return nil
 
-	case 3:
-		// .mount NAME /path/to/file
-		err := apiimpl.Mount(ctx, tokens[1], tokens[2])
+	default:
+		// .mount NAME /path/to/file [key=value ...]
+		options, err := apiimpl.ParseMountOptions(tokens[3:])
+		if err != nil {
+			return fmt.Errorf("usage: .mount [tablename [<file_path> [key=value ...]]]")
+		}
+		err = apiimpl.MountWithOptions(ctx, tokens[1], tokens[2], options)
 		if err != nil {
 			return fmt.Errorf("error mounting file: %w", err)
 		}
 		if !silentMode {
 			fmt.Printf("Mounted %s as %s\n", tokens[2], tokens[1])
-			doSchema(ctx, tokens[0:2])
+			// e.g. 'book.xlsx' with excel.sheets=all is only a set of tables
+			if _, err := apiimpl.GetSchema(ctx, tokens[1]); err == nil {
+				doSchema(ctx, tokens[0:2])
+			}
+			mountInfo, _ := apiimpl.GetMount(ctx, tokens[1])
+			if tables, hasTables := mountInfo["tables"].([]string); hasTables {
+				for _, table := range tables {
+					fmt.Printf("\n%s:\n", table)
+					doSchema(ctx, []string{".schema", table})
+				}
+			}
 		}
 		return nil
-
-	default:
-		return fmt.Errorf("usage: .mount [tablename [<file_path>]]")
 	}
-
-	// TODO: Support for mounting http:// and https:// URLs as data sources
 }
 
 // doTables handles the .tables command
@@ -234,67 +502,72 @@ func doSchema(ctx data.GremelContext, tokens []string) error {
 		os.Stderr.WriteString(fmt.Sprintf("Error getting schema: %v\n", err))
 		return err
 	}
-	for k, v := range schema {
-		fmt.Printf("%s: %s\n", k, v)
+	for _, column := range schema {
+		fmt.Printf("%s: %s\n", column.Name, column.Type)
 	}
 	return nil
 }
 
-// separator returns a string of hyphens with the same length as the input string
-func separator(s string) string {
-	return strings.Repeat("-", len(s))
-}
-
 // executeSQL executes a complete SQL statement
-func executeSQL(ctx data.GremelContext, sql string) error {
+func (s *sqlSession) executeSQL(sql string) error {
 	sql = strings.TrimSpace(sql)
 	if sql == "" {
 		return nil
 	}
 
-	// TODO: Implement actual SQL execution using the database
-	if ctx.Values().GetBool("verbose") {
+	if s.ctx.Values().GetBool("verbose") {
 		fmt.Printf("Executing SQL: %s\n", sql)
 	}
-	rows, columns, err := apiimpl.Query(data.NewGremelContext(context.Background()), sql)
+	if s.onceOutput != nil {
+		// Whether or not the query works
+		defer s.closeOnce()
+	}
+	// The session's values (e.g. 'silent') go along with the query, and to
+	// any refresh which it sets off
+	queryCtx := s.ctx.Context()
+	if s.interactive {
+		// Ctrl-C cancels the query, rather than quitting
+		var stop context.CancelFunc
+		queryCtx, stop = signal.NotifyContext(queryCtx, os.Interrupt)
+		defer stop()
+	}
+	rows, columns, err := apiimpl.Query(data.NewGremelContext(queryCtx, s.ctx.Values()), sql)
 	if err != nil {
 		return fmt.Errorf("executeSQL(): %w", err)
 	}
 
-	// Print results in tabular format
-	// Use tabwriter for aligned columns
-	w := tabwriter.NewWriter(os.Stdout, 1, 1, 4, ' ', 0)
-
-	// Column names
-	if !silentMode {
-		if len(columns) > 0 {
-			for _, col := range columns {
-				_, _ = w.Write([]byte(col + "\t"))
-			}
-			_, _ = w.Write([]byte("\n"))
-
-			// Heading separator
-			for _, col := range columns {
-				_, _ = w.Write([]byte(separator(col) + "\t"))
-			}
-			_, _ = w.Write([]byte("\n"))
+	if s.onceOutput != nil {
+		err = s.onceOutput.write(columns, rows)
+		if err == nil {
+			err = s.closeOnce()
 		}
+	} else if s.output != nil {
+		err = s.output.write(columns, rows)
+	} else {
+		err = s.writer.WriteResults(os.Stdout, columns, rows, s.queryMode || !silentMode)
 	}
-
-	// Process rows
-	if len(rows) > 0 {
-		for _, row := range rows {
-			for _, col := range columns {
-				valueAsString := fmt.Sprint(row[col])
-				_, _ = w.Write([]byte(valueAsString + "\t"))
-			}
-			_, _ = w.Write([]byte("\n"))
-		}
-		_ = w.Flush()
+	if err != nil {
+		return fmt.Errorf("executeSQL(): %w", err)
 	}
-
-	if !silentMode {
+	if !s.queryMode && !silentMode {
 		fmt.Printf("%d rows\n", len(rows))
 	}
+
+	if s.failIfEmpty && len(rows) == 0 {
+		return &failedQueryError{sql: sql, reason: "returned no rows"}
+	}
+	if s.failIfRows && len(rows) > 0 {
+		return &failedQueryError{sql: sql, reason: fmt.Sprintf("returned %d rows", len(rows))}
+	}
 	return nil
 }
+
+// failedQueryError is a query which tripped --fail-if-empty or --fail-if-rows
+type failedQueryError struct {
+	sql    string
+	reason string
+}
+
+func (e *failedQueryError) Error() string {
+	return fmt.Sprintf("query %s: %s", e.reason, e.sql)
+}
@@ -2,4 +2,21 @@ package data
 
 const (
 	CONF_LOGLEVEL = "config.loglevel"
+
+	// SQLite file in which the mounted tables are kept (in-memory if not set)
+	CONF_DB = "config.db"
+
+	// Number of rows read up-front to infer the schema when mounting a table
+	CONF_MOUNT_SAMPLE = "config.mount.sample"
+	// Number of rows inserted per transaction when mounting a table
+	CONF_MOUNT_BATCHSIZE = "config.mount.batchsize"
+
+	// The longest a query may run for before it is cancelled (e.g. '30s'),
+	// or no limit if it isn't set
+	CONF_QUERY_TIMEOUT = "config.query.timeout"
+
+	// How long an 'exec:' mount's command may run for (e.g. '30s')
+	CONF_EXEC_TIMEOUT = "config.exec.timeout"
+	// 'exec:' mounts are only allowed through the REST API if this is true
+	CONF_API_EXEC = "config.api.exec"
 )
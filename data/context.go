@@ -2,9 +2,16 @@ package data
 
 import (
 	"context"
+	"time"
 )
 
+// GremelContext carries the hints (e.g. 'log.format', 'excel.sheetname')
+// which steer the parsers, alongside a regular context.Context.
+//
+// It is itself a context.Context, so it can be handed to anything which
+// expects one - such as the constructors in the ParserRegistry.
 type GremelContext interface {
+	context.Context
 	Context() context.Context
 	Values() Metadata
 }
@@ -21,6 +28,18 @@ func NewGremelContext(ctx context.Context, baseline ...Metadata) GremelContext {
 	}
 }
 
+// ToGremelContext returns ctx if it is already a GremelContext, otherwise it
+// wraps ctx in a new GremelContext with no values
+func ToGremelContext(ctx context.Context) GremelContext {
+	if gremelContext, isGremelContext := ctx.(GremelContext); isGremelContext {
+		return gremelContext
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return NewGremelContext(ctx)
+}
+
 func (c *GremelContextImpl) Context() context.Context {
 	return c.ctx
 }
@@ -28,3 +47,19 @@ func (c *GremelContextImpl) Context() context.Context {
 func (c *GremelContextImpl) Values() Metadata {
 	return c.values
 }
+
+func (c *GremelContextImpl) Deadline() (time.Time, bool) {
+	return c.ctx.Deadline()
+}
+
+func (c *GremelContextImpl) Done() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+func (c *GremelContextImpl) Err() error {
+	return c.ctx.Err()
+}
+
+func (c *GremelContextImpl) Value(key any) any {
+	return c.ctx.Value(key)
+}
@@ -29,3 +29,74 @@ type Parser interface {
 	GetHeadings(rows []Row) []string
 	GetName() string
 }
+
+// StreamParser is implemented by parsers which can hand out rows one at a time,
+// rather than materialising every row in a RowList.
+//
+// Parsers opt in simply by implementing ParseStream - see NewRowFuncIterator
+// for the easiest way of doing so.
+type StreamParser interface {
+	Parser
+	ParseStream(input io.Reader) (RowIterator, error)
+}
+
+// ChildTableParser is implemented by parsers which split nested data out into
+// tables of their own, e.g. arrays of objects within JSON rows.
+//
+// ChildTables is only complete once every row has been read from the parser.
+type ChildTableParser interface {
+	Parser
+	ChildTables() []ChildTable
+}
+
+// ChildTable is a table of rows which were split out of the parent table.
+// Name is appended to the parent table name, e.g. 'items' for 'orders__items'
+type ChildTable struct {
+	Name string
+	// The column names, in the order they were first seen
+	Columns []string
+	Rows    []Row
+}
+
+// MultiTableParser is implemented by parsers which can turn a single input
+// into several tables, e.g. one per Excel worksheet.
+type MultiTableParser interface {
+	Parser
+	// MultiTable is true if the context has asked for more than one table
+	MultiTable() bool
+	// ParseTables returns the rows for each table.  Every one of the
+	// iterators must be closed.
+	ParseTables(input io.Reader) ([]TableRows, error)
+}
+
+// TableRows are the rows for one of the tables from a MultiTableParser.
+// Name is appended to the table name, e.g. 'Sheet1' for 'book_Sheet1'
+type TableRows struct {
+	Name string
+	// The order of the columns, if the parser knows it.  It can grow as the
+	// rows are read, e.g. for cells beyond the last heading.
+	Columns *ColumnOrder
+	Rows    RowIterator
+}
+
+// ParseStream returns the rows from the parser as a RowIterator.
+//
+// Parsers which implement StreamParser are used natively.  Anything else is
+// parsed into a RowList, which is then iterated over.
+func ParseStream(parser Parser, input io.Reader) (RowIterator, error) {
+	if streamParser, isStreamParser := parser.(StreamParser); isStreamParser {
+		return streamParser.ParseStream(input)
+	}
+
+	rows, err := parser.Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	if rows == nil {
+		return NewRowSliceIterator(nil), nil
+	}
+	if rows.Err != nil {
+		return nil, rows.Err
+	}
+	return NewRowSliceIterator(rows.Rows), nil
+}
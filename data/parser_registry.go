@@ -3,14 +3,31 @@ package data
 import (
 	"context"
 	"fmt"
+	"mime"
 	"strings"
+	"sync"
 )
 
 type ParserConstructor func(name string, ctx context.Context) Parser
 
+// ParserRegistry maps format names (e.g. 'csv'), file extensions (e.g. 'tsv')
+// and MIME types (e.g. 'text/csv') onto parser constructors.
+//
+// Extensions and MIME types are aliases for a registered name, so the parser
+// has to be registered (by name) before it can be looked up any other way.
+//
+// All of the Get...() methods return a ParserError if there is no match.
 type ParserRegistry interface {
 	Register(name string, constructor ParserConstructor) error
 	Get(name string, ctx context.Context) Parser
+
+	// e.g. RegisterExtension("jsonl", "ndjson")
+	RegisterExtension(extension string, name string) error
+	GetByExtension(extension string, ctx context.Context) Parser
+
+	// e.g. RegisterMimeType("text/csv", "csv")
+	RegisterMimeType(mimeType string, name string) error
+	GetByMimeType(mimeType string, ctx context.Context) Parser
 }
 
 var parserInstance ParserRegistry = &parserRegistry{
@@ -22,18 +39,92 @@ func GetParserRegistry() ParserRegistry {
 }
 
 type parserRegistry struct {
-	parsersByName map[string]ParserConstructor
+	sync.RWMutex
+	parsersByName    map[string]ParserConstructor
+	namesByExtension map[string]string
+	namesByMimeType  map[string]string
 }
 
 func (r *parserRegistry) Register(name string, constructor ParserConstructor) error {
+	r.Lock()
+	defer r.Unlock()
 	r.parsersByName[strings.ToLower(name)] = constructor
 	return nil
 }
 
 func (r *parserRegistry) Get(name string, ctx context.Context) Parser {
-	if constructor, found := r.parsersByName[strings.ToLower(name)]; found {
+	r.RLock()
+	constructor, found := r.parsersByName[strings.ToLower(name)]
+	r.RUnlock()
+	if found {
 		return constructor(name, ctx)
 	}
-
 	return NewParserError(fmt.Errorf("Parser '%s' not found", name))
 }
+
+func (r *parserRegistry) RegisterExtension(extension string, name string) error {
+	extension = normaliseExtension(extension)
+	if extension == "" {
+		return fmt.Errorf("RegisterExtension(%s): extension cannot be empty", name)
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.namesByExtension == nil {
+		r.namesByExtension = make(map[string]string)
+	}
+	r.namesByExtension[extension] = strings.ToLower(name)
+	return nil
+}
+
+func (r *parserRegistry) GetByExtension(extension string, ctx context.Context) Parser {
+	r.RLock()
+	name, found := r.namesByExtension[normaliseExtension(extension)]
+	r.RUnlock()
+	if !found {
+		return NewParserError(fmt.Errorf("no parser registered for extension '%s'", extension))
+	}
+	return r.Get(name, ctx)
+}
+
+func (r *parserRegistry) RegisterMimeType(mimeType string, name string) error {
+	mimeType = normaliseMimeType(mimeType)
+	if mimeType == "" {
+		return fmt.Errorf("RegisterMimeType(%s): MIME type cannot be empty", name)
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.namesByMimeType == nil {
+		r.namesByMimeType = make(map[string]string)
+	}
+	r.namesByMimeType[mimeType] = strings.ToLower(name)
+	return nil
+}
+
+func (r *parserRegistry) GetByMimeType(mimeType string, ctx context.Context) Parser {
+	r.RLock()
+	name, found := r.namesByMimeType[normaliseMimeType(mimeType)]
+	r.RUnlock()
+	if !found {
+		return NewParserError(fmt.Errorf("no parser registered for MIME type '%s'", mimeType))
+	}
+	return r.Get(name, ctx)
+}
+
+// '.CSV' and 'csv' are the same extension
+func normaliseExtension(extension string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(extension), "."))
+}
+
+// 'Text/CSV; charset=utf-8' and 'text/csv' are the same MIME type
+func normaliseMimeType(mimeType string) string {
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// IsParserError reports whether a registry lookup failed to find a parser
+func IsParserError(parser Parser) bool {
+	_, isParserError := parser.(*ParserError)
+	return isParserError
+}
@@ -250,3 +250,35 @@ func BenchmarkParserRegistry_Get_NotFound(b *testing.B) {
 		registry.Get("nonexistent", ctx)
 	}
 }
+
+func TestParserRegistry_GetByExtension(t *testing.T) {
+	registry := &parserRegistry{
+		parsersByName: make(map[string]ParserConstructor),
+	}
+	require.NoError(t, registry.Register("test", newMockParser))
+	require.NoError(t, registry.RegisterExtension(".TST", "test"))
+
+	parser := registry.GetByExtension("tst", context.Background())
+	assert.False(t, IsParserError(parser))
+	assert.Equal(t, "test", parser.GetName())
+
+	parser = registry.GetByExtension("nope", context.Background())
+	assert.True(t, IsParserError(parser))
+
+	assert.Error(t, registry.RegisterExtension("", "test"))
+}
+
+func TestParserRegistry_GetByMimeType(t *testing.T) {
+	registry := &parserRegistry{
+		parsersByName: make(map[string]ParserConstructor),
+	}
+	require.NoError(t, registry.Register("test", newMockParser))
+	require.NoError(t, registry.RegisterMimeType("text/x-test", "test"))
+
+	parser := registry.GetByMimeType("Text/X-Test; charset=utf-8", context.Background())
+	assert.False(t, IsParserError(parser))
+	assert.Equal(t, "test", parser.GetName())
+
+	parser = registry.GetByMimeType("application/octet-stream", context.Background())
+	assert.True(t, IsParserError(parser))
+}
@@ -0,0 +1,218 @@
+package data
+
+import (
+	"context"
+	"io"
+)
+
+// RowIterator hands out rows one at a time, so that large inputs can be
+// loaded without holding every row in memory.
+//
+// It follows the same shape as sql.Rows / bufio.Scanner:
+//
+//	for it.Next() {
+//	    row := it.Row()
+//	    ...
+//	}
+//	if err := it.Err(); err != nil {
+//	    ...
+//	}
+//
+// Close must always be called once the caller is done with the iterator.
+type RowIterator interface {
+	Next() bool
+	Row() Row
+	Err() error
+	Close() error
+}
+
+// NewRowSliceIterator returns a RowIterator over rows which are already in memory
+func NewRowSliceIterator(rows []Row) RowIterator {
+	return &rowSliceIterator{
+		rows:  rows,
+		index: -1,
+	}
+}
+
+type rowSliceIterator struct {
+	rows  []Row
+	index int
+}
+
+func (it *rowSliceIterator) Next() bool {
+	if it.index+1 >= len(it.rows) {
+		it.index = len(it.rows)
+		return false
+	}
+	it.index++
+	return true
+}
+
+func (it *rowSliceIterator) Row() Row {
+	if it.index < 0 || it.index >= len(it.rows) {
+		return nil
+	}
+	return it.rows[it.index]
+}
+
+func (it *rowSliceIterator) Err() error {
+	return nil
+}
+
+func (it *rowSliceIterator) Close() error {
+	return nil
+}
+
+// NewMultiRowIterator chains several iterators together, exhausting each one
+// in turn. This is typically used to replay the rows which were sampled for
+// schema inference, followed by the remainder of the input.
+//
+// Closing the returned iterator closes all of the underlying iterators.
+func NewMultiRowIterator(iterators ...RowIterator) RowIterator {
+	return &multiRowIterator{
+		iterators: iterators,
+	}
+}
+
+type multiRowIterator struct {
+	iterators []RowIterator
+	current   int
+	err       error
+}
+
+func (it *multiRowIterator) Next() bool {
+	for it.current < len(it.iterators) {
+		if it.iterators[it.current].Next() {
+			return true
+		}
+		if err := it.iterators[it.current].Err(); err != nil {
+			it.err = err
+			return false
+		}
+		it.current++
+	}
+	return false
+}
+
+func (it *multiRowIterator) Row() Row {
+	if it.current >= len(it.iterators) {
+		return nil
+	}
+	return it.iterators[it.current].Row()
+}
+
+func (it *multiRowIterator) Err() error {
+	return it.err
+}
+
+func (it *multiRowIterator) Close() error {
+	var firstErr error
+	for _, iterator := range it.iterators {
+		if err := iterator.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// ReadRows pulls at most max rows from the iterator.
+// Fewer than max rows are returned if the iterator is exhausted (or fails,
+// in which case the error is also returned).
+func ReadRows(it RowIterator, max int) ([]Row, error) {
+	rows := make([]Row, 0)
+	for len(rows) < max && it.Next() {
+		rows = append(rows, it.Row())
+	}
+	return rows, it.Err()
+}
+
+// NewRowFuncIterator adapts a 'next row' function into a RowIterator.
+//
+// next is called once per row, and must return io.EOF when there are no more
+// rows.  Any other error stops the iteration and is reported by Err().
+// closer (which may be nil) is called when the iterator is closed.
+func NewRowFuncIterator(next func() (Row, error), closer func() error) RowIterator {
+	return &rowFuncIterator{
+		next:   next,
+		closer: closer,
+	}
+}
+
+type rowFuncIterator struct {
+	next   func() (Row, error)
+	closer func() error
+	row    Row
+	err    error
+	done   bool
+	closed bool
+}
+
+func (it *rowFuncIterator) Next() bool {
+	if it.done {
+		return false
+	}
+	row, err := it.next()
+	if err != nil {
+		it.done = true
+		it.row = nil
+		if err != io.EOF {
+			it.err = err
+		}
+		return false
+	}
+	it.row = row
+	return true
+}
+
+func (it *rowFuncIterator) Row() Row {
+	return it.row
+}
+
+func (it *rowFuncIterator) Err() error {
+	return it.err
+}
+
+func (it *rowFuncIterator) Close() error {
+	if it.closed || it.closer == nil {
+		return nil
+	}
+	it.closed = true
+	return it.closer()
+}
+
+// NewContextRowIterator stops the iteration, with ctx.Err(), once ctx is
+// cancelled (e.g. so that Ctrl-C interrupts a long load)
+func NewContextRowIterator(ctx context.Context, rows RowIterator) RowIterator {
+	return &contextRowIterator{RowIterator: rows, ctx: ctx}
+}
+
+type contextRowIterator struct {
+	RowIterator
+	ctx context.Context
+	err error
+}
+
+func (it *contextRowIterator) Next() bool {
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+	return it.RowIterator.Next()
+}
+
+func (it *contextRowIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.RowIterator.Err()
+}
+
+// CollectRows drains the iterator into a slice.
+// This is how a StreamParser can implement Parse() in terms of ParseStream().
+func CollectRows(it RowIterator) ([]Row, error) {
+	var rows []Row
+	for it.Next() {
+		rows = append(rows, it.Row())
+	}
+	return rows, it.Err()
+}
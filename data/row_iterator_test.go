@@ -0,0 +1,129 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowSliceIterator(t *testing.T) {
+	rows := []Row{
+		{"id": 1},
+		{"id": 2},
+		{"id": 3},
+	}
+	it := NewRowSliceIterator(rows)
+	defer it.Close()
+
+	var got []Row
+	for it.Next() {
+		got = append(got, it.Row())
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, rows, got)
+	assert.False(t, it.Next(), "Next() should keep returning false once exhausted")
+	assert.Nil(t, it.Row())
+}
+
+func TestRowSliceIterator_Empty(t *testing.T) {
+	it := NewRowSliceIterator(nil)
+	assert.False(t, it.Next())
+	assert.Nil(t, it.Row())
+	assert.NoError(t, it.Err())
+}
+
+func TestMultiRowIterator(t *testing.T) {
+	first := NewRowSliceIterator([]Row{{"id": 1}, {"id": 2}})
+	empty := NewRowSliceIterator(nil)
+	second := NewRowSliceIterator([]Row{{"id": 3}})
+
+	it := NewMultiRowIterator(first, empty, second)
+	defer it.Close()
+
+	var ids []any
+	for it.Next() {
+		ids = append(ids, it.Row()["id"])
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []any{1, 2, 3}, ids)
+}
+
+func TestReadRows(t *testing.T) {
+	it := NewRowSliceIterator([]Row{{"id": 1}, {"id": 2}, {"id": 3}})
+
+	sample, err := ReadRows(it, 2)
+	require.NoError(t, err)
+	assert.Len(t, sample, 2)
+
+	// The remainder is still available from the iterator
+	rest, err := ReadRows(it, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []Row{{"id": 3}}, rest)
+
+	// ... and then there's nothing left
+	rest, err = ReadRows(it, 10)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+}
+
+func TestRowFuncIterator(t *testing.T) {
+	remaining := 3
+	closed := false
+	it := NewRowFuncIterator(func() (Row, error) {
+		if remaining == 0 {
+			return nil, io.EOF
+		}
+		remaining--
+		return Row{"remaining": remaining}, nil
+	}, func() error {
+		closed = true
+		return nil
+	})
+
+	rows, err := CollectRows(it)
+	require.NoError(t, err)
+	assert.Len(t, rows, 3)
+	assert.False(t, it.Next())
+
+	require.NoError(t, it.Close())
+	assert.True(t, closed)
+}
+
+func TestRowFuncIterator_Error(t *testing.T) {
+	it := NewRowFuncIterator(func() (Row, error) {
+		return nil, errors.New("broken input")
+	}, nil)
+
+	assert.False(t, it.Next())
+	assert.EqualError(t, it.Err(), "broken input")
+	assert.NoError(t, it.Close())
+}
+
+func TestContextRowIterator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	it := NewContextRowIterator(ctx, NewRowSliceIterator([]Row{{"id": 1}, {"id": 2}}))
+
+	require.True(t, it.Next())
+	assert.Equal(t, Row{"id": 1}, it.Row())
+	cancel()
+	assert.False(t, it.Next())
+	assert.ErrorIs(t, it.Err(), context.Canceled)
+	assert.NoError(t, it.Close())
+}
+
+func TestParseStream_ShimForRowListParsers(t *testing.T) {
+	// The BaseParser only knows how to Parse() into a RowList
+	parser := NewBaseParser(context.TODO(), "base")
+	it, err := ParseStream(parser, strings.NewReader(`[{"id": 1}, {"id": 2}]`))
+	require.NoError(t, err)
+	defer it.Close()
+
+	rows, err := CollectRows(it)
+	require.NoError(t, err)
+	assert.Len(t, rows, 2)
+}
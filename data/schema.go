@@ -0,0 +1,136 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Column is one column of a table, with its SQL type
+type Column struct {
+	Name string
+	Type string
+}
+
+// Schema is the columns of a table, in the order they appear in the table
+// (which is the order they appeared in the source, e.g. a CSV header).
+//
+// In JSON it is an object of column name to type, e.g. {"id": "INTEGER"},
+// with the keys in column order.
+type Schema []Column
+
+// Names returns the column names, in order
+func (s Schema) Names() []string {
+	names := make([]string, len(s))
+	for i, column := range s {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// Type returns the type of the named column, or "" if there isn't one
+func (s Schema) Type(name string) string {
+	for _, column := range s {
+		if column.Name == name {
+			return column.Type
+		}
+	}
+	return ""
+}
+
+func (s Schema) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, column := range s {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(column.Name)
+		if err != nil {
+			return nil, err
+		}
+		columnType, err := json.Marshal(column.Type)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(columnType)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (s *Schema) UnmarshalJSON(jsonBytes []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	if token, err := decoder.Token(); err != nil {
+		return err
+	} else if token != json.Delim('{') {
+		return fmt.Errorf("schema must be a JSON object, not %v", token)
+	}
+	schema := Schema{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		var columnType string
+		if err := decoder.Decode(&columnType); err != nil {
+			return err
+		}
+		schema = append(schema, Column{Name: token.(string), Type: columnType})
+	}
+	*s = schema
+	return nil
+}
+
+// ColumnOrder records column names in the order they are first seen, for
+// parsers whose rows (being maps) can't remember it for themselves
+type ColumnOrder struct {
+	names []string
+	seen  map[string]bool
+}
+
+// Add records any of names which haven't been seen before
+func (o *ColumnOrder) Add(names ...string) {
+	if o.seen == nil {
+		o.seen = make(map[string]bool)
+	}
+	for _, name := range names {
+		if !o.seen[name] {
+			o.seen[name] = true
+			o.names = append(o.names, name)
+		}
+	}
+}
+
+// Names returns the column names, in the order they were first seen
+func (o *ColumnOrder) Names() []string {
+	return o.names
+}
+
+// OrderColumns puts names into the order given by order.  Any names which
+// aren't in order go after the others, sorted by name.
+func OrderColumns(names []string, order []string) []string {
+	rank := make(map[string]int, len(order))
+	for i, name := range order {
+		if _, exists := rank[name]; !exists {
+			rank[name] = i
+		}
+	}
+	ordered := append([]string(nil), names...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		rankI, knownI := rank[ordered[i]]
+		rankJ, knownJ := rank[ordered[j]]
+		switch {
+		case knownI && knownJ:
+			return rankI < rankJ
+		case knownI != knownJ:
+			return knownI
+		default:
+			return ordered[i] < ordered[j]
+		}
+	})
+	return ordered
+}
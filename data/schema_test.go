@@ -0,0 +1,36 @@
+package data
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaJSONKeepsColumnOrder(t *testing.T) {
+	schema := Schema{{Name: "zeta", Type: "TEXT"}, {Name: "alpha", Type: "INTEGER"}}
+	jsonBytes, err := json.Marshal(schema)
+	require.NoError(t, err)
+	assert.Equal(t, `{"zeta":"TEXT","alpha":"INTEGER"}`, string(jsonBytes))
+
+	var restored Schema
+	require.NoError(t, json.Unmarshal(jsonBytes, &restored))
+	assert.Equal(t, schema, restored)
+	assert.Equal(t, []string{"zeta", "alpha"}, restored.Names())
+	assert.Equal(t, "INTEGER", restored.Type("alpha"))
+	assert.Equal(t, "", restored.Type("missing"))
+
+	assert.Error(t, json.Unmarshal([]byte(`["zeta"]`), &restored))
+}
+
+func TestOrderColumns(t *testing.T) {
+	var order ColumnOrder
+	order.Add("c", "a")
+	order.Add("a", "b")
+	assert.Equal(t, []string{"c", "a", "b"}, order.Names())
+
+	// Anything which isn't in the order goes last, by name
+	assert.Equal(t, []string{"c", "a", "b", "x", "y"}, OrderColumns([]string{"y", "b", "x", "a", "c"}, order.Names()))
+	assert.Equal(t, []string{"a", "b"}, OrderColumns([]string{"b", "a"}, nil))
+}
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/jbirtley88/gremel/helper"
+)
+
+// DefaultBatchSize is the number of rows inserted per transaction when the
+// caller does not specify a batch size
+const DefaultBatchSize = 10000
+
+// bulkLoader does the heavy lifting for InsertRowStream.
+//
+// Inserting one row per implicit transaction is painfully slow in SQLite, so
+// rows are inserted through a single prepared statement inside a transaction
+// which is committed every batchSize rows.
+type bulkLoader struct {
+	db        *SQLiteGremelDB
+	tableName string
+	batchSize int
+
+	columns   []string
+	columnSet map[string]bool
+	tx        *sql.Tx
+	stmt      *sql.Stmt
+	pending   int
+	count     int64
+}
+
+func (l *bulkLoader) insert(row data.Row) error {
+	if l.columnSet == nil {
+		l.initColumns(row)
+	}
+
+	// Any columns we haven't seen before get added to the table
+	var newColumns []string
+	for fieldName, value := range row {
+		if !l.columnSet[fieldName] && value != nil {
+			newColumns = append(newColumns, fieldName)
+		}
+	}
+	if len(newColumns) > 0 {
+		sort.Strings(newColumns)
+		if err := l.addColumns(newColumns, row); err != nil {
+			return err
+		}
+	}
+
+	if l.tx == nil {
+		if err := l.begin(); err != nil {
+			return err
+		}
+	}
+
+	values := make([]any, 0, len(l.columns))
+	for _, column := range l.columns {
+		values = append(values, row[column])
+	}
+	if _, err := l.stmt.Exec(values...); err != nil {
+		return fmt.Errorf("failed to execute insert statement: %w", err)
+	}
+	l.count++
+	l.pending++
+
+	if l.pending >= l.batchSize {
+		return l.commit()
+	}
+	return nil
+}
+
+// initColumns takes the column list from the stashed schema, or from the
+// first row if we don't know the schema for this table
+func (l *bulkLoader) initColumns(firstRow data.Row) {
+	l.columnSet = make(map[string]bool)
+	if schema, exists := l.db.schemaByName[l.tableName]; exists {
+		l.columns = schema.Names()
+	} else {
+		for column := range firstRow {
+			l.columns = append(l.columns, column)
+		}
+		sort.Strings(l.columns)
+	}
+	for _, column := range l.columns {
+		l.columnSet[column] = true
+	}
+}
+
+func (l *bulkLoader) addColumns(newColumns []string, row data.Row) error {
+	if l.tx == nil {
+		if err := l.begin(); err != nil {
+			return err
+		}
+	}
+	// The prepared statement is tied to the old column list
+	l.stmt.Close()
+
+	for _, column := range newColumns {
+		columnType, err := helper.GetColumnType(row[column])
+		if err != nil {
+			return fmt.Errorf("column %q: %w", column, err)
+		}
+		typeName, err := getSQLTypeName(columnType)
+		if err != nil {
+			return fmt.Errorf("column %q: %w", column, err)
+		}
+		if _, err := l.tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", quoteIdentifier(l.tableName), quoteIdentifier(column), typeName)); err != nil {
+			return fmt.Errorf("failed to add column %q: %w", column, err)
+		}
+		if schema, exists := l.db.schemaByName[l.tableName]; exists {
+			schema = append(schema, data.Column{Name: column, Type: typeName})
+			l.db.schemaByName[l.tableName] = schema
+			// Has to be within the transaction, which has the database locked
+			if err := l.db.saveMetadata(l.tx, metadataSchema, l.tableName, schema); err != nil {
+				return err
+			}
+		}
+		l.columns = append(l.columns, column)
+		l.columnSet[column] = true
+	}
+	return l.prepare()
+}
+
+func (l *bulkLoader) begin() error {
+	tx, err := l.db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	l.tx = tx
+	return l.prepare()
+}
+
+func (l *bulkLoader) prepare() error {
+	columns := make([]string, len(l.columns))
+	placeholders := make([]string, len(l.columns))
+	for i, column := range l.columns {
+		columns[i] = quoteIdentifier(column)
+		placeholders[i] = "?"
+	}
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);",
+		quoteIdentifier(l.tableName),
+		strings.Join(columns, ", "),
+		strings.Join(placeholders, ", "),
+	)
+	if len(l.columns) == 0 {
+		insertSQL = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES;", quoteIdentifier(l.tableName))
+	}
+	stmt, err := l.tx.Prepare(insertSQL)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert statement: %w", err)
+	}
+	l.stmt = stmt
+	return nil
+}
+
+func (l *bulkLoader) commit() error {
+	if l.tx == nil {
+		return nil
+	}
+	l.stmt.Close()
+	err := l.tx.Commit()
+	l.tx = nil
+	l.stmt = nil
+	l.pending = 0
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// rollback abandons any uncommitted batch. It is a no-op once commit() has been called.
+func (l *bulkLoader) rollback() {
+	if l.tx == nil {
+		return
+	}
+	if l.stmt != nil {
+		l.stmt.Close()
+	}
+	_ = l.tx.Rollback()
+	l.tx = nil
+	l.stmt = nil
+}
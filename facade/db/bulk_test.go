@@ -0,0 +1,94 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteGremelDB_InsertRowStream(t *testing.T) {
+	t.Run("inserts rows across several batches", func(t *testing.T) {
+		db := newNamedSQLiteGremelDB("bulk_batches").(*SQLiteGremelDB)
+		defer db.Close()
+
+		rows := make([]data.Row, 0)
+		for i := 1; i <= 25; i++ {
+			rows = append(rows, data.Row{"id": i, "name": fmt.Sprintf("row%d", i)})
+		}
+		require.NoError(t, db.CreateSchema("batched", nil, rows[0:1]))
+
+		count, err := db.InsertRowStream("batched", data.NewRowSliceIterator(rows), 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(25), count)
+
+		result, _, err := db.Query("SELECT COUNT(*) AS n, MAX(id) AS maxid FROM batched")
+		require.NoError(t, err)
+		assert.Equal(t, int64(25), result[0]["n"])
+		assert.Equal(t, int64(25), result[0]["maxid"])
+	})
+
+	t.Run("adds columns which were not in the sample", func(t *testing.T) {
+		db := newNamedSQLiteGremelDB("bulk_new_columns").(*SQLiteGremelDB)
+		defer db.Close()
+
+		rows := []data.Row{
+			{"id": 1, "name": "Alice"},
+			{"id": 2, "name": "Bob", "age": 42},
+		}
+		require.NoError(t, db.CreateSchema("evolving", nil, rows[0:1]))
+
+		count, err := db.InsertRowStream("evolving", data.NewRowSliceIterator(rows), 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		schema, err := db.GetSchema("evolving")
+		require.NoError(t, err)
+		assert.Equal(t, "INTEGER", schema.Type("age"))
+
+		result, _, err := db.Query("SELECT age FROM evolving WHERE id = 2")
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), result[0]["age"])
+	})
+
+	t.Run("quotes table and column names", func(t *testing.T) {
+		db := newNamedSQLiteGremelDB("bulk_quoting").(*SQLiteGremelDB)
+		defer db.Close()
+
+		rows := []data.Row{
+			{"address.city": "Leeds", "order": 1, "first name": "Alice"},
+			{"address.city": "York", "order": 2, `say "hi"`: "hello"},
+		}
+		require.NoError(t, db.CreateSchema("people list", nil, rows[0:1]))
+		_, err := db.InsertRowStream("people list", data.NewRowSliceIterator(rows), 0)
+		require.NoError(t, err)
+		require.NoError(t, db.RenameSchema("people list", "select"))
+
+		result, _, err := db.Query(`SELECT "address.city" AS city, "say ""hi""" AS greeting FROM "select" ORDER BY "order"`)
+		require.NoError(t, err)
+		assert.Equal(t, []data.Row{{"city": "Leeds", "greeting": nil}, {"city": "York", "greeting": "hello"}}, result)
+		require.NoError(t, db.DropSchema("select"))
+	})
+
+	t.Run("nothing is committed from a failed batch", func(t *testing.T) {
+		db := newNamedSQLiteGremelDB("bulk_failure").(*SQLiteGremelDB)
+		defer db.Close()
+
+		rows := []data.Row{
+			{"id": 1},
+			{"id": 2, "bad": complex(1, 2)},
+		}
+		require.NoError(t, db.CreateSchema("failing", nil, rows[0:1]))
+
+		count, err := db.InsertRowStream("failing", data.NewRowSliceIterator(rows), 100)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "row 2")
+		assert.Equal(t, int64(1), count)
+
+		result, _, err := db.Query("SELECT COUNT(*) AS n FROM failing")
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), result[0]["n"])
+	})
+}
@@ -1,6 +1,10 @@
 package db
 
-import "github.com/jbirtley88/gremel/data"
+import (
+	"context"
+
+	"github.com/jbirtley88/gremel/data"
+)
 
 type ErrorGremelDB struct {
 	underlyingError error
@@ -12,18 +16,22 @@ func NewErrorGremelDB(err error) GremelDB {
 	}
 }
 
-func (db *ErrorGremelDB) CreateSchema(tableName string, rows []data.Row) error {
+func (db *ErrorGremelDB) CreateSchema(tableName string, columns []string, rows []data.Row) error {
 	return db.underlyingError
 }
 
-func (db *ErrorGremelDB) GetSchema(tableName string) (data.Row, error) {
-	return data.Row{}, db.underlyingError
+func (db *ErrorGremelDB) GetSchema(tableName string) (data.Schema, error) {
+	return data.Schema{}, db.underlyingError
 }
 
 func (db *ErrorGremelDB) DropSchema(tableName string) error {
 	return db.underlyingError
 }
 
+func (db *ErrorGremelDB) RenameSchema(fromTable string, toTable string) error {
+	return db.underlyingError
+}
+
 func (db *ErrorGremelDB) GetTables() ([]string, error) {
 	return nil, db.underlyingError
 }
@@ -36,10 +44,22 @@ func (db *ErrorGremelDB) GetMount(tableName string) (data.Row, error) {
 	return data.Row{}, db.underlyingError
 }
 
+func (db *ErrorGremelDB) SetMountInfo(tableName string, key string, value any) error {
+	return db.underlyingError
+}
+
 func (db *ErrorGremelDB) InsertRows(tableName string, rows []data.Row) error {
 	return db.underlyingError
 }
 
+func (db *ErrorGremelDB) InsertRowStream(tableName string, rows data.RowIterator, batchSize int) (int64, error) {
+	return 0, db.underlyingError
+}
+
+func (db *ErrorGremelDB) SaveAs(path string) error {
+	return db.underlyingError
+}
+
 func (db *ErrorGremelDB) Close() error {
 	return db.underlyingError
 }
@@ -47,3 +67,7 @@ func (db *ErrorGremelDB) Close() error {
 func (db *ErrorGremelDB) Query(sqlQuery string) ([]data.Row, []string, error) {
 	return nil, nil, db.underlyingError
 }
+
+func (db *ErrorGremelDB) QueryContext(ctx context.Context, sqlQuery string) ([]data.Row, []string, error) {
+	return nil, nil, db.underlyingError
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jbirtley88/gremel/data"
+)
+
+// The schemas and mounts are kept in a table inside the database itself, so
+// that an on-disk database (see --db) comes back exactly as it was left.
+const metadataTable = "_gremel_metadata"
+
+const (
+	metadataSchema    = "schema"
+	metadataMount     = "mount"
+	metadataMountInfo = "mountinfo"
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// loadMetadata creates the metadata table if need be, and restores the
+// schemas and mounts from it
+func (db *SQLiteGremelDB) loadMetadata() error {
+	_, err := db.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (kind, name)
+);`, metadataTable))
+	if err != nil {
+		return fmt.Errorf("loadMetadata(): failed to create %s: %w", metadataTable, err)
+	}
+
+	rows, err := db.db.Query(fmt.Sprintf("SELECT kind, name, value FROM %s;", metadataTable))
+	if err != nil {
+		return fmt.Errorf("loadMetadata(): failed to read %s: %w", metadataTable, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var kind, name, value string
+		if err := rows.Scan(&kind, &name, &value); err != nil {
+			return fmt.Errorf("loadMetadata(): %w", err)
+		}
+		switch kind {
+		case metadataSchema:
+			var schema data.Schema
+			if err := json.Unmarshal([]byte(value), &schema); err != nil {
+				return fmt.Errorf("loadMetadata(): schema for %s: %w", name, err)
+			}
+			db.schemaByName[name] = schema
+		case metadataMount:
+			var source string
+			if err := json.Unmarshal([]byte(value), &source); err != nil {
+				return fmt.Errorf("loadMetadata(): mount for %s: %w", name, err)
+			}
+			db.mountByName[name] = source
+		case metadataMountInfo:
+			mountInfo := make(data.Row)
+			if err := json.Unmarshal([]byte(value), &mountInfo); err != nil {
+				return fmt.Errorf("loadMetadata(): mount info for %s: %w", name, err)
+			}
+			for key, value := range mountInfo {
+				mountInfo[key] = restoreValue(value)
+			}
+			db.mountInfoByName[name] = mountInfo
+		}
+	}
+	return rows.Err()
+}
+
+// saveMetadata records (or replaces) one piece of metadata
+func (db *SQLiteGremelDB) saveMetadata(conn execer, kind string, name string, value any) error {
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("saveMetadata(%s, %s): %w", kind, name, err)
+	}
+	_, err = conn.Exec(fmt.Sprintf("INSERT OR REPLACE INTO %s (kind, name, value) VALUES (?, ?, ?);", metadataTable), kind, name, string(jsonBytes))
+	if err != nil {
+		return fmt.Errorf("saveMetadata(%s, %s): %w", kind, name, err)
+	}
+	return nil
+}
+
+func (db *SQLiteGremelDB) deleteMetadata(conn execer, kind string, name string) error {
+	_, err := conn.Exec(fmt.Sprintf("DELETE FROM %s WHERE kind = ? AND name = ?;", metadataTable), kind, name)
+	if err != nil {
+		return fmt.Errorf("deleteMetadata(%s, %s): %w", kind, name, err)
+	}
+	return nil
+}
+
+// restoreValue undoes the loss of type information in the round trip through
+// JSON, for the shapes of mount info which we record (e.g. []string of table
+// names and map[string]string of options)
+func restoreValue(value any) any {
+	switch v := value.(type) {
+	case []any:
+		strings := make([]string, 0, len(v))
+		for _, element := range v {
+			s, isString := element.(string)
+			if !isString {
+				return value
+			}
+			strings = append(strings, s)
+		}
+		return strings
+	case map[string]any:
+		strings := make(map[string]string, len(v))
+		for key, element := range v {
+			s, isString := element.(string)
+			if !isString {
+				return value
+			}
+			strings[key] = s
+		}
+		return strings
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v)
+		}
+	}
+	return value
+}
@@ -40,11 +40,11 @@ func TestNamedSQLiteGremelDB_SeparateDatabases(t *testing.T) {
 	}
 
 	// Create schema in db1
-	err := db1.CreateSchema("users", []data.Row{sampleRow1})
+	err := db1.CreateSchema("users", nil, []data.Row{sampleRow1})
 	require.NoError(t, err, "Failed to create schema in db1")
 
 	// Create schema in db2
-	err = db2.CreateSchema("products", []data.Row{sampleRow2})
+	err = db2.CreateSchema("products", nil, []data.Row{sampleRow2})
 	require.NoError(t, err, "Failed to create schema in db2")
 
 	// Insert data into db1
@@ -89,7 +89,7 @@ func TestNamedSQLiteGremelDB_SameName_SeparateInstances(t *testing.T) {
 
 	// Create schema in db1
 	sampleRow := data.Row{"id": 1, "name": "test"}
-	err := db1.CreateSchema("shared_table", []data.Row{sampleRow})
+	err := db1.CreateSchema("shared_table", nil, []data.Row{sampleRow})
 	require.NoError(t, err, "Failed to create schema in db1")
 
 	// Insert data via db1
@@ -120,10 +120,10 @@ func TestNamedSQLiteGremelDB_DifferentNames_CompleteSeparation(t *testing.T) {
 	// Create same table name in both databases with same structure
 	sampleRow := data.Row{"id": 1, "data": "test"}
 
-	err := dbA.CreateSchema("common_table", []data.Row{sampleRow})
+	err := dbA.CreateSchema("common_table", nil, []data.Row{sampleRow})
 	require.NoError(t, err, "Failed to create schema in dbA")
 
-	err = dbB.CreateSchema("common_table", []data.Row{sampleRow})
+	err = dbB.CreateSchema("common_table", nil, []data.Row{sampleRow})
 	require.NoError(t, err, "Failed to create schema in dbB")
 
 	// Insert different data into each
@@ -150,3 +150,23 @@ func TestNamedSQLiteGremelDB_DifferentNames_CompleteSeparation(t *testing.T) {
 	err = dbB.InsertRows("common_table", moreRowsB)
 	assert.NoError(t, err, "dbB should still work after dbA table was dropped")
 }
+
+func TestUsePrivateGremelDB(t *testing.T) {
+	// Not the singleton itself, which would share the in-memory "gremel"
+	// database with the other tests
+	restore := UsePrivateGremelDB()
+	defer restore()
+	outer := GetGremelDB()
+	require.NoError(t, outer.CreateSchema("private_table", nil, []data.Row{{"id": 1}}))
+
+	restoreInner := UsePrivateGremelDB()
+	inner := GetGremelDB()
+	assert.NotSame(t, outer, inner)
+	_, err := inner.GetSchema("private_table")
+	assert.Error(t, err)
+
+	restoreInner()
+	assert.Same(t, outer, GetGremelDB())
+	_, err = GetGremelDB().GetSchema("private_table")
+	assert.NoError(t, err)
+}
@@ -1,14 +1,19 @@
 package db
 
 import (
+	"context"
+
 	"github.com/jbirtley88/gremel/data"
 )
 
 type GremelDB interface {
-	CreateSchema(tableName string, rows []data.Row) error
+	// Create the table for rows, with its columns in the order given by columns
+	CreateSchema(tableName string, columns []string, rows []data.Row) error
 	DropSchema(tableName string) error
+	// Replace toTable with fromTable, e.g. once a table loaded under another name is complete
+	RenameSchema(fromTable string, toTable string) error
 	// Support for the '.schema' command
-	GetSchema(tableName string) (data.Row, error)
+	GetSchema(tableName string) (data.Schema, error)
 	// Support for the '.tables' command
 	GetTables() ([]string, error)
 
@@ -16,7 +21,15 @@ type GremelDB interface {
 	Mount(tableName string, source string) error
 	// Get the mount point for this table, to support the '.mount' command
 	GetMount(tableName string) (data.Row, error)
+	// Record extra information about the mount (e.g. 'options'), which GetMount(tableName) reports
+	SetMountInfo(tableName string, key string, value any) error
 	InsertRows(tableName string, rows []data.Row) error
+	// Bulk-load rows in batches of batchSize per transaction, returning the number of rows inserted
+	InsertRowStream(tableName string, rows data.RowIterator, batchSize int) (int64, error)
 	Query(sqlQuery string) ([]data.Row, []string, error)
+	// Query, giving up (with an error) if ctx is cancelled before it finishes
+	QueryContext(ctx context.Context, sqlQuery string) ([]data.Row, []string, error)
+	// Write a copy of the database to a file, which can be reopened with '--db'
+	SaveAs(path string) error
 	Close() error
 }
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrReadOnly is returned (wrapped) by Query for anything other than reading
+// the mounted tables, e.g. 'DROP TABLE', 'ATTACH' or 'PRAGMA journal_mode=off'
+var ErrReadOnly = errors.New("queries are read-only")
+
+// The driver name which sql.Open() is given, so that every connection gets the
+// read-only authorizer
+const readOnlyDriverName = "sqlite3_gremel"
+
+// go-sqlite3 doesn't export SQLITE_RECURSIVE, which 'WITH RECURSIVE' needs
+const sqliteRecursive = 33
+
+// The only pragma which can be used in a query, either as
+// 'PRAGMA table_info(table)' or as pragma_table_info('table').  The rest either
+// change the database or report on more than the mounted tables (e.g.
+// database_list has the --db path, and integrity_check reads the whole
+// database).  helper.ClassifySQL agrees.
+const readOnlyPragma = "table_info"
+
+// What a denied action is called in the error, for those that aren't
+// described by their arguments
+var actionNames = map[int]string{
+	sqlite3.SQLITE_INSERT:              "INSERT",
+	sqlite3.SQLITE_UPDATE:              "UPDATE",
+	sqlite3.SQLITE_DELETE:              "DELETE",
+	sqlite3.SQLITE_CREATE_INDEX:        "CREATE INDEX",
+	sqlite3.SQLITE_CREATE_TABLE:        "CREATE TABLE",
+	sqlite3.SQLITE_CREATE_TEMP_INDEX:   "CREATE INDEX",
+	sqlite3.SQLITE_CREATE_TEMP_TABLE:   "CREATE TABLE",
+	sqlite3.SQLITE_CREATE_TEMP_TRIGGER: "CREATE TRIGGER",
+	sqlite3.SQLITE_CREATE_TEMP_VIEW:    "CREATE VIEW",
+	sqlite3.SQLITE_CREATE_TRIGGER:      "CREATE TRIGGER",
+	sqlite3.SQLITE_CREATE_VIEW:         "CREATE VIEW",
+	sqlite3.SQLITE_CREATE_VTABLE:       "CREATE VIRTUAL TABLE",
+	sqlite3.SQLITE_DROP_INDEX:          "DROP INDEX",
+	sqlite3.SQLITE_DROP_TABLE:          "DROP TABLE",
+	sqlite3.SQLITE_DROP_TEMP_INDEX:     "DROP INDEX",
+	sqlite3.SQLITE_DROP_TEMP_TABLE:     "DROP TABLE",
+	sqlite3.SQLITE_DROP_TEMP_TRIGGER:   "DROP TRIGGER",
+	sqlite3.SQLITE_DROP_TEMP_VIEW:      "DROP VIEW",
+	sqlite3.SQLITE_DROP_TRIGGER:        "DROP TRIGGER",
+	sqlite3.SQLITE_DROP_VIEW:           "DROP VIEW",
+	sqlite3.SQLITE_DROP_VTABLE:         "DROP VIRTUAL TABLE",
+	sqlite3.SQLITE_TRANSACTION:         "a transaction",
+	sqlite3.SQLITE_ATTACH:              "ATTACH",
+	sqlite3.SQLITE_DETACH:              "DETACH",
+	sqlite3.SQLITE_ALTER_TABLE:         "ALTER TABLE",
+	sqlite3.SQLITE_REINDEX:             "REINDEX",
+	sqlite3.SQLITE_ANALYZE:             "ANALYZE",
+	sqlite3.SQLITE_SAVEPOINT:           "SAVEPOINT",
+}
+
+// The guard for each connection which is running a query.  Connections which
+// aren't (e.g. those loading a mount) are allowed to do anything.
+var readOnlyGuards sync.Map
+
+func init() {
+	// The authorizer is registered once per connection (go-sqlite3 only lets
+	// go of it when the connection is closed), and looks up the guard for
+	// whichever query is running on it at the time
+	sql.Register(readOnlyDriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			conn.RegisterAuthorizer(func(action int, arg1 string, arg2 string, arg3 string) int {
+				guard, exists := readOnlyGuards.Load(conn)
+				if !exists {
+					return sqlite3.SQLITE_OK
+				}
+				return guard.(*readOnlyGuard).authorize(action, arg1, arg2, arg3)
+			})
+			return nil
+		},
+	})
+}
+
+// readOnlyConn gets a connection which, until release is called, can only
+// read the mounted tables.  The caller holds the read lock.
+//
+// SQLite's authorizer checks each statement as it is prepared, and
+// 'PRAGMA query_only' catches anything which writes without asking it first
+// (e.g. 'VACUUM INTO').
+func (db *SQLiteGremelDB) readOnlyConn(ctx context.Context) (*sql.Conn, *readOnlyGuard, func(), error) {
+	conn, err := db.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var sqliteConn *sqlite3.SQLiteConn
+	if err := conn.Raw(func(driverConn any) error {
+		sqliteConn = driverConn.(*sqlite3.SQLiteConn)
+		return nil
+	}); err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA query_only = 1"); err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+	guard := &readOnlyGuard{tables: db.schemaByName}
+	readOnlyGuards.Store(sqliteConn, guard)
+
+	release := func() {
+		readOnlyGuards.Delete(sqliteConn)
+		// Not ctx, which may well have been cancelled by now
+		if _, err := conn.ExecContext(context.Background(), "PRAGMA query_only = 0"); err != nil {
+			// Don't let anything else have a connection which can't write
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return conn, guard, release, nil
+}
+
+// readOnlyGuard is the authorizer for a single query, which only lets it
+// read from the mounted tables
+type readOnlyGuard struct {
+	// The mounted tables (the caller holds the read lock)
+	tables map[string]data.Schema
+	// Why the query was denied, for the error
+	denied string
+}
+
+// readOnlyError says why the query wasn't allowed, or is nil if err had
+// nothing to do with it being read-only
+func (g *readOnlyGuard) readOnlyError(err error) error {
+	if g.denied != "" {
+		return fmt.Errorf("%w: %s is not allowed", ErrReadOnly, g.denied)
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrReadonly {
+		return fmt.Errorf("%w: %s", ErrReadOnly, err.Error())
+	}
+	return nil
+}
+
+func (g *readOnlyGuard) authorize(action int, arg1 string, arg2 string, arg3 string) int {
+	switch action {
+	case sqlite3.SQLITE_SELECT, sqliteRecursive:
+		return sqlite3.SQLITE_OK
+	case sqlite3.SQLITE_READ:
+		// arg1 is the table, arg2 the column and arg3 the database, which is
+		// empty for a common table expression
+		if arg3 == "" || g.isReadable(arg1) {
+			return sqlite3.SQLITE_OK
+		}
+		return g.deny(fmt.Sprintf("reading '%s' (which isn't mounted)", arg1))
+	case sqlite3.SQLITE_INSERT, sqlite3.SQLITE_UPDATE, sqlite3.SQLITE_DELETE:
+		// CREATE and DROP ask about changing SQLite's catalogue before they ask
+		// about themselves, and are denied when they do (as is anything else
+		// which tries to change it, by 'PRAGMA query_only')
+		if isCatalogue(arg1) {
+			return sqlite3.SQLITE_OK
+		}
+	case sqlite3.SQLITE_FUNCTION:
+		// arg2 is the function
+		if strings.EqualFold(arg2, "load_extension") {
+			return g.deny(arg2 + "()")
+		}
+		return sqlite3.SQLITE_OK
+	case sqlite3.SQLITE_PRAGMA:
+		// arg1 is the pragma, arg2 its value (if any)
+		if strings.EqualFold(arg1, readOnlyPragma) {
+			return sqlite3.SQLITE_OK
+		}
+		if arg2 == "" {
+			return g.deny("PRAGMA " + arg1)
+		}
+		return g.deny(fmt.Sprintf("PRAGMA %s = %s", arg1, arg2))
+	}
+	if name, exists := actionNames[action]; exists {
+		return g.deny(name)
+	}
+	return g.deny(fmt.Sprintf("SQLite action %d", action))
+}
+
+// isReadable is true for the mounted tables, SQLite's own catalogue and the
+// pragma_table_info() function, but not for the
+// workspace metadata
+func (g *readOnlyGuard) isReadable(table string) bool {
+	if isCatalogue(table) {
+		return true
+	}
+	table = strings.ToLower(table)
+	if table == strings.ToLower(metadataTable) {
+		return false
+	}
+	if table == "pragma_"+readOnlyPragma {
+		return true
+	}
+	for name := range g.tables {
+		if strings.ToLower(name) == table {
+			return true
+		}
+	}
+	return false
+}
+
+// isCatalogue is true for SQLite's list of the tables in the database
+func isCatalogue(table string) bool {
+	switch strings.ToLower(table) {
+	case "sqlite_master", "sqlite_schema", "sqlite_temp_master", "sqlite_temp_schema":
+		return true
+	}
+	return false
+}
+
+// deny remembers the first reason, since that is the one SQLite fails with
+func (g *readOnlyGuard) deny(reason string) int {
+	if g.denied == "" {
+		g.denied = reason
+	}
+	return sqlite3.SQLITE_DENY
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteGremelDB_QueryIsReadOnly(t *testing.T) {
+	db := newNamedSQLiteGremelDB("readonly").(*SQLiteGremelDB)
+	defer db.Close()
+	require.NoError(t, db.CreateSchema("people", nil, []data.Row{{"id": 1, "name": "Alice"}}))
+	require.NoError(t, db.InsertRows("people", []data.Row{{"id": 1, "name": "Alice"}, {"id": 2, "name": "Bob"}}))
+	require.NoError(t, db.Mount("people", "people.csv"))
+
+	for _, query := range []string{
+		"SELECT name FROM people ORDER BY id",
+		"WITH named AS (SELECT name FROM people) SELECT name FROM named",
+		"WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n WHERE x < 3) SELECT x FROM n",
+		"SELECT * FROM (SELECT id FROM People) AS p",
+		"EXPLAIN QUERY PLAN SELECT * FROM people",
+		"VALUES (1), (2)",
+		"SELECT name FROM sqlite_master",
+		"PRAGMA table_info(people)",
+		"SELECT name FROM pragma_table_info('people')",
+	} {
+		_, _, err := db.Query(query)
+		assert.NoError(t, err, query)
+	}
+
+	for query, reason := range map[string]string{
+		"DROP TABLE people": "DROP TABLE",
+		"INSERT INTO people (id, name) VALUES (3, 'Eve')": "INSERT",
+		"UPDATE people SET name = 'Eve'":                  "UPDATE",
+		"DELETE FROM people":                              "DELETE",
+		"CREATE TABLE eve (id INTEGER)":                   "CREATE TABLE",
+		"BEGIN":                                           "a transaction",
+		"ATTACH ':memory:' AS other":                      "ATTACH",
+		"PRAGMA user_version = 3":                         "PRAGMA user_version = 3",
+		"PRAGMA optimize":                                 "PRAGMA optimize",
+		"PRAGMA database_list":                            "PRAGMA database_list",
+		"PRAGMA integrity_check":                          "PRAGMA integrity_check",
+		"SELECT * FROM pragma_user_version":               "reading 'pragma_user_version' (which isn't mounted)",
+		"SELECT * FROM pragma_database_list":              "reading 'pragma_database_list' (which isn't mounted)",
+		"SELECT load_extension('evil.so')":                "load_extension()",
+		"SELECT * FROM " + metadataTable:                  "reading '" + metadataTable + "' (which isn't mounted)",
+		"SELECT 1; DROP TABLE people":                     "DROP TABLE",
+	} {
+		_, _, err := db.Query(query)
+		assert.ErrorIs(t, err, ErrReadOnly, query)
+		assert.ErrorContains(t, err, ": "+reason+" is not allowed", query)
+	}
+
+	// Caught by 'PRAGMA query_only', since it doesn't ask the authorizer
+	path := filepath.Join(t.TempDir(), "copy.sqlite")
+	_, _, err := db.Query("VACUUM INTO '" + path + "'")
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	// Nothing has changed, and the database can still be written to outside of queries
+	rows, _, err := db.Query("SELECT name FROM people ORDER BY id")
+	require.NoError(t, err)
+	assert.Equal(t, []data.Row{{"name": "Alice"}, {"name": "Bob"}}, rows)
+	require.NoError(t, db.InsertRows("people", []data.Row{{"id": 3, "name": "Carol"}}))
+	require.NoError(t, db.DropSchema("people"))
+	_, _, err = db.Query("SELECT name FROM people")
+	assert.Error(t, err)
+}
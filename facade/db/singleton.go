@@ -1,20 +1,62 @@
 package db
 
-import "sync"
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/spf13/viper"
+)
 
 var dbSingleton sync.Once
 var dbInstance GremelDB
 
+// The database which GetGremelDB returns instead, while UsePrivateGremelDB is
+// in effect
+var dbOverrideMutex sync.RWMutex
+var dbOverride GremelDB
+var privateDBCount atomic.Int64
+
 // GetGremelDB returns a singleton instance of the GremelDB
 // This is a simple way to ensure that we only have one database instance in the application
 // and that it is shared across all components that need it.
+//
+// The database is in-memory, unless 'config.db' (or '--db') names a file.
 func GetGremelDB() GremelDB {
+	dbOverrideMutex.RLock()
+	override := dbOverride
+	dbOverrideMutex.RUnlock()
+	if override != nil {
+		return override
+	}
 	dbSingleton.Do(func() {
-		dbInstance = newNamedSQLiteGremelDB("gremel")
+		if path := viper.GetString(data.CONF_DB); path != "" {
+			dbInstance = newFileSQLiteGremelDB(path)
+		} else {
+			dbInstance = newNamedSQLiteGremelDB("gremel")
+		}
 	})
 	return dbInstance
 }
 
+// UsePrivateGremelDB makes GetGremelDB return a new, empty, in-memory database
+// until restore is called, e.g. so that 'gremel convert' can mount its input
+// without touching the workspace in 'config.db'.  restore closes it.
+func UsePrivateGremelDB() (restore func()) {
+	private := newNamedSQLiteGremelDB(fmt.Sprintf("gremel_private_%d", privateDBCount.Add(1)))
+	dbOverrideMutex.Lock()
+	previous := dbOverride
+	dbOverride = private
+	dbOverrideMutex.Unlock()
+	return func() {
+		dbOverrideMutex.Lock()
+		dbOverride = previous
+		dbOverrideMutex.Unlock()
+		private.Close()
+	}
+}
+
 /*
 -- Create the accounts table
 CREATE TABLE accounts (
@@ -1,21 +1,30 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"math"
 	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/jbirtley88/gremel/data"
 	"github.com/jbirtley88/gremel/helper"
-	_ "github.com/mattn/go-sqlite3"
 )
 
 type SQLiteGremelDB struct {
+	// Held for writing by anything which changes the database, and for reading
+	// by queries.  Mounts can be loaded in the background (e.g. by a followed
+	// log file), and SQLite's shared cache fails with 'table is locked' rather
+	// than waiting for the other connection.  It also guards the maps.
+	sync.RWMutex
+
 	db           *sql.DB
-	schemaByName map[string]data.Row
+	schemaByName map[string]data.Schema
 	mountByName  map[string]string
+	// Anything else we know about a mount, e.g. the options it was mounted with
+	mountInfoByName map[string]data.Row
 }
 
 // NewSQLiteGremelDB creates a new in-memory SQLite database connection
@@ -28,30 +37,47 @@ func newSQLiteGremelDB() GremelDB {
 func newNamedSQLiteGremelDB(dbName string) GremelDB {
 	// Connect to named in-memory SQLite database
 	// Using file::memory: syntax with cache=shared ensures each named database is separate
-	// dbPath := "/var/tmp/" + dbName + ".db"
-	// _ = os.Remove(dbPath)
-	// _ = os.WriteFile(dbPath, []byte{}, 0644)
-	// connectionString := fmt.Sprintf("file:%s?cache=shared", dbPath)
 	connectionString := fmt.Sprintf("file:%s?mode=memory&cache=shared", dbName)
-	db, err := sql.Open("sqlite3", connectionString)
+	return openSQLiteGremelDB(connectionString, fmt.Sprintf("in-memory SQLite database %q", dbName))
+}
+
+// newFileSQLiteGremelDB opens (or creates) an on-disk SQLite database.
+//
+// The tables, schemas and mounts in it are restored, so that the workspace
+// survives a restart without having to mount everything again.
+func newFileSQLiteGremelDB(path string) GremelDB {
+	connectionString := fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL", path)
+	return openSQLiteGremelDB(connectionString, fmt.Sprintf("SQLite database %q", path))
+}
+
+func openSQLiteGremelDB(connectionString string, description string) GremelDB {
+	db, err := sql.Open(readOnlyDriverName, connectionString)
 	if err != nil {
-		return NewErrorGremelDB(fmt.Errorf("failed to open in-memory SQLite database %q: %w", dbName, err))
+		return NewErrorGremelDB(fmt.Errorf("failed to open %s: %w", description, err))
 	}
 
 	// Test the connection
 	if err := db.Ping(); err != nil {
-		return NewErrorGremelDB(fmt.Errorf("failed to ping SQLite database %q: %w", dbName, err))
+		return NewErrorGremelDB(fmt.Errorf("failed to ping %s: %w", description, err))
 	}
 
-	return &SQLiteGremelDB{
-		db:           db,
-		schemaByName: make(map[string]data.Row),
-		mountByName:  make(map[string]string),
+	gremelDB := &SQLiteGremelDB{
+		db:              db,
+		schemaByName:    make(map[string]data.Schema),
+		mountByName:     make(map[string]string),
+		mountInfoByName: make(map[string]data.Row),
 	}
+	if err := gremelDB.loadMetadata(); err != nil {
+		db.Close()
+		return NewErrorGremelDB(fmt.Errorf("failed to load metadata from %s: %w", description, err))
+	}
+	return gremelDB
 }
 
 // Close closes the database connection
 func (db *SQLiteGremelDB) Close() error {
+	db.Lock()
+	defer db.Unlock()
 	return db.db.Close()
 }
 
@@ -72,65 +98,94 @@ func (db *SQLiteGremelDB) getColumnType(value any) (string, error) {
 		return "TEXT", nil
 	case reflect.Bool:
 		return "BOOLEAN", nil
+	case reflect.Struct:
+		return "TIMESTAMP", nil
 	default:
 		return "", fmt.Errorf("unsupported data type: %T", value)
 	}
 }
 
-func (db *SQLiteGremelDB) getCreateTableSQL(tableName string, columnTypes map[string]reflect.Kind) (string, data.Row, error) {
+// getCreateTableSQL creates the table with its columns in the order given by
+// columns, followed by any others in columnTypes (sorted by name)
+func (db *SQLiteGremelDB) getCreateTableSQL(tableName string, columns []string, columnTypes map[string]reflect.Kind) (string, data.Schema, error) {
 	sqlLines := make([]string, 0)
 
 	// Handle empty row case
-	sqlLines = append(sqlLines, fmt.Sprintf("DROP TABLE IF EXISTS %s;", tableName))
+	sqlLines = append(sqlLines, fmt.Sprintf("DROP TABLE IF EXISTS %s;", quoteIdentifier(tableName)))
 	if len(columnTypes) == 0 {
-		sqlLines = append(sqlLines, fmt.Sprintf("CREATE TABLE %s (", tableName))
+		sqlLines = append(sqlLines, fmt.Sprintf("CREATE TABLE %s (", quoteIdentifier(tableName)))
 		sqlLines = append(sqlLines, "    _placeholder INTEGER") // Add a placeholder column for empty tables
 		sqlLines = append(sqlLines, ");")
 		return strings.Join(sqlLines, "\n"), nil, nil
 	}
 
-	sqlLines = append(sqlLines, fmt.Sprintf("CREATE TABLE %s (", tableName))
+	sqlLines = append(sqlLines, fmt.Sprintf("CREATE TABLE %s (", quoteIdentifier(tableName)))
 
 	// Collect column definitions first
-	schema := make(data.Row)
-	columns := make([]string, 0, len(columnTypes))
-	for fieldName, columnType := range columnTypes {
-		typeName := ""
-		switch columnType {
-		case reflect.Int, reflect.Int32, reflect.Int64:
-			typeName = "INTEGER"
-		case reflect.Float32, reflect.Float64:
-			typeName = "REAL"
-		case reflect.String:
-			typeName = "TEXT"
-		case reflect.Bool:
-			typeName = "BOOLEAN"
-		default:
-			return "", nil, fmt.Errorf("unsupported data type: %v", columnType)
+	fieldNames := make([]string, 0, len(columnTypes))
+	for fieldName := range columnTypes {
+		fieldNames = append(fieldNames, fieldName)
+	}
+	schema := make(data.Schema, 0, len(columnTypes))
+	columnDefinitions := make([]string, 0, len(columnTypes))
+	for _, fieldName := range data.OrderColumns(fieldNames, columns) {
+		typeName, err := getSQLTypeName(columnTypes[fieldName])
+		if err != nil {
+			return "", nil, err
 		}
-		schema[fieldName] = typeName
-		columns = append(columns, fmt.Sprintf("    %s %s", fieldName, typeName))
+		schema = append(schema, data.Column{Name: fieldName, Type: typeName})
+		columnDefinitions = append(columnDefinitions, fmt.Sprintf("    %s %s", quoteIdentifier(fieldName), typeName))
 	}
 
 	// Join columns with commas and add to SQL lines
-	for i := range columns {
-		if i < len(columns)-1 {
-			columns[i] += ","
+	for i := range columnDefinitions {
+		if i < len(columnDefinitions)-1 {
+			columnDefinitions[i] += ","
 		}
-		sqlLines = append(sqlLines, columns[i])
+		sqlLines = append(sqlLines, columnDefinitions[i])
 	}
 
 	sqlLines = append(sqlLines, ");")
 	return strings.Join(sqlLines, "\n"), schema, nil
 }
 
-func (db *SQLiteGremelDB) CreateSchema(tableName string, rows []data.Row) error {
+// quoteIdentifier quotes a table or column name for SQL, so that names with
+// spaces or dots in them (e.g. 'address.city') or which are keywords work
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// getSQLTypeName maps the kinds produced by helper.DeriveSchema onto SQLite column types
+func getSQLTypeName(columnType reflect.Kind) (string, error) {
+	switch columnType {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return "INTEGER", nil
+	case reflect.Float32, reflect.Float64:
+		return "REAL", nil
+	case reflect.String:
+		return "TEXT", nil
+	case reflect.Bool:
+		return "BOOLEAN", nil
+	case reflect.Struct:
+		// i.e. time.Time - the driver reads TIMESTAMP columns back as time.Time
+		return "TIMESTAMP", nil
+	default:
+		return "", fmt.Errorf("unsupported data type: %v", columnType)
+	}
+}
+
+// CreateSchema (re)creates the table, with the columns and their types worked
+// out from rows.  The columns are in the order given by columns (e.g. the
+// order they appear in the source), and any others go after them.
+func (db *SQLiteGremelDB) CreateSchema(tableName string, columns []string, rows []data.Row) error {
+	db.Lock()
+	defer db.Unlock()
 	derivedSchema, err := helper.DeriveSchema(rows)
 	if err != nil {
 		return fmt.Errorf("CreateSchema(%s): failed to derive schema: %w", tableName, err)
 	}
 
-	createTableSQL, schema, err := db.getCreateTableSQL(tableName, derivedSchema)
+	createTableSQL, schema, err := db.getCreateTableSQL(tableName, columns, derivedSchema)
 	if err != nil {
 		return fmt.Errorf("CreateSchema(%s): failed to generate CREATE TABLE SQL: %w", tableName, err)
 	}
@@ -144,24 +199,33 @@ func (db *SQLiteGremelDB) CreateSchema(tableName string, rows []data.Row) error
 
 	// Stash the schema for later retrieval
 	db.schemaByName[tableName] = schema
+	if err := db.saveMetadata(db.db, metadataSchema, tableName, schema); err != nil {
+		return fmt.Errorf("CreateSchema(%s): %w", tableName, err)
+	}
 
 	// TODO(jb): Create indexes for better query performance
 
 	return nil
 }
 
-func (db *SQLiteGremelDB) GetSchema(tableName string) (data.Row, error) {
+// GetSchema returns the columns of the table, in order
+func (db *SQLiteGremelDB) GetSchema(tableName string) (data.Schema, error) {
+	db.RLock()
+	defer db.RUnlock()
 	schema, exists := db.schemaByName[tableName]
 	if !exists {
 		return nil, fmt.Errorf("GetSchema(%s): schema not found", tableName)
 	}
-	return schema, nil
+	// A copy, since columns can be added to the table while it is being loaded
+	return append(data.Schema{}, schema...), nil
 }
 
 func (db *SQLiteGremelDB) DropSchema(tableName string) error {
+	db.Lock()
+	defer db.Unlock()
 	// Drop views first (in reverse order of dependency)
 	dropStatements := []string{
-		fmt.Sprintf("DROP TABLE IF EXISTS %s;", tableName),
+		fmt.Sprintf("DROP TABLE IF EXISTS %s;", quoteIdentifier(tableName)),
 	}
 
 	for _, dropSQL := range dropStatements {
@@ -170,10 +234,55 @@ func (db *SQLiteGremelDB) DropSchema(tableName string) error {
 		}
 	}
 
+	delete(db.schemaByName, tableName)
+	if err := db.deleteMetadata(db.db, metadataSchema, tableName); err != nil {
+		return fmt.Errorf("DropSchema(%s): %w", tableName, err)
+	}
+	return nil
+}
+
+// RenameSchema replaces toTable (if there is one) with fromTable, e.g. once
+// a table which was loaded under another name is complete
+func (db *SQLiteGremelDB) RenameSchema(fromTable string, toTable string) error {
+	db.Lock()
+	defer db.Unlock()
+	schema, exists := db.schemaByName[fromTable]
+	if !exists {
+		return fmt.Errorf("RenameSchema(%s): schema not found", fromTable)
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("RenameSchema(%s): %w", fromTable, err)
+	}
+	defer tx.Rollback()
+	renameStatements := []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s;", quoteIdentifier(toTable)),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", quoteIdentifier(fromTable), quoteIdentifier(toTable)),
+	}
+	for _, renameSQL := range renameStatements {
+		if _, err := tx.Exec(renameSQL); err != nil {
+			return fmt.Errorf("RenameSchema(%s): failed to rename to %s: %w", fromTable, toTable, err)
+		}
+	}
+	if err := db.saveMetadata(tx, metadataSchema, toTable, schema); err != nil {
+		return fmt.Errorf("RenameSchema(%s): %w", fromTable, err)
+	}
+	if err := db.deleteMetadata(tx, metadataSchema, fromTable); err != nil {
+		return fmt.Errorf("RenameSchema(%s): %w", fromTable, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("RenameSchema(%s): %w", fromTable, err)
+	}
+
+	db.schemaByName[toTable] = schema
+	delete(db.schemaByName, fromTable)
 	return nil
 }
 
 func (db *SQLiteGremelDB) GetTables() ([]string, error) {
+	db.RLock()
+	defer db.RUnlock()
 	var tables []string
 	for t := range db.schemaByName {
 		tables = append(tables, t)
@@ -182,6 +291,8 @@ func (db *SQLiteGremelDB) GetTables() ([]string, error) {
 }
 
 func (db *SQLiteGremelDB) GetMount(tableName string) (data.Row, error) {
+	db.RLock()
+	defer db.RUnlock()
 	if tableName == "" {
 		// Get all mounts
 		row := make(data.Row)
@@ -195,58 +306,129 @@ func (db *SQLiteGremelDB) GetMount(tableName string) (data.Row, error) {
 	if !exists {
 		return nil, fmt.Errorf("GetMount(%s): mount not found", tableName)
 	}
-	return data.Row{tableName: source}, nil
+	mountInfo := data.Row{tableName: source}
+	for key, value := range db.mountInfoByName[tableName] {
+		mountInfo[key] = value
+	}
+	return mountInfo, nil
 }
 
-// Register a mount for a table
+// Register a mount for a table.
+// (Re)mounting a table forgets anything previously recorded by SetMountInfo.
 func (db *SQLiteGremelDB) Mount(tableName string, source string) error {
+	db.Lock()
+	defer db.Unlock()
 	db.mountByName[tableName] = source
+	delete(db.mountInfoByName, tableName)
+	if err := db.saveMetadata(db.db, metadataMount, tableName, source); err != nil {
+		return fmt.Errorf("Mount(%s): %w", tableName, err)
+	}
+	if err := db.deleteMetadata(db.db, metadataMountInfo, tableName); err != nil {
+		return fmt.Errorf("Mount(%s): %w", tableName, err)
+	}
 	return nil
 }
 
-func (db *SQLiteGremelDB) InsertRows(tableName string, rows []data.Row) error {
-	if len(rows) == 0 {
-		return nil // Nothing to insert
+// SetMountInfo records extra information about a mount, which is reported by GetMount(tableName)
+func (db *SQLiteGremelDB) SetMountInfo(tableName string, key string, value any) error {
+	db.Lock()
+	defer db.Unlock()
+	if _, exists := db.mountByName[tableName]; !exists {
+		return fmt.Errorf("SetMountInfo(%s): mount not found", tableName)
+	}
+	if key == tableName {
+		return fmt.Errorf("SetMountInfo(%s): key '%s' is reserved", tableName, key)
+	}
+	if db.mountInfoByName[tableName] == nil {
+		db.mountInfoByName[tableName] = make(data.Row)
+	}
+	db.mountInfoByName[tableName][key] = value
+	if err := db.saveMetadata(db.db, metadataMountInfo, tableName, db.mountInfoByName[tableName]); err != nil {
+		return fmt.Errorf("SetMountInfo(%s): %w", tableName, err)
 	}
+	return nil
+}
 
-	// Prepare the insert statement
-	fieldNames := make([]string, 0, len(rows[0]))
-	placeholders := make([]string, 0, len(rows[0]))
-	for fieldName := range rows[0] {
-		fieldNames = append(fieldNames, fieldName)
-		placeholders = append(placeholders, "?")
+// SaveAs writes a copy of the whole database (including the metadata) to
+// path, which can later be opened with --db
+func (db *SQLiteGremelDB) SaveAs(path string) error {
+	db.RLock()
+	defer db.RUnlock()
+	if _, err := db.db.Exec("VACUUM INTO ?;", path); err != nil {
+		return fmt.Errorf("SaveAs(%s): %w", path, err)
 	}
+	return nil
+}
 
-	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);",
-		tableName,
-		strings.Join(fieldNames, ", "),
-		strings.Join(placeholders, ", "),
-	)
+func (db *SQLiteGremelDB) InsertRows(tableName string, rows []data.Row) error {
+	if len(rows) == 0 {
+		return nil // Nothing to insert
+	}
 
-	stmt, err := db.db.Prepare(insertSQL)
+	_, err := db.InsertRowStream(tableName, data.NewRowSliceIterator(rows), len(rows))
 	if err != nil {
-		return fmt.Errorf("InsertRows(%s): failed to prepare insert statement: %w", tableName, err)
+		return fmt.Errorf("InsertRows(%s): %w", tableName, err)
 	}
-	defer stmt.Close()
+	return nil
+}
 
-	// Insert each row
-	for _, row := range rows {
-		values := make([]any, 0, len(fieldNames))
-		for _, fieldName := range fieldNames {
-			values = append(values, row[fieldName])
-		}
+// InsertRowStream inserts every row from the iterator, using a single prepared
+// statement and committing a transaction every batchSize rows.
+//
+// Columns which were not seen when the schema was created (e.g. because they
+// only appear after the rows that were sampled) are added to the table as
+// they are encountered.
+func (db *SQLiteGremelDB) InsertRowStream(tableName string, rows data.RowIterator, batchSize int) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	loader := &bulkLoader{
+		db:        db,
+		tableName: tableName,
+		batchSize: batchSize,
+	}
+	defer loader.rollback()
 
-		if _, err := stmt.Exec(values...); err != nil {
-			return fmt.Errorf("InsertRows(%s): failed to execute insert statement: %w", tableName, err)
+	for rows.Next() {
+		if err := loader.insert(rows.Row()); err != nil {
+			return loader.count, fmt.Errorf("InsertRowStream(%s): row %d: %w", tableName, loader.count+1, err)
 		}
 	}
-
-	return nil
+	if err := rows.Err(); err != nil {
+		return loader.count, fmt.Errorf("InsertRowStream(%s): %w", tableName, err)
+	}
+	if err := loader.commit(); err != nil {
+		return loader.count, fmt.Errorf("InsertRowStream(%s): %w", tableName, err)
+	}
+	return loader.count, nil
 }
 
 func (db *SQLiteGremelDB) Query(sqlQuery string) ([]data.Row, []string, error) {
-	rows, err := db.db.Query(sqlQuery)
+	return db.QueryContext(context.Background(), sqlQuery)
+}
+
+// QueryContext runs the query until ctx is cancelled, at which point SQLite
+// is interrupted and the query fails.
+//
+// The query is read-only: SQLite's authorizer denies anything other than
+// reading the mounted tables, with an error which wraps ErrReadOnly.
+func (db *SQLiteGremelDB) QueryContext(ctx context.Context, sqlQuery string) ([]data.Row, []string, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	conn, guard, release, err := db.readOnlyConn(ctx)
 	if err != nil {
+		return nil, nil, fmt.Errorf("Query(%s): failed to get a connection: %w", sqlQuery, err)
+	}
+	defer release()
+
+	rows, err := conn.QueryContext(ctx, sqlQuery)
+	if err != nil {
+		if readOnlyErr := guard.readOnlyError(err); readOnlyErr != nil {
+			return nil, nil, fmt.Errorf("Query(%s): %w", sqlQuery, readOnlyErr)
+		}
 		return nil, nil, fmt.Errorf("Query(%s): failed to execute query: %w", sqlQuery, err)
 	}
 	defer rows.Close()
@@ -290,6 +472,9 @@ func (db *SQLiteGremelDB) Query(sqlQuery string) ([]data.Row, []string, error) {
 	}
 
 	if err := rows.Err(); err != nil {
+		if readOnlyErr := guard.readOnlyError(err); readOnlyErr != nil {
+			return nil, nil, fmt.Errorf("Query(%s): %w", sqlQuery, readOnlyErr)
+		}
 		return nil, nil, fmt.Errorf("Query(%s): row iteration error: %w", sqlQuery, err)
 	}
 
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -156,26 +157,29 @@ func TestSQLiteGremelDB_getCreateTableSQL(t *testing.T) {
 
 		columnTypes, err := helper.DeriveSchema([]data.Row{row})
 		require.NoError(t, err)
-		sql, schema, err := db.getCreateTableSQL("users", columnTypes)
+		// 'active' isn't in the column order, so goes at the end
+		sql, schema, err := db.getCreateTableSQL("users", []string{"id", "name", "age", "salary"}, columnTypes)
 		assert.NoError(t, err)
 		assert.NotEmpty(t, sql)
 		assert.NotNil(t, schema)
-		assert.Equal(t, schema, data.Row{
-			"id":     "INTEGER",
-			"name":   "TEXT",
-			"age":    "INTEGER",
-			"salary": "REAL",
-			"active": "BOOLEAN",
-		})
+		assert.Equal(t, data.Schema{
+			{Name: "id", Type: "INTEGER"},
+			{Name: "name", Type: "TEXT"},
+			{Name: "age", Type: "INTEGER"},
+			{Name: "salary", Type: "REAL"},
+			{Name: "active", Type: "BOOLEAN"},
+		}, schema)
 		// Check that SQL contains CREATE TABLE statement
-		assert.Contains(t, sql, "CREATE TABLE users (")
+		assert.Contains(t, sql, `CREATE TABLE "users" (`)
 
 		// Check that all columns are present with correct types
-		assert.Contains(t, sql, "id INTEGER")
-		assert.Contains(t, sql, "name TEXT")
-		assert.Contains(t, sql, "age INTEGER")
-		assert.Contains(t, sql, "salary REAL")
-		assert.Contains(t, sql, "active BOOLEAN")
+		assert.Contains(t, sql, `"id" INTEGER`)
+		assert.Contains(t, sql, `"name" TEXT`)
+		assert.Contains(t, sql, `"age" INTEGER`)
+		assert.Contains(t, sql, `"salary" REAL`)
+		assert.Contains(t, sql, `"active" BOOLEAN`)
+
+		assert.Regexp(t, `(?s)"id" INTEGER,\s+"name" TEXT,\s+"age" INTEGER,\s+"salary" REAL,\s+"active" BOOLEAN`, sql)
 
 		// Check that SQL ends properly
 		assert.Contains(t, sql, ");")
@@ -186,9 +190,9 @@ func TestSQLiteGremelDB_getCreateTableSQL(t *testing.T) {
 
 		columnTypes, err := helper.DeriveSchema([]data.Row{row})
 		require.NoError(t, err)
-		sql, schema, err := db.getCreateTableSQL("empty_table", columnTypes)
+		sql, schema, err := db.getCreateTableSQL("empty_table", nil, columnTypes)
 		assert.NoError(t, err)
-		assert.Contains(t, sql, "CREATE TABLE empty_table (")
+		assert.Contains(t, sql, `CREATE TABLE "empty_table" (`)
 		assert.Contains(t, sql, "_placeholder INTEGER") // Empty tables get a placeholder column
 		assert.Contains(t, sql, ");")
 
@@ -206,7 +210,7 @@ func TestSQLiteGremelDB_getCreateTableSQL(t *testing.T) {
 		require.Nil(t, columnTypes)
 
 		// Even though DeriveSchema failed, we can still test getCreateTableSQL's error handling
-		sql, schema, err := db.getCreateTableSQL("test_table", map[string]reflect.Kind{
+		sql, schema, err := db.getCreateTableSQL("test_table", nil, map[string]reflect.Kind{
 			"id":          reflect.Int64,
 			"unsupported": reflect.Complex128,
 		})
@@ -223,13 +227,11 @@ func TestSQLiteGremelDB_getCreateTableSQL(t *testing.T) {
 
 		columnTypes, err := helper.DeriveSchema([]data.Row{row})
 		require.NoError(t, err)
-		sql, schema, err := db.getCreateTableSQL("table_with_underscores", columnTypes)
+		sql, schema, err := db.getCreateTableSQL("table_with_underscores", nil, columnTypes)
 		assert.NoError(t, err)
-		assert.Contains(t, sql, "CREATE TABLE table_with_underscores (")
+		assert.Contains(t, sql, `CREATE TABLE "table_with_underscores" (`)
 		assert.NotNil(t, schema)
-		assert.Equal(t, schema, data.Row{
-			"field1": "TEXT",
-		})
+		assert.Equal(t, data.Schema{{Name: "field1", Type: "TEXT"}}, schema)
 	})
 }
 
@@ -244,7 +246,7 @@ func TestSQLiteGremelDB_CreateSchema(t *testing.T) {
 			"email": "jb@example.com",
 		}
 
-		err := db.CreateSchema("users", []data.Row{row})
+		err := db.CreateSchema("users", nil, []data.Row{row})
 		assert.NoError(t, err)
 
 		// Verify table was created by querying schema
@@ -265,7 +267,7 @@ func TestSQLiteGremelDB_CreateSchema(t *testing.T) {
 			"bool_field":   true,
 		}
 
-		err := db.CreateSchema("mixed_types", []data.Row{row})
+		err := db.CreateSchema("mixed_types", nil, []data.Row{row})
 		assert.NoError(t, err)
 
 		// Verify table was created
@@ -284,7 +286,7 @@ func TestSQLiteGremelDB_CreateSchema(t *testing.T) {
 			"unsupported": complex(1, 2),
 		}
 
-		err := db.CreateSchema("test_table", []data.Row{row})
+		err := db.CreateSchema("test_table", nil, []data.Row{row})
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "CreateSchema(test_table): failed to derive schema")
 		assert.Contains(t, err.Error(), "unsupported data type")
@@ -299,11 +301,11 @@ func TestSQLiteGremelDB_CreateSchema(t *testing.T) {
 		}
 
 		// Create table first time
-		err := db.CreateSchema("duplicate_table", []data.Row{row})
+		err := db.CreateSchema("duplicate_table", nil, []data.Row{row})
 		assert.NoError(t, err)
 
 		// Try to create the same table again - should fail
-		err = db.CreateSchema("duplicate_table", []data.Row{row})
+		err = db.CreateSchema("duplicate_table", nil, []data.Row{row})
 		assert.NoError(t, err)
 	})
 
@@ -313,7 +315,7 @@ func TestSQLiteGremelDB_CreateSchema(t *testing.T) {
 
 		row := data.Row{}
 
-		err := db.CreateSchema("empty_table", []data.Row{row})
+		err := db.CreateSchema("empty_table", nil, []data.Row{row})
 		assert.NoError(t, err)
 
 		// Verify table was created
@@ -334,7 +336,7 @@ func TestSQLiteGremelDB_DropSchema(t *testing.T) {
 			"id":   1,
 			"name": "test",
 		}
-		err := db.CreateSchema("test_table", []data.Row{row})
+		err := db.CreateSchema("test_table", nil, []data.Row{row})
 		require.NoError(t, err)
 
 		// Verify table exists
@@ -376,7 +378,7 @@ func TestSQLiteGremelDB_DropSchema(t *testing.T) {
 				// Only create one table since we can't have duplicates
 				continue
 			}
-			err := db.CreateSchema(tableName, []data.Row{row})
+			err := db.CreateSchema(tableName, nil, []data.Row{row})
 			require.NoError(t, err)
 		}
 
@@ -392,6 +394,85 @@ func TestSQLiteGremelDB_DropSchema(t *testing.T) {
 	})
 }
 
+func TestSQLiteGremelDB_SetMountInfo(t *testing.T) {
+	db := newNamedSQLiteGremelDB("mountinfo").(*SQLiteGremelDB)
+	defer db.Close()
+
+	// Can't describe a mount which doesn't exist
+	assert.Error(t, db.SetMountInfo("people", "options", "format=csv"))
+
+	require.NoError(t, db.Mount("people", "people.csv"))
+	require.NoError(t, db.SetMountInfo("people", "options", map[string]string{"format": "csv"}))
+	assert.Error(t, db.SetMountInfo("people", "people", "clobbered"))
+
+	mountInfo, err := db.GetMount("people")
+	require.NoError(t, err)
+	assert.Equal(t, data.Row{"people": "people.csv", "options": map[string]string{"format": "csv"}}, mountInfo)
+
+	// All mounts just lists the sources
+	mountInfo, err = db.GetMount("")
+	require.NoError(t, err)
+	assert.Equal(t, data.Row{"people": "people.csv"}, mountInfo)
+
+	// Remounting starts afresh
+	require.NoError(t, db.Mount("people", "people.json"))
+	mountInfo, err = db.GetMount("people")
+	require.NoError(t, err)
+	assert.Equal(t, data.Row{"people": "people.json"}, mountInfo)
+}
+
+func TestSQLiteGremelDB_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gremel.sqlite")
+	db := newFileSQLiteGremelDB(path).(*SQLiteGremelDB)
+	require.NoError(t, db.CreateSchema("people", nil, []data.Row{{"id": 1, "name": "Alice"}}))
+	require.NoError(t, db.InsertRows("people", []data.Row{{"id": 1, "name": "Alice"}, {"id": 2, "name": "Bob"}}))
+	require.NoError(t, db.Mount("people", "people.csv"))
+	require.NoError(t, db.SetMountInfo("people", "options", map[string]string{"format": "csv"}))
+	require.NoError(t, db.SetMountInfo("people", "tables", []string{"people_addresses"}))
+	schema, err := db.GetSchema("people")
+	require.NoError(t, err)
+
+	// A copy of the whole session
+	savedPath := filepath.Join(t.TempDir(), "saved.sqlite")
+	require.NoError(t, db.SaveAs(savedPath))
+	require.NoError(t, db.Close())
+
+	for _, reopenPath := range []string{path, savedPath} {
+		reopened, isSQLite := newFileSQLiteGremelDB(reopenPath).(*SQLiteGremelDB)
+		require.True(t, isSQLite, reopenPath)
+
+		tables, err := reopened.GetTables()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"people"}, tables)
+
+		restoredSchema, err := reopened.GetSchema("people")
+		require.NoError(t, err)
+		assert.Equal(t, schema, restoredSchema)
+
+		mountInfo, err := reopened.GetMount("people")
+		require.NoError(t, err)
+		assert.Equal(t, data.Row{
+			"people":  "people.csv",
+			"options": map[string]string{"format": "csv"},
+			"tables":  []string{"people_addresses"},
+		}, mountInfo)
+
+		rows, _, err := reopened.Query("SELECT name FROM people ORDER BY id;")
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		assert.Equal(t, "Bob", rows[1]["name"])
+
+		// Dropping a table forgets it for next time, too
+		require.NoError(t, reopened.DropSchema("people"))
+		require.NoError(t, reopened.Close())
+		reopened = newFileSQLiteGremelDB(reopenPath).(*SQLiteGremelDB)
+		tables, err = reopened.GetTables()
+		require.NoError(t, err)
+		assert.Empty(t, tables)
+		require.NoError(t, reopened.Close())
+	}
+}
+
 func TestSQLiteGremelDB_Integration(t *testing.T) {
 	t.Run("complete lifecycle - create, use, drop", func(t *testing.T) {
 		db := newSQLiteGremelDB().(*SQLiteGremelDB)
@@ -406,7 +487,7 @@ func TestSQLiteGremelDB_Integration(t *testing.T) {
 		}
 
 		// Create schema
-		err := db.CreateSchema(tableName, []data.Row{row})
+		err := db.CreateSchema(tableName, nil, []data.Row{row})
 		require.NoError(t, err)
 
 		// Insert test data
@@ -462,7 +543,7 @@ func TestSQLiteGremelDB_Integration(t *testing.T) {
 
 		// Create all schemas
 		for tableName, row := range tables {
-			err := db.CreateSchema(tableName, []data.Row{row})
+			err := db.CreateSchema(tableName, nil, []data.Row{row})
 			require.NoError(t, err)
 		}
 
@@ -482,8 +563,34 @@ func TestSQLiteGremelDB_Integration(t *testing.T) {
 
 		// Verify all tables are gone
 		var totalCount int
-		err := db.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table'").Scan(&totalCount)
+		err := db.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name != ?", metadataTable).Scan(&totalCount)
 		assert.NoError(t, err)
 		assert.Equal(t, 0, totalCount)
 	})
 }
+
+func TestSQLiteGremelDB_RenameSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gremel.sqlite")
+	db := newFileSQLiteGremelDB(path).(*SQLiteGremelDB)
+	require.NoError(t, db.CreateSchema("people", nil, []data.Row{{"id": 1}}))
+	require.NoError(t, db.InsertRows("people", []data.Row{{"id": 1}}))
+	require.NoError(t, db.CreateSchema("loading_people", []string{"id", "name"}, []data.Row{{"id": 1, "name": "Alice"}}))
+	require.NoError(t, db.InsertRows("loading_people", []data.Row{{"id": 1, "name": "Alice"}, {"id": 2, "name": "Bob"}}))
+
+	require.NoError(t, db.RenameSchema("loading_people", "people"))
+	assert.Error(t, db.RenameSchema("loading_people", "people"))
+	require.NoError(t, db.Close())
+
+	// The metadata follows the table
+	db = newFileSQLiteGremelDB(path).(*SQLiteGremelDB)
+	defer db.Close()
+	tables, err := db.GetTables()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"people"}, tables)
+	schema, err := db.GetSchema("people")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name"}, schema.Names())
+	rows, _, err := db.Query("SELECT name FROM people ORDER BY id")
+	require.NoError(t, err)
+	assert.Equal(t, []data.Row{{"name": "Alice"}, {"name": "Bob"}}, rows)
+}
@@ -0,0 +1,223 @@
+package helper
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/xuri/excelize/v2"
+)
+
+// Excel won't have a worksheet name longer than this
+const maxSheetNameLength = 31
+
+// How times are shown (they are stored as Excel dates, so can still be
+// sorted, filtered and formatted differently)
+const excelDateFormat = "yyyy-mm-dd hh:mm:ss"
+
+// ExcelWorkbook writes query results to worksheets of a new .xlsx workbook.
+//
+// Each worksheet has the headings in bold, with an auto-filter on them, and
+// numbers, booleans and times are written as such (rather than as text).  Rows are
+// streamed, so a worksheet can be larger than would fit in memory.
+type ExcelWorkbook struct {
+	file      *excelize.File
+	boldStyle int
+	dateStyle int
+	sheets    []string
+}
+
+func NewExcelWorkbook() (*ExcelWorkbook, error) {
+	file := excelize.NewFile()
+	boldStyle, err := file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("NewExcelWorkbook(): %w", err)
+	}
+	dateFormat := excelDateFormat
+	dateStyle, err := file.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("NewExcelWorkbook(): %w", err)
+	}
+	return &ExcelWorkbook{file: file, boldStyle: boldStyle, dateStyle: dateStyle}, nil
+}
+
+// Sheets returns the names of the worksheets which have been added, in order
+func (wb *ExcelWorkbook) Sheets() []string {
+	return wb.sheets
+}
+
+// WriteSheet adds a worksheet with all of rows in it
+func (wb *ExcelWorkbook) WriteSheet(name string, columns []string, rows []data.Row, headings bool) error {
+	sheet, err := wb.StartSheet(name, columns, headings)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := sheet.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return sheet.Close()
+}
+
+// StartSheet adds a worksheet called name (or 'Sheet1', 'Sheet2' and so on if
+// name is empty), which rows are then written to one at a time.  Characters
+// which Excel doesn't allow in worksheet names are replaced, and a name which
+// is already taken gets a number added.
+//
+// Only one worksheet can be written at a time, and it must be closed before
+// the next is started.
+func (wb *ExcelWorkbook) StartSheet(name string, columns []string, headings bool) (*ExcelSheetWriter, error) {
+	name = wb.uniqueSheetName(name)
+	if len(wb.sheets) == 0 {
+		// A new workbook comes with an empty 'Sheet1'
+		if err := wb.file.SetSheetName(wb.file.GetSheetName(0), name); err != nil {
+			return nil, fmt.Errorf("StartSheet(%s): %w", name, err)
+		}
+	} else if _, err := wb.file.NewSheet(name); err != nil {
+		return nil, fmt.Errorf("StartSheet(%s): %w", name, err)
+	}
+	wb.sheets = append(wb.sheets, name)
+
+	stream, err := wb.file.NewStreamWriter(name)
+	if err != nil {
+		return nil, fmt.Errorf("StartSheet(%s): %w", name, err)
+	}
+	sheet := &ExcelSheetWriter{workbook: wb, name: name, columns: columns, stream: stream}
+	if headings && len(columns) > 0 {
+		cells := make([]any, len(columns))
+		for i, column := range columns {
+			cells[i] = excelize.Cell{StyleID: wb.boldStyle, Value: column}
+		}
+		if err := sheet.writeCells(cells); err != nil {
+			return nil, err
+		}
+		sheet.filtered = true
+	}
+	return sheet, nil
+}
+
+// SaveAs writes the workbook to path
+func (wb *ExcelWorkbook) SaveAs(path string) error {
+	if len(wb.sheets) > 0 {
+		wb.file.SetActiveSheet(0)
+	}
+	if err := wb.file.SaveAs(path); err != nil {
+		return fmt.Errorf("SaveAs(%s): %w", path, err)
+	}
+	return nil
+}
+
+// Close releases the workbook (which is not saved)
+func (wb *ExcelWorkbook) Close() error {
+	return wb.file.Close()
+}
+
+func (wb *ExcelWorkbook) uniqueSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		name = fmt.Sprintf("Sheet%d", len(wb.sheets)+1)
+	}
+	name = truncateRunes(name, maxSheetNameLength)
+
+	unique := name
+	for n := 2; wb.hasSheet(unique); n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		unique = truncateRunes(name, maxSheetNameLength-len(suffix)) + suffix
+	}
+	return unique
+}
+
+func (wb *ExcelWorkbook) hasSheet(name string) bool {
+	for _, sheet := range wb.sheets {
+		// Excel worksheet names are case-insensitive
+		if strings.EqualFold(sheet, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateRunes(s string, length int) string {
+	runes := []rune(s)
+	if len(runes) > length {
+		return string(runes[:length])
+	}
+	return s
+}
+
+// ExcelSheetWriter streams rows into one worksheet of an ExcelWorkbook
+type ExcelSheetWriter struct {
+	workbook *ExcelWorkbook
+	name     string
+	columns  []string
+	stream   *excelize.StreamWriter
+	rows     int
+	filtered bool
+}
+
+// WriteRow adds row to the end of the worksheet
+func (s *ExcelSheetWriter) WriteRow(row data.Row) error {
+	cells := make([]any, len(s.columns))
+	for i, column := range s.columns {
+		value := excelValue(row[column])
+		if _, isTime := value.(time.Time); isTime {
+			value = excelize.Cell{StyleID: s.workbook.dateStyle, Value: value}
+		}
+		cells[i] = value
+	}
+	return s.writeCells(cells)
+}
+
+func (s *ExcelSheetWriter) writeCells(cells []any) error {
+	s.rows++
+	cell, err := excelize.CoordinatesToCellName(1, s.rows)
+	if err != nil {
+		return fmt.Errorf("WriteRow(%s): %w", s.name, err)
+	}
+	if err := s.stream.SetRow(cell, cells); err != nil {
+		return fmt.Errorf("WriteRow(%s): %w", s.name, err)
+	}
+	return nil
+}
+
+// Close finishes the worksheet, and puts the auto-filter on the headings
+func (s *ExcelSheetWriter) Close() error {
+	if err := s.stream.Flush(); err != nil {
+		return fmt.Errorf("Close(%s): %w", s.name, err)
+	}
+	if !s.filtered {
+		return nil
+	}
+	lastCell, err := excelize.CoordinatesToCellName(len(s.columns), max(s.rows, 1))
+	if err != nil {
+		return fmt.Errorf("Close(%s): %w", s.name, err)
+	}
+	if err := s.workbook.file.AutoFilter(s.name, "A1:"+lastCell, nil); err != nil {
+		return fmt.Errorf("Close(%s): %w", s.name, err)
+	}
+	return nil
+}
+
+// excelValue is how a value is written to a cell.  Numbers, booleans and
+// times keep their type, so that they can be summed, sorted and so on in Excel.
+func excelValue(value any) any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case int, int32, int64, float32, float64, bool, string, time.Time:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return formatValue(v)
+	}
+}
@@ -0,0 +1,99 @@
+package helper
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestExcelWorkbookWritesDates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dates.xlsx")
+	wb, err := NewExcelWorkbook()
+	require.NoError(t, err)
+	when := time.Date(2024, 3, 1, 12, 30, 15, 0, time.UTC)
+	require.NoError(t, wb.WriteSheet("Dates", []string{"when"}, []data.Row{{"when": when}}, true))
+	require.NoError(t, wb.SaveAs(path))
+	require.NoError(t, wb.Close())
+
+	f, err := excelize.OpenFile(path)
+	require.NoError(t, err)
+	defer f.Close()
+	// A number (rather than text), shown as a date
+	raw, err := f.GetCellValue("Dates", "A2", excelize.Options{RawCellValue: true})
+	require.NoError(t, err)
+	serial, err := strconv.ParseFloat(raw, 64)
+	require.NoError(t, err, raw)
+	assert.InDelta(t, 45352.521007, serial, 0.000001)
+	shown, err := f.GetCellValue("Dates", "A2")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-01 12:30:15", shown)
+	styleID, err := f.GetCellStyle("Dates", "A2")
+	require.NoError(t, err)
+	style, err := f.GetStyle(styleID)
+	require.NoError(t, err)
+	require.NotNil(t, style.CustomNumFmt)
+	assert.Equal(t, excelDateFormat, *style.CustomNumFmt)
+}
+
+func TestExcelWorkbook(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.xlsx")
+	wb, err := NewExcelWorkbook()
+	require.NoError(t, err)
+	rows := []data.Row{
+		{"name": "Alice", "age": int64(30), "score": 1.5, "admin": true},
+		{"name": "Bob", "age": int64(25), "score": nil, "admin": false},
+	}
+	require.NoError(t, wb.WriteSheet("People", []string{"name", "age", "score", "admin"}, rows, true))
+	require.NoError(t, wb.WriteSheet("people", []string{"name"}, rows[:1], false))
+	require.NoError(t, wb.WriteSheet("a/b", []string{"name"}, nil, true))
+	assert.Equal(t, []string{"People", "people (2)", "a_b"}, wb.Sheets())
+	require.NoError(t, wb.SaveAs(path))
+	require.NoError(t, wb.Close())
+
+	f, err := excelize.OpenFile(path)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, []string{"People", "people (2)", "a_b"}, f.GetSheetList())
+
+	values, err := f.GetRows("People")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"name", "age", "score", "admin"}, {"Alice", "30", "1.5", "TRUE"}, {"Bob", "25", "", "FALSE"}}, values)
+
+	// Typed cells
+	cellType, err := f.GetCellType("People", "B2")
+	require.NoError(t, err)
+	assert.NotEqual(t, excelize.CellTypeSharedString, cellType)
+	assert.NotEqual(t, excelize.CellTypeInlineString, cellType)
+	cellType, err = f.GetCellType("People", "D2")
+	require.NoError(t, err)
+	assert.Equal(t, excelize.CellTypeBool, cellType)
+
+	// Bold headings
+	styleID, err := f.GetCellStyle("People", "A1")
+	require.NoError(t, err)
+	style, err := f.GetStyle(styleID)
+	require.NoError(t, err)
+	require.NotNil(t, style.Font)
+	assert.True(t, style.Font.Bold)
+
+	// Auto-filter, only on the sheets with headings
+	names := f.GetDefinedName()
+	var filters []string
+	for _, name := range names {
+		if name.Name == "_xlnm._FilterDatabase" {
+			filters = append(filters, name.RefersTo)
+		}
+	}
+	assert.ElementsMatch(t, []string{"'People'!$A$1:$D$3", "'a_b'!$A$1:$A$1"}, filters)
+
+	// Headless sheet
+	values, err = f.GetRows("people (2)")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"Alice"}}, values)
+}
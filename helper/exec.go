@@ -34,6 +34,10 @@ func GetCommandOutput(cmdAndArgs []string, timeout time.Duration) (*ExecResult,
 		cmd = exec.CommandContext(ctx, cmdAndArgs[0], cmdAndArgs[1:]...)
 	}
 
+	// Killing a shell doesn't kill its children, which would otherwise keep
+	// stdout open (and us waiting) long after the timeout
+	cmd.WaitDelay = time.Second
+
 	// Create buffers to capture stdout and stderr
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -5,8 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"strings"
 	"text/tabwriter"
+
+	"github.com/jbirtley88/gremel/data"
 )
 
 func TextOutput(rows []map[string]any, headings []string, writeTo io.Writer) error {
@@ -83,37 +84,13 @@ func TableOutput(rows []map[string]any, headings []string, writeTo io.Writer) er
 	return nil
 }
 
+// CSVOutput writes rows as CSV, with a heading line
 func CSVOutput(rows []map[string]any, headings []string, writeTo io.Writer) error {
-	byteWriter := bytes.NewBuffer(make([]byte, 0))
-
-	// Write headings
-	for i, heading := range headings {
-		if i > 0 {
-			byteWriter.WriteString(",")
-		}
-		byteWriter.WriteString(heading)
+	dataRows := make([]data.Row, len(rows))
+	for i, row := range rows {
+		dataRows[i] = row
 	}
-	byteWriter.WriteString("\n")
-
-	// Write rows
-	for _, row := range rows {
-		for i, heading := range headings {
-			if i > 0 {
-				byteWriter.WriteString(",")
-			}
-			if value, exists := row[heading]; exists {
-				formatted := formatValue(value)
-				if strings.Contains(formatted, ",") || strings.Contains(formatted, "\"") {
-					formatted = "\"" + strings.ReplaceAll(formatted, "\"", "\"\"") + "\""
-				}
-				byteWriter.WriteString(formatted)
-			}
-		}
-		byteWriter.WriteString("\n")
-	}
-
-	writeTo.Write(byteWriter.Bytes())
-	return nil
+	return (&delimitedWriter{delimiter: ','}).WriteResults(writeTo, headings, dataRows, true)
 }
 
 func JSONOutput(rows []map[string]any, writeTo io.Writer) error {
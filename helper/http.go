@@ -14,6 +14,13 @@ import (
 // It also lends itself very well to mocking and stubbing for unit tests.
 type HttpHelper interface {
 	Get(url string) (code int, body io.ReadCloser, err error)
+
+	// Fetch is like Get, but hands back the whole response so that the
+	// caller can see the headers (e.g. Content-Type)
+	Fetch(url string) (*http.Response, error)
+
+	// Head fetches just the headers (e.g. ETag, Last-Modified)
+	Head(url string) (*http.Response, error)
 }
 
 type HttpHelperBuilder struct {
@@ -44,12 +51,23 @@ type DefaultHttpHelper struct {
 }
 
 func (h *DefaultHttpHelper) Get(url string) (code int, body io.ReadCloser, err error) {
-	client := http.Client{
-		Timeout: h.timeout,
-	}
-	resp, err := client.Get(url)
+	resp, err := h.Fetch(url)
 	if err != nil {
 		return 0, nil, err
 	}
 	return resp.StatusCode, resp.Body, nil
 }
+
+func (h *DefaultHttpHelper) Fetch(url string) (*http.Response, error) {
+	client := http.Client{
+		Timeout: h.timeout,
+	}
+	return client.Get(url)
+}
+
+func (h *DefaultHttpHelper) Head(url string) (*http.Response, error) {
+	client := http.Client{
+		Timeout: h.timeout,
+	}
+	return client.Head(url)
+}
@@ -56,8 +56,9 @@ func NormaliseNumbers(rows []data.Row) []data.Row {
 					} else {
 						rows[i][k] = f
 					}
-				} else {
+				} else if v != nil {
 					// For other types, use normal inference
+					// (JSON nulls stay as nulls, rather than becoming "<nil>")
 					rows[i][k] = data.InferValue(v)
 				}
 			}
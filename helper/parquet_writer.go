@@ -0,0 +1,248 @@
+package helper
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/parquet-go/parquet-go"
+)
+
+// ParquetWriter writes query results as an (uncompressed) Apache Parquet file.
+//
+// Rows are written a row group at a time, so a file can be larger than would
+// fit in memory.  Each column's type is worked out from the values in the
+// first row group: whole numbers are INT64, numbers DOUBLE, booleans BOOLEAN,
+// times TIMESTAMP(MICROS) and everything else a UTF-8 string.  Every column
+// can be NULL.
+type ParquetWriter struct {
+	// How many rows go in each row group (parquetRowGroupSize if zero)
+	RowGroupSize int
+}
+
+// The number of rows in each row group, unless ParquetWriter says otherwise
+const parquetRowGroupSize = 65536
+
+// What a column's values are, going by the first row group
+const (
+	parquetKindNone = iota
+	parquetKindBoolean
+	parquetKindInt
+	parquetKindDouble
+	parquetKindTime
+	parquetKindString
+)
+
+// The parquet type for each kind of column (a column of nothing but NULLs is
+// a string)
+var parquetKindNodes = map[int]parquet.Node{
+	parquetKindNone:    parquet.String(),
+	parquetKindBoolean: parquet.Leaf(parquet.BooleanType),
+	parquetKindInt:     parquet.Int(64),
+	parquetKindDouble:  parquet.Leaf(parquet.DoubleType),
+	parquetKindTime:    parquet.Timestamp(parquet.Microsecond),
+	parquetKindString:  parquet.String(),
+}
+
+func (p *ParquetWriter) WriteResults(w io.Writer, columns []string, rows []data.Row, headings bool) error {
+	return writeAllRows(p, w, columns, rows, headings)
+}
+
+// StartResults starts the file, although nothing is written until the first
+// row group is full (or the writer is closed).  The column names are always
+// written, whatever headings says.
+func (p *ParquetWriter) StartResults(w io.Writer, columns []string, headings bool) (RowWriter, error) {
+	size := p.RowGroupSize
+	if size <= 0 {
+		size = parquetRowGroupSize
+	}
+	return &parquetRowWriter{w: w, columns: columns, rowGroupSize: size}, nil
+}
+
+type parquetRowWriter struct {
+	w            io.Writer
+	columns      []string
+	rowGroupSize int
+	// The first row group, until the columns' kinds are known
+	rows []data.Row
+	// The kind of each column, and the file, once the first row group is
+	// written
+	kinds  []int
+	writer *parquet.Writer
+}
+
+func (p *parquetRowWriter) WriteRow(row data.Row) error {
+	if p.writer != nil {
+		return p.writeRow(row)
+	}
+	p.rows = append(p.rows, row)
+	if len(p.rows) < p.rowGroupSize {
+		return nil
+	}
+	return p.writeFirstRowGroup()
+}
+
+func (p *parquetRowWriter) Close() error {
+	if p.writer == nil {
+		if err := p.writeFirstRowGroup(); err != nil {
+			return err
+		}
+	}
+	if err := p.writer.Close(); err != nil {
+		return fmt.Errorf("error writing parquet file: %w", err)
+	}
+	return nil
+}
+
+// writeFirstRowGroup works out the schema from the rows held so far, and
+// then writes them
+func (p *parquetRowWriter) writeFirstRowGroup() error {
+	schema := parquetSchema{Group: parquet.Group{}}
+	p.kinds = make([]int, len(p.columns))
+	for i, column := range p.columns {
+		p.kinds[i] = parquetColumnKind(p.rows, column)
+		node := parquet.Optional(parquetKindNodes[p.kinds[i]])
+		schema.Group[column] = node
+		schema.columns = append(schema.columns, &parquetColumn{Node: node, name: column})
+	}
+	p.writer = parquet.NewWriter(p.w, parquet.NewSchema("gremel", schema), parquet.MaxRowsPerRowGroup(int64(p.rowGroupSize)))
+	rows := p.rows
+	p.rows = nil
+	for _, row := range rows {
+		if err := p.writeRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parquetRowWriter) writeRow(row data.Row) error {
+	values := make(parquet.Row, len(p.columns))
+	for i, column := range p.columns {
+		value, fits := parquetValue(row[column], p.kinds[i])
+		if !fits {
+			return fmt.Errorf("column '%s' is %s, but has %#v in it (after the first row group)", column, parquetKindNodes[p.kinds[i]].Type().Kind(), row[column])
+		}
+		definitionLevel := 1
+		if value.IsNull() {
+			definitionLevel = 0
+		}
+		values[i] = value.Level(0, definitionLevel, i)
+	}
+	if _, err := p.writer.WriteRows([]parquet.Row{values}); err != nil {
+		return fmt.Errorf("error writing parquet row: %w", err)
+	}
+	return nil
+}
+
+// parquetValue is value as the kind of value in its column, and whether it
+// fits in the column at all
+func parquetValue(value any, kind int) (parquet.Value, bool) {
+	if value == nil {
+		return parquet.NullValue(), true
+	}
+	switch kind {
+	case parquetKindBoolean:
+		b, isBool := value.(bool)
+		return parquet.BooleanValue(b), isBool
+	case parquetKindInt:
+		n, isInt := parquetInt(value)
+		return parquet.Int64Value(n), isInt
+	case parquetKindDouble:
+		f, isNumber := parquetFloat(value)
+		return parquet.DoubleValue(f), isNumber
+	case parquetKindTime:
+		t, isTime := value.(time.Time)
+		return parquet.Int64Value(t.UnixMicro()), isTime
+	}
+	return parquet.ByteArrayValue([]byte(resultValue(value))), true
+}
+
+// parquetColumnKind works out what a column holds from its values.  A mix of
+// whole numbers and numbers is DOUBLE, and any other mix is a string.
+func parquetColumnKind(rows []data.Row, column string) int {
+	kind := parquetKindNone
+	for _, row := range rows {
+		valueKind := parquetKindString
+		switch row[column].(type) {
+		case nil:
+			continue
+		case bool:
+			valueKind = parquetKindBoolean
+		case int, int8, int16, int32, int64, uint8, uint16, uint32:
+			valueKind = parquetKindInt
+		case float32, float64:
+			valueKind = parquetKindDouble
+		case time.Time:
+			valueKind = parquetKindTime
+		}
+		switch {
+		case kind == parquetKindNone || kind == valueKind:
+			kind = valueKind
+		case (kind == parquetKindInt || kind == parquetKindDouble) && (valueKind == parquetKindInt || valueKind == parquetKindDouble):
+			kind = parquetKindDouble
+		default:
+			kind = parquetKindString
+		}
+	}
+	return kind
+}
+
+func parquetInt(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func parquetFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	n, isInt := parquetInt(value)
+	return float64(n), isInt
+}
+
+// parquetSchema is the top of the schema: a parquet.Group, but with the
+// columns in the order of the results, rather than in name order
+type parquetSchema struct {
+	parquet.Group
+	columns []parquet.Field
+}
+
+func (s parquetSchema) Fields() []parquet.Field {
+	return s.columns
+}
+
+type parquetColumn struct {
+	parquet.Node
+	name string
+}
+
+func (c *parquetColumn) Name() string {
+	return c.name
+}
+
+func (c *parquetColumn) Value(base reflect.Value) reflect.Value {
+	return base.MapIndex(reflect.ValueOf(c.name))
+}
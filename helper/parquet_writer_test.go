@@ -0,0 +1,110 @@
+package helper
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readParquetRows reads a file back, as one value for each column in each
+// row (with nil for NULL)
+func readParquetRows(t *testing.T, file *parquet.File) [][]any {
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+	var rows [][]any
+	buffer := make([]parquet.Row, 10)
+	for {
+		n, err := reader.ReadRows(buffer)
+		for _, row := range buffer[:n] {
+			var values []any
+			for _, value := range row {
+				switch {
+				case value.IsNull():
+					values = append(values, nil)
+				case value.Kind() == parquet.Boolean:
+					values = append(values, value.Boolean())
+				case value.Kind() == parquet.Int64:
+					values = append(values, value.Int64())
+				case value.Kind() == parquet.Double:
+					values = append(values, value.Double())
+				default:
+					values = append(values, string(value.ByteArray()))
+				}
+			}
+			rows = append(rows, values)
+		}
+		if errors.Is(err, io.EOF) {
+			return rows
+		}
+		require.NoError(t, err)
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	when := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	columns := []string{"id", "name", "score", "active", "seen", "empty"}
+	rows := []data.Row{
+		{"id": int64(1), "name": "Alice", "score": int64(10), "active": true, "seen": when},
+		{"id": int64(2), "name": nil, "score": 7.5, "active": false, "seen": nil},
+		{"id": int64(3), "name": "Bob", "score": int64(3), "active": true, "seen": when.Add(time.Hour)},
+	}
+	var b bytes.Buffer
+	// Two row groups
+	require.NoError(t, (&ParquetWriter{RowGroupSize: 2}).WriteResults(&b, columns, rows, false))
+	file, err := parquet.OpenFile(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), file.NumRows())
+	assert.Len(t, file.RowGroups(), 2)
+
+	// The columns are in the order they were given
+	fields := file.Schema().Fields()
+	require.Len(t, fields, len(columns))
+	for i, expected := range []struct {
+		kind      parquet.Kind
+		timestamp bool
+		utf8      bool
+	}{
+		{parquet.Int64, false, false},
+		{parquet.ByteArray, false, true},
+		{parquet.Double, false, false},
+		{parquet.Boolean, false, false},
+		{parquet.Int64, true, false},
+		{parquet.ByteArray, false, true},
+	} {
+		assert.Equal(t, columns[i], fields[i].Name())
+		assert.True(t, fields[i].Optional(), columns[i])
+		assert.Equal(t, expected.kind, fields[i].Type().Kind(), columns[i])
+		logicalType := fields[i].Type().LogicalType()
+		assert.Equal(t, expected.timestamp, logicalType != nil && logicalType.Timestamp != nil, columns[i])
+		assert.Equal(t, expected.utf8, logicalType != nil && logicalType.UTF8 != nil, columns[i])
+	}
+
+	assert.Equal(t, [][]any{
+		{int64(1), "Alice", 10.0, true, when.UnixMicro(), nil},
+		{int64(2), nil, 7.5, false, nil, nil},
+		{int64(3), "Bob", 3.0, true, when.Add(time.Hour).UnixMicro(), nil},
+	}, readParquetRows(t, file))
+}
+
+func TestParquetWriterNoRows(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, (&ParquetWriter{}).WriteResults(&b, []string{"a", "b"}, nil, true))
+	file, err := parquet.OpenFile(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), file.NumRows())
+	assert.Len(t, file.Schema().Fields(), 2)
+}
+
+func TestParquetWriterTypeChangesAfterFirstRowGroup(t *testing.T) {
+	var b bytes.Buffer
+	rows := []data.Row{{"n": int64(1)}, {"n": "two"}}
+	err := (&ParquetWriter{RowGroupSize: 1}).WriteResults(&b, []string{"n"}, rows, true)
+	assert.ErrorContains(t, err, "column 'n' is INT64")
+}
@@ -0,0 +1,424 @@
+package helper
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/jbirtley88/gremel/data"
+)
+
+// ResultWriter writes the rows returned by a query, e.g. as CSV or JSON.
+//
+// columns is the order of the columns in each row.  Formats which have a
+// heading line only write it if headings is true.
+type ResultWriter interface {
+	WriteResults(w io.Writer, columns []string, rows []data.Row, headings bool) error
+}
+
+// RowWriter writes query results one row at a time.  Close must be called
+// once every row has been written.
+type RowWriter interface {
+	WriteRow(row data.Row) error
+	Close() error
+}
+
+// ResultStreamer is implemented by ResultWriters which can write rows as they
+// arrive, rather than needing all of them up front (e.g. to line columns up)
+type ResultStreamer interface {
+	StartResults(w io.Writer, columns []string, headings bool) (RowWriter, error)
+}
+
+// StartResults returns a RowWriter which writes to w with writer.  Writers
+// which can't stream have the rows collected, and written on Close.
+func StartResults(writer ResultWriter, w io.Writer, columns []string, headings bool) (RowWriter, error) {
+	if streamer, isStreamer := writer.(ResultStreamer); isStreamer {
+		return streamer.StartResults(w, columns, headings)
+	}
+	return &collectingRowWriter{writer: writer, w: w, columns: columns, headings: headings}, nil
+}
+
+type collectingRowWriter struct {
+	writer   ResultWriter
+	w        io.Writer
+	columns  []string
+	headings bool
+	rows     []data.Row
+}
+
+func (c *collectingRowWriter) WriteRow(row data.Row) error {
+	c.rows = append(c.rows, row)
+	return nil
+}
+
+func (c *collectingRowWriter) Close() error {
+	return c.writer.WriteResults(c.w, c.columns, c.rows, c.headings)
+}
+
+// writeAllRows writes rows with a RowWriter from StartResults
+func writeAllRows(streamer ResultStreamer, w io.Writer, columns []string, rows []data.Row, headings bool) error {
+	rowWriter, err := streamer.StartResults(w, columns, headings)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := rowWriter.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return rowWriter.Close()
+}
+
+// ResultWriterFunc adapts a plain function to a ResultWriter
+type ResultWriterFunc func(w io.Writer, columns []string, rows []data.Row, headings bool) error
+
+func (f ResultWriterFunc) WriteResults(w io.Writer, columns []string, rows []data.Row, headings bool) error {
+	return f(w, columns, rows, headings)
+}
+
+// The result writers which come with Gremel
+const (
+	ResultFormatTable    = "table"
+	ResultFormatBox      = "box"
+	ResultFormatLine     = "line"
+	ResultFormatCSV      = "csv"
+	ResultFormatTSV      = "tsv"
+	ResultFormatJSON     = "json"
+	ResultFormatJSONL    = "jsonl"
+	ResultFormatMarkdown = "markdown"
+	ResultFormatHTML     = "html"
+)
+
+var (
+	resultWritersMutex sync.RWMutex
+	resultWriters      = map[string]ResultWriter{
+		ResultFormatTable:    ResultWriterFunc(writeAlignedTable),
+		ResultFormatBox:      ResultWriterFunc(writeBoxTable),
+		ResultFormatLine:     ResultWriterFunc(writeLines),
+		ResultFormatCSV:      &delimitedWriter{delimiter: ','},
+		ResultFormatTSV:      &delimitedWriter{delimiter: '\t'},
+		ResultFormatJSON:     &jsonWriter{},
+		ResultFormatJSONL:    &jsonWriter{lines: true},
+		ResultFormatMarkdown: ResultWriterFunc(writeMarkdown),
+		ResultFormatHTML:     ResultWriterFunc(writeHTML),
+	}
+)
+
+// RegisterResultWriter makes a ResultWriter available by name (e.g. for
+// '.mode NAME' and '--format NAME'), replacing any with the same name
+func RegisterResultWriter(name string, writer ResultWriter) {
+	resultWritersMutex.Lock()
+	defer resultWritersMutex.Unlock()
+	resultWriters[strings.ToLower(name)] = writer
+}
+
+// GetResultWriter returns the ResultWriter registered as name
+func GetResultWriter(name string) (ResultWriter, error) {
+	resultWritersMutex.RLock()
+	defer resultWritersMutex.RUnlock()
+	if writer, exists := resultWriters[strings.ToLower(name)]; exists {
+		return writer, nil
+	}
+	return nil, fmt.Errorf("GetResultWriter(%s): unknown format (must be one of %s)", name, strings.Join(resultWriterNames(), ", "))
+}
+
+// ResultWriterNames returns the names of the registered result writers, in order
+func ResultWriterNames() []string {
+	resultWritersMutex.RLock()
+	defer resultWritersMutex.RUnlock()
+	return resultWriterNames()
+}
+
+func resultWriterNames() []string {
+	names := make([]string, 0, len(resultWriters))
+	for name := range resultWriters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resultValue is how a value is shown in the text formats.  NULL is empty.
+func resultValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	default:
+		return formatValue(v)
+	}
+}
+
+// writeAlignedTable lines the columns up, with a line of hyphens under the headings
+func writeAlignedTable(w io.Writer, columns []string, rows []data.Row, headings bool) error {
+	tw := tabwriter.NewWriter(w, 1, 1, 4, ' ', 0)
+	if headings && len(columns) > 0 {
+		for _, column := range columns {
+			fmt.Fprint(tw, column+"\t")
+		}
+		fmt.Fprintln(tw)
+		for _, column := range columns {
+			fmt.Fprint(tw, strings.Repeat("-", len(column))+"\t")
+		}
+		fmt.Fprintln(tw)
+	}
+	for _, row := range rows {
+		for _, column := range columns {
+			// A tab or newline in a value would throw the alignment out
+			fmt.Fprint(tw, strings.Join(strings.Fields(resultValue(row[column])), " ")+"\t")
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// writeBoxTable draws a table with box-drawing characters
+func writeBoxTable(w io.Writer, columns []string, rows []data.Row, headings bool) error {
+	if len(columns) == 0 {
+		return nil
+	}
+	cells := make([][]string, 0, len(rows))
+	widths := make([]int, len(columns))
+	if headings {
+		for i, column := range columns {
+			widths[i] = utf8.RuneCountInString(column)
+		}
+	}
+	for _, row := range rows {
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			values[i] = strings.Join(strings.Fields(resultValue(row[column])), " ")
+			widths[i] = max(widths[i], utf8.RuneCountInString(values[i]))
+		}
+		cells = append(cells, values)
+	}
+
+	var b strings.Builder
+	rule := func(left, middle, right string) {
+		b.WriteString(left)
+		for i, width := range widths {
+			if i > 0 {
+				b.WriteString(middle)
+			}
+			b.WriteString(strings.Repeat("─", width+2))
+		}
+		b.WriteString(right + "\n")
+	}
+	line := func(values []string) {
+		for i, value := range values {
+			b.WriteString("│ " + value + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(value)) + " ")
+		}
+		b.WriteString("│\n")
+	}
+
+	rule("┌", "┬", "┐")
+	if headings {
+		line(columns)
+		rule("├", "┼", "┤")
+	}
+	for _, values := range cells {
+		line(values)
+	}
+	rule("└", "┴", "┘")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeLines writes one 'column = value' line per column, with a blank line between rows
+func writeLines(w io.Writer, columns []string, rows []data.Row, headings bool) error {
+	width := 0
+	for _, column := range columns {
+		width = max(width, utf8.RuneCountInString(column))
+	}
+	var b strings.Builder
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		for _, column := range columns {
+			fmt.Fprintf(&b, "%*s = %s\n", width, column, resultValue(row[column]))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// delimitedWriter writes CSV (or TSV), quoting any value which has the
+// delimiter, a quote, a newline or leading space in it
+type delimitedWriter struct {
+	delimiter rune
+}
+
+func (d *delimitedWriter) WriteResults(w io.Writer, columns []string, rows []data.Row, headings bool) error {
+	return writeAllRows(d, w, columns, rows, headings)
+}
+
+func (d *delimitedWriter) StartResults(w io.Writer, columns []string, headings bool) (RowWriter, error) {
+	cw := csv.NewWriter(w)
+	cw.Comma = d.delimiter
+	if headings {
+		if err := cw.Write(columns); err != nil {
+			return nil, fmt.Errorf("error writing headings: %w", err)
+		}
+	}
+	return &delimitedRowWriter{csv: cw, columns: columns, record: make([]string, len(columns))}, nil
+}
+
+type delimitedRowWriter struct {
+	csv     *csv.Writer
+	columns []string
+	record  []string
+}
+
+func (d *delimitedRowWriter) WriteRow(row data.Row) error {
+	for i, column := range d.columns {
+		d.record[i] = resultValue(row[column])
+	}
+	if err := d.csv.Write(d.record); err != nil {
+		return fmt.Errorf("error writing row: %w", err)
+	}
+	return nil
+}
+
+func (d *delimitedRowWriter) Close() error {
+	d.csv.Flush()
+	return d.csv.Error()
+}
+
+// jsonWriter writes a JSON array of objects (or, for JSON Lines, one object
+// per line), with the keys in column order
+type jsonWriter struct {
+	lines bool
+}
+
+func (j *jsonWriter) WriteResults(w io.Writer, columns []string, rows []data.Row, headings bool) error {
+	return writeAllRows(j, w, columns, rows, headings)
+}
+
+func (j *jsonWriter) StartResults(w io.Writer, columns []string, headings bool) (RowWriter, error) {
+	// The keys are the same for every row
+	keys := make([][]byte, len(columns))
+	for i, column := range columns {
+		keys[i], _ = json.Marshal(column)
+	}
+	jw := &jsonRowWriter{w: bufio.NewWriter(w), lines: j.lines, columns: columns, keys: keys}
+	if !j.lines {
+		jw.w.WriteString("[")
+	}
+	return jw, nil
+}
+
+type jsonRowWriter struct {
+	w       *bufio.Writer
+	lines   bool
+	columns []string
+	keys    [][]byte
+	rows    int
+}
+
+func (j *jsonRowWriter) WriteRow(row data.Row) error {
+	switch {
+	case j.lines:
+	case j.rows == 0:
+		j.w.WriteString("\n")
+	default:
+		j.w.WriteString(",\n")
+	}
+	j.rows++
+	j.w.WriteString("{")
+	for i, column := range j.columns {
+		if i > 0 {
+			j.w.WriteString(",")
+		}
+		value, err := json.Marshal(jsonValue(row[column]))
+		if err != nil {
+			return fmt.Errorf("error encoding JSON: %w", err)
+		}
+		j.w.Write(j.keys[i])
+		j.w.WriteString(":")
+		j.w.Write(value)
+	}
+	j.w.WriteString("}")
+	if j.lines {
+		j.w.WriteString("\n")
+	}
+	return nil
+}
+
+func (j *jsonRowWriter) Close() error {
+	if !j.lines {
+		j.w.WriteString("\n]\n")
+	}
+	return j.w.Flush()
+}
+
+func jsonValue(value any) any {
+	if v, isBytes := value.([]byte); isBytes {
+		return string(v)
+	}
+	return value
+}
+
+// writeMarkdown writes a GitHub-flavoured Markdown table
+func writeMarkdown(w io.Writer, columns []string, rows []data.Row, headings bool) error {
+	if len(columns) == 0 {
+		return nil
+	}
+	escape := strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+	var b strings.Builder
+	line := func(values []string) {
+		b.WriteString("|")
+		for _, value := range values {
+			b.WriteString(" " + escape.Replace(value) + " |")
+		}
+		b.WriteString("\n")
+	}
+	// Markdown tables have to have a heading line, even if it's empty
+	if headings {
+		line(columns)
+	} else {
+		line(make([]string, len(columns)))
+	}
+	b.WriteString(strings.Repeat("|---", len(columns)) + "|\n")
+	values := make([]string, len(columns))
+	for _, row := range rows {
+		for i, column := range columns {
+			values[i] = resultValue(row[column])
+		}
+		line(values)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeHTML writes an HTML <table>
+func writeHTML(w io.Writer, columns []string, rows []data.Row, headings bool) error {
+	var b strings.Builder
+	b.WriteString("<table>\n")
+	if headings {
+		b.WriteString("<tr>")
+		for _, column := range columns {
+			b.WriteString("<th>" + html.EscapeString(column) + "</th>")
+		}
+		b.WriteString("</tr>\n")
+	}
+	for _, row := range rows {
+		b.WriteString("<tr>")
+		for _, column := range columns {
+			b.WriteString("<td>" + html.EscapeString(resultValue(row[column])) + "</td>")
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
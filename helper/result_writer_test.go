@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	resultColumns = []string{"name", "id", "note"}
+	resultRows    = []data.Row{
+		{"id": int64(1), "name": "Smith, John", "note": "  two\nlines"},
+		{"id": int64(2), "name": "O'Brien", "note": nil},
+	}
+)
+
+func writeResultsAs(t *testing.T, format string, headings bool) string {
+	writer, err := GetResultWriter(format)
+	require.NoError(t, err)
+	var buffer bytes.Buffer
+	require.NoError(t, writer.WriteResults(&buffer, resultColumns, resultRows, headings))
+	return buffer.String()
+}
+
+func TestResultWriters(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{ResultFormatCSV, "name,id,note\n\"Smith, John\",1,\"  two\nlines\"\nO'Brien,2,\n"},
+		{ResultFormatTSV, "name\tid\tnote\nSmith, John\t1\t\"  two\nlines\"\nO'Brien\t2\t\n"},
+		{ResultFormatJSON, "[\n{\"name\":\"Smith, John\",\"id\":1,\"note\":\"  two\\nlines\"},\n{\"name\":\"O'Brien\",\"id\":2,\"note\":null}\n]\n"},
+		{ResultFormatJSONL, "{\"name\":\"Smith, John\",\"id\":1,\"note\":\"  two\\nlines\"}\n{\"name\":\"O'Brien\",\"id\":2,\"note\":null}\n"},
+		{ResultFormatMarkdown, "| name | id | note |\n|---|---|---|\n| Smith, John | 1 |   two<br>lines |\n| O'Brien | 2 |  |\n"},
+		{ResultFormatHTML, "<table>\n<tr><th>name</th><th>id</th><th>note</th></tr>\n<tr><td>Smith, John</td><td>1</td><td>  two\nlines</td></tr>\n<tr><td>O&#39;Brien</td><td>2</td><td></td></tr>\n</table>\n"},
+		{ResultFormatTable, "name           id    note         \n----           --    ----         \nSmith, John    1     two lines    \nO'Brien        2                  \n"},
+		{ResultFormatBox, "┌─────────────┬────┬───────────┐\n│ name        │ id │ note      │\n├─────────────┼────┼───────────┤\n│ Smith, John │ 1  │ two lines │\n│ O'Brien     │ 2  │           │\n└─────────────┴────┴───────────┘\n"},
+		{ResultFormatLine, "name = Smith, John\n  id = 1\nnote =   two\nlines\n\nname = O'Brien\n  id = 2\nnote = \n"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, writeResultsAs(t, test.format, true), test.format)
+	}
+}
+
+func TestResultWritersWithoutHeadings(t *testing.T) {
+	assert.Equal(t, "\"Smith, John\",1,\"  two\nlines\"\nO'Brien,2,\n", writeResultsAs(t, "CSV", false))
+	assert.Equal(t, "|  |  |  |\n|---|---|---|\n| Smith, John | 1 |   two<br>lines |\n| O'Brien | 2 |  |\n", writeResultsAs(t, ResultFormatMarkdown, false))
+}
+
+func TestRegisterResultWriter(t *testing.T) {
+	_, err := GetResultWriter("count")
+	assert.Error(t, err)
+
+	RegisterResultWriter("count", ResultWriterFunc(func(w io.Writer, columns []string, rows []data.Row, headings bool) error {
+		_, err := w.Write([]byte{byte('0' + len(rows))})
+		return err
+	}))
+	assert.Equal(t, "2", writeResultsAs(t, "count", true))
+	assert.Contains(t, ResultWriterNames(), "count")
+}
+
+func TestCSVOutputQuoting(t *testing.T) {
+	var buffer bytes.Buffer
+	rows := []map[string]any{{"a": " leading", "b": "new\nline"}}
+	require.NoError(t, CSVOutput(rows, []string{"a", "b"}, &buffer))
+	assert.Equal(t, "a,b\n\" leading\",\"new\nline\"\n", buffer.String())
+}
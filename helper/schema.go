@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"time"
 
 	"github.com/jbirtley88/gremel/data"
 )
@@ -16,6 +17,10 @@ func DeriveSchema(rows []data.Row) (map[string]reflect.Kind, error) {
 	schema := make(map[string]reflect.Kind)
 	for _, row := range rows {
 		for fieldName, fieldValue := range row {
+			// A null tells us nothing about the type of the column
+			if fieldValue == nil {
+				continue
+			}
 			columnType, err := GetColumnType(fieldValue)
 			if err != nil {
 				return nil, fmt.Errorf("DeriveSchema: failed to get column type for field %q: %w", fieldName, err)
@@ -69,6 +74,9 @@ func GetColumnType(value any) (reflect.Kind, error) {
 		return reflect.Bool, nil
 	case string:
 		return reflect.String, nil
+	case time.Time:
+		// Timestamps are the only structs we know how to store
+		return reflect.Struct, nil
 	default:
 		return reflect.Invalid, fmt.Errorf("unsupported data type: %T", value)
 	}
@@ -11,50 +11,169 @@ import (
 func ProcessNextSQLLine(ctx data.GremelContext, line string, sqlBuffer *[]string) (string, error) {
 	// Sanitise the SQL input here to prevent SQL injection
 	// cf. Bobby Tables: https://xkcd.com/327/
-	// Remove SQL comments (-- comments outside of quotes)
-	line = RemoveComments(line)
+	// Block comments can span lines, so the buffer holds the lines as they
+	// were typed and the comments are removed from all of them together
+	pending := strings.Join(append(append([]string{}, *sqlBuffer...), line), "\n")
+	stripped, inComment := stripComments(pending)
+	if strings.TrimSpace(stripped) == "" && !inComment {
+		*sqlBuffer = []string{}
+		return "", nil // Nothing but comments
+	}
 
-	// Check if this looks like a SQL statement (must start with SELECT for now)
-	trimmedLine := strings.TrimSpace(line)
-	if trimmedLine == "" {
-		return "", nil // Empty line after comment removal
+	// Everything after the first semicolon is ignored
+	statement := stripped
+	semicolonIndex := FindSemicolonOutsideQuotes(stripped)
+	if semicolonIndex != -1 {
+		statement = stripped[:semicolonIndex]
 	}
 
-	// If buffer is empty, check if this starts a valid SQL statement
-	if len(*sqlBuffer) == 0 {
-		firstWord := strings.ToUpper(strings.Split(trimmedLine, " ")[0])
-		if firstWord != "SELECT" {
-			return "", fmt.Errorf("only SELECT statements are supported currently")
-		}
+	// Check it is read-only as soon as we can tell what it is
+	if verb, readOnly := ClassifySQL(statement); verb != "" && !readOnly {
+		*sqlBuffer = []string{}
+		return "", fmt.Errorf("only read-only statements (SELECT, WITH, EXPLAIN, VALUES or PRAGMA table_info) are supported, not %s", verb)
 	}
 
-	// If line contains semicolon, split at the first semicolon
-	semicolonIndex := FindSemicolonOutsideQuotes(line)
-	if semicolonIndex != -1 {
-		// Add everything up to the semicolon to buffer
-		*sqlBuffer = append(*sqlBuffer, line[:semicolonIndex])
+	if semicolonIndex == -1 {
+		// No semicolon found, add to buffer
+		*sqlBuffer = append(*sqlBuffer, line)
+		return "", nil
+	}
 
-		// Execute the complete SQL statement
-		completeSQL := strings.Join(*sqlBuffer, " ")
+	// Clear the buffer, and execute the complete SQL statement
+	*sqlBuffer = []string{}
+	return strings.TrimSpace(strings.ReplaceAll(statement, "\n", " ")), nil
+}
 
-		// Clear the buffer
-		*sqlBuffer = []string{}
-		return completeSQL, nil
+// ClassifySQL works out what a (possibly incomplete) statement does from its
+// leading keywords, e.g. "SELECT" for 'WITH recent AS (...) SELECT ...' or
+// "DELETE" for 'EXPLAIN DELETE FROM ...'.  Comments and leading parentheses
+// are skipped.  The verb is "" until there is enough of the statement to
+// tell, and readOnly is true for SELECT, VALUES and 'PRAGMA table_info' (the
+// only pragma a query is allowed).
+func ClassifySQL(sql string) (verb string, readOnly bool) {
+	sql, _ = stripComments(sql)
+	tokens := sqlTokens(sql)
+
+	// e.g. '(SELECT ...)'
+	depth := 0
+	for len(tokens) > 0 && tokens[0] == "(" {
+		depth++
+		tokens = tokens[1:]
+	}
+
+	for len(tokens) > 0 {
+		switch tokens[0] {
+		case "EXPLAIN":
+			// 'EXPLAIN [QUERY PLAN] statement' describes the statement
+			tokens = tokens[1:]
+			if len(tokens) > 0 && tokens[0] == "QUERY" {
+				if len(tokens) < 2 {
+					return "", false
+				}
+				tokens = tokens[2:]
+			}
+		case "WITH":
+			// The statement is the first verb after the common table
+			// expressions, which are all in parentheses
+			level := depth
+			for _, token := range tokens[1:] {
+				switch {
+				case token == "(":
+					level++
+				case token == ")":
+					level--
+				case level == depth && statementVerbs[token]:
+					return token, token == "SELECT" || token == "VALUES"
+				}
+			}
+			return "", false
+		case "(":
+			depth++
+			tokens = tokens[1:]
+		case "PRAGMA":
+			// e.g. 'PRAGMA table_info(people)' or 'PRAGMA main.table_info(people)'
+			for _, token := range tokens[1:] {
+				if token == "(" {
+					break
+				}
+				if token != "MAIN" && token != "TEMP" {
+					return "PRAGMA", token == "TABLE_INFO"
+				}
+			}
+			return "", false
+		default:
+			return tokens[0], tokens[0] == "SELECT" || tokens[0] == "VALUES"
+		}
 	}
+	return "", false
+}
+
+// The verbs which can follow 'WITH ...'
+var statementVerbs = map[string]bool{
+	"SELECT":  true,
+	"VALUES":  true,
+	"INSERT":  true,
+	"REPLACE": true,
+	"UPDATE":  true,
+	"DELETE":  true,
+}
+
+// sqlTokens splits (comment-free) SQL into upper-cased words and parentheses,
+// leaving out quoted strings and identifiers, numbers and other punctuation
+func sqlTokens(sql string) []string {
+	var tokens []string
+	for i := 0; i < len(sql); i++ {
+		char := sql[i]
+		switch {
+		case char == '(' || char == ')':
+			tokens = append(tokens, string(char))
+		case char == '\'' || char == '"' || char == '`' || char == '[':
+			closing := char
+			if char == '[' {
+				closing = ']'
+			}
+			end := strings.IndexByte(sql[i+1:], closing)
+			if end == -1 {
+				return tokens
+			}
+			i += end + 1
+		case isWordStart(char):
+			start := i
+			for i+1 < len(sql) && (isWordStart(sql[i+1]) || (sql[i+1] >= '0' && sql[i+1] <= '9') || sql[i+1] == '$') {
+				i++
+			}
+			tokens = append(tokens, strings.ToUpper(sql[start:i+1]))
+		case char >= '0' && char <= '9':
+			// Skip the rest of the number, e.g. '1e10'
+			for i+1 < len(sql) && (isWordStart(sql[i+1]) || (sql[i+1] >= '0' && sql[i+1] <= '9') || sql[i+1] == '.') {
+				i++
+			}
+		}
+	}
+	return tokens
+}
 
-	// No semicolon found, add to buffer
-	*sqlBuffer = append(*sqlBuffer, line)
-	return "", nil
+func isWordStart(char byte) bool {
+	return char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || char >= 0x80
 }
 
-// RemoveComments removes SQL comments (--) that are outside of quotes
+// RemoveComments removes SQL comments (both '-- to the end of the line' and
+// '/* block */') that are outside of quotes
 func RemoveComments(line string) string {
+	stripped, _ := stripComments(line)
+	return stripped
+}
+
+// stripComments replaces each comment outside of quotes with a space (so that
+// 'a/**/b' stays two words).  inComment is true if sql ends part way through
+// a block comment.
+func stripComments(sql string) (stripped string, inComment bool) {
 	var result strings.Builder
 	inSingleQuote := false
 	inDoubleQuote := false
 
-	for i := 0; i < len(line); i++ {
-		char := line[i]
+	for i := 0; i < len(sql); i++ {
+		char := sql[i]
 
 		// Handle quote tracking
 		if char == '\'' && !inDoubleQuote {
@@ -63,16 +182,30 @@ func RemoveComments(line string) string {
 			inDoubleQuote = !inDoubleQuote
 		}
 
-		// Check for comment start
-		if !inSingleQuote && !inDoubleQuote && char == '-' && i+1 < len(line) && line[i+1] == '-' {
+		if !inSingleQuote && !inDoubleQuote && char == '-' && i+1 < len(sql) && sql[i+1] == '-' {
 			// Found comment outside quotes, ignore rest of line
-			break
+			end := strings.IndexByte(sql[i:], '\n')
+			if end == -1 {
+				break
+			}
+			result.WriteByte(' ')
+			i += end - 1
+			continue
+		}
+		if !inSingleQuote && !inDoubleQuote && char == '/' && i+1 < len(sql) && sql[i+1] == '*' {
+			end := strings.Index(sql[i+2:], "*/")
+			if end == -1 {
+				return result.String(), true
+			}
+			result.WriteByte(' ')
+			i += end + 3
+			continue
 		}
 
 		result.WriteByte(char)
 	}
 
-	return result.String()
+	return result.String(), false
 }
 
 // FindSemicolonOutsideQuotes finds the first semicolon outside of quotes
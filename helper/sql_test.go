@@ -0,0 +1,120 @@
+package helper
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jbirtley88/gremel/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifySQL(t *testing.T) {
+	tests := []struct {
+		sql      string
+		verb     string
+		readOnly bool
+	}{
+		{"SELECT * FROM people", "SELECT", true},
+		{"  select 1", "SELECT", true},
+		{"VALUES (1, 'a'), (2, 'b')", "VALUES", true},
+		{"WITH recent AS (SELECT * FROM access WHERE status >= 500) SELECT path FROM recent", "SELECT", true},
+		{"WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n) SELECT x FROM n", "SELECT", true},
+		{"WITH a AS (SELECT 1), b AS MATERIALIZED (SELECT 2) VALUES (3)", "VALUES", true},
+		{"WITH doomed AS (SELECT id FROM people) DELETE FROM people WHERE id IN doomed", "DELETE", false},
+		{"EXPLAIN QUERY PLAN SELECT * FROM people", "SELECT", true},
+		{"explain WITH x AS (SELECT 1) SELECT * FROM x", "SELECT", true},
+		{"EXPLAIN DROP TABLE people", "DROP", false},
+		{"(SELECT 1)", "SELECT", true},
+		{"-- the people\nSELECT 1", "SELECT", true},
+		{"/* DROP TABLE people */ SELECT 1", "SELECT", true},
+		{"/* multi\nline */ (/**/ VALUES (1))", "VALUES", true},
+		{"DROP TABLE people", "DROP", false},
+		{"INSERT INTO people VALUES (1)", "INSERT", false},
+		{"PRAGMA journal_mode = off", "PRAGMA", false},
+		{"PRAGMA database_list", "PRAGMA", false},
+		{"pragma table_info(people)", "PRAGMA", true},
+		{"PRAGMA main.table_info(people)", "PRAGMA", true},
+		{"SELECT name FROM pragma_table_info('people')", "SELECT", true},
+		{"ATTACH 'other.db' AS other", "ATTACH", false},
+		// Not enough to tell yet
+		{"", "", false},
+		{"-- just a comment", "", false},
+		{"/* SELECT", "", false},
+		{"EXPLAIN QUERY", "", false},
+		{"PRAGMA", "", false},
+		{"WITH recent AS (SELECT * FROM access", "", false},
+		{`WITH "select" AS (SELECT 1)`, "", false},
+	}
+	for _, test := range tests {
+		verb, readOnly := ClassifySQL(test.sql)
+		assert.Equal(t, test.verb, verb, test.sql)
+		assert.Equal(t, test.readOnly, readOnly, test.sql)
+	}
+}
+
+func TestRemoveComments(t *testing.T) {
+	assert.Equal(t, "SELECT 1 ", RemoveComments("SELECT 1 -- one"))
+	assert.Equal(t, "SELECT   1", RemoveComments("SELECT /* one */ 1"))
+	assert.Equal(t, "SELECT '-- /* not a comment */'", RemoveComments("SELECT '-- /* not a comment */'"))
+	assert.Equal(t, `SELECT "a--b" `, RemoveComments(`SELECT "a--b" /* unterminated`))
+}
+
+func TestProcessNextSQLLine(t *testing.T) {
+	ctx := data.NewGremelContext(context.Background())
+	process := func(lines ...string) (string, []string, error) {
+		var buffer []string
+		var statement string
+		var err error
+		for _, line := range lines {
+			statement, err = ProcessNextSQLLine(ctx, line, &buffer)
+			if err != nil {
+				break
+			}
+		}
+		return statement, buffer, err
+	}
+
+	statement, buffer, err := process("SELECT id;")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT id", statement)
+	assert.Empty(t, buffer)
+
+	statement, _, err = process("WITH recent AS (", "  SELECT * FROM access -- the recent ones", ")", "SELECT path FROM recent; -- done")
+	require.NoError(t, err)
+	assert.Equal(t, "WITH recent AS (   SELECT * FROM access   ) SELECT path FROM recent", statement)
+
+	statement, _, err = process("/* a comment which", "goes on; for a while */", "EXPLAIN QUERY PLAN SELECT 1;")
+	require.NoError(t, err)
+	assert.Equal(t, "EXPLAIN QUERY PLAN SELECT 1", statement)
+
+	statement, _, err = process("VALUES (1, ';');")
+	require.NoError(t, err)
+	assert.Equal(t, "VALUES (1, ';')", statement)
+
+	// Still going
+	statement, buffer, err = process("WITH recent AS (", "SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, "", statement)
+	assert.Len(t, buffer, 2)
+	_, buffer, err = process("/* nothing yet")
+	require.NoError(t, err)
+	assert.Len(t, buffer, 1)
+
+	// Nothing but comments
+	statement, buffer, err = process("-- nothing to see here", "/* or here */")
+	require.NoError(t, err)
+	assert.Equal(t, "", statement)
+	assert.Empty(t, buffer)
+
+	// Rejected as soon as it's clear what they are
+	for _, lines := range [][]string{
+		{"DROP TABLE people"},
+		{"/* harmless */ DELETE FROM people;"},
+		{"WITH doomed AS (SELECT 1)", "DELETE FROM people;"},
+	} {
+		_, buffer, err = process(lines...)
+		assert.ErrorContains(t, err, "only read-only statements", lines)
+		assert.Empty(t, buffer, lines)
+	}
+}
@@ -0,0 +1,59 @@
+package helper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SplitWords splits a command line (e.g. '.mount procs exec:"ps -eo pid,comm"')
+// into words on whitespace, much as a shell would: single quotes keep
+// everything up to the closing quote, and double quotes do the same except
+// that '\"' and '\\' are escapes.  The quotes themselves are removed.  Any
+// other backslash is just a backslash, so that paths like C:\data\x.csv don't
+// need quoting.
+func SplitWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+				i++
+				word.WriteRune(runes[i])
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("SplitWords(%s): unterminated %c quote", line, quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
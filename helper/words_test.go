@@ -0,0 +1,42 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{".tables", []string{".tables"}},
+		{"  .mount   people  people.csv ", []string{".mount", "people", "people.csv"}},
+		{`.mount procs exec:'ps -eo pid,comm --no-headers' format=whitespace`, []string{".mount", "procs", "exec:ps -eo pid,comm --no-headers", "format=whitespace"}},
+		{`.mount logs exec:"grep 'GET /' access.log"`, []string{".mount", "logs", "exec:grep 'GET /' access.log"}},
+		{`.mount x "a \"quoted\" \\ word"`, []string{".mount", "x", `a "quoted" \ word`}},
+		// A backslash outside quotes is just a backslash
+		{`.mount u /tmp/we\ird.csv`, []string{".mount", "u", `/tmp/we\ird.csv`}},
+		{`.mount x C:\data\x.csv`, []string{".mount", "x", `C:\data\x.csv`}},
+		{`.mount x \`, []string{".mount", "x", `\`}},
+		{`.mount x 'my file.csv'`, []string{".mount", "x", "my file.csv"}},
+		// ... as it is in double quotes, unless it's before '"' or '\'
+		{`.mount x "C:\data\new folder\x.csv"`, []string{".mount", "x", `C:\data\new folder\x.csv`}},
+		{`.mount x "a\nb\\c\"d"`, []string{".mount", "x", `a\nb\c"d`}},
+		{`.mount x 'it\'`, []string{".mount", "x", `it\`}},
+		{`.mount x '' y`, []string{".mount", "x", "", "y"}},
+		{"", nil},
+	}
+	for _, test := range tests {
+		words, err := SplitWords(test.line)
+		require.Nil(t, err, test.line)
+		assert.Equal(t, test.expected, words, test.line)
+	}
+
+	_, err := SplitWords(`.mount x exec:'ps -ef`)
+	assert.NotNil(t, err)
+	_, err = SplitWords(`.mount x "C:\data\"`)
+	assert.NotNil(t, err)
+}
@@ -22,6 +22,21 @@ func (lf LogFormat) String() string {
 	}
 }
 
+// Columns returns the columns of the rows parsed from lines in the format, in
+// the order they appear in a line
+func (lf LogFormat) Columns() []string {
+	switch lf {
+	case LogCLF:
+		return []string{"host", "ident", "authuser", "time", "method", "path", "proto", "status", "size", "latency"}
+	case LogCombined:
+		return []string{"host", "ident", "user", "time", "request", "status", "size", "referer", "useragent", "latency"}
+	case LogSyslog:
+		return []string{"timestamp", "host", "process", "pid", "message", "raw"}
+	default:
+		return nil
+	}
+}
+
 func DetectLogFormat(line string) LogFormat {
 	if _, err := ParseCLFLine(line); err == nil {
 		return LogCLF
@@ -7,14 +7,21 @@ import (
 )
 
 func ParseLine(line string) (data.Row, error) {
+	row, _, err := ParseLineFormat(line)
+	return row, err
+}
+
+// ParseLineFormat parses a line in any of the formats we know, and says which
+// one it was
+func ParseLineFormat(line string) (data.Row, LogFormat, error) {
 	if row, err := ParseCLFLine(line); err == nil {
-		return row, nil
+		return row, LogCLF, nil
 	}
 	if row, err := ParseCombinedLogLine(line); err == nil {
-		return row, nil
+		return row, LogCombined, nil
 	}
 	if row, err := ParseSyslogLine(line); err == nil {
-		return row, nil
+		return row, LogSyslog, nil
 	}
-	return nil, fmt.Errorf("ParseLine: unrecognised log format")
+	return nil, LogUnknown, fmt.Errorf("ParseLine: unrecognised log format")
 }
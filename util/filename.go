@@ -6,6 +6,11 @@ import (
 	"strings"
 )
 
+var (
+	ErrEmptyFilename = errors.New("filename cannot be empty")
+	ErrNoExtension   = errors.New("file extension cannot be empty")
+)
+
 // SplitFilename splits a path into the filename and extension.
 // For example, "/path/to/file.txt" becomes ("file", "txt").
 // Returns an error if either the name or extension are empty.
@@ -15,7 +20,7 @@ func SplitFilename(path string) (name, ext string, err error) {
 
 	// Check for invalid filenames first
 	if filename == "" || filename == "." || filename == ".." || filename == "/" {
-		return "", "", errors.New("filename cannot be empty")
+		return "", "", ErrEmptyFilename
 	}
 
 	// Get the extension (includes the dot)
@@ -29,11 +34,46 @@ func SplitFilename(path string) (name, ext string, err error) {
 
 	// Check for empty name or extension
 	if name == "" {
-		return "", "", errors.New("filename cannot be empty")
+		return "", "", ErrEmptyFilename
 	}
 	if ext == "" {
-		return "", "", errors.New("file extension cannot be empty")
+		return "", "", ErrNoExtension
 	}
 
 	return name, ext, nil
 }
+
+// The extensions of compressed files, along with the extension implied for
+// what is inside (e.g. a '.tgz' is a '.tar.gz')
+var compressionExtensions = map[string]string{
+	"gz":   "",
+	"bz2":  "",
+	"zst":  "",
+	"xz":   "",
+	"tgz":  "tar",
+	"tbz2": "tar",
+	"txz":  "tar",
+}
+
+// SplitCompressedFilename is SplitFilename, but looks through a compression
+// extension to the extension of the file inside it, which is returned along
+// with the compression extension.
+// For example, "/var/log/access.log.gz" becomes ("access", "log", "gz") and
+// "export.tgz" becomes ("export", "tar", "tgz").
+// The compression extension is empty if the file is not compressed.
+func SplitCompressedFilename(path string) (name, ext, compression string, err error) {
+	name, ext, err = SplitFilename(path)
+	if err != nil {
+		return name, ext, "", err
+	}
+	innerExt, isCompressed := compressionExtensions[strings.ToLower(ext)]
+	if !isCompressed {
+		return name, ext, "", nil
+	}
+	compression = ext
+	if innerExt != "" {
+		return name, innerExt, compression, nil
+	}
+	name, ext, err = SplitFilename(name)
+	return name, ext, compression, err
+}
@@ -55,3 +55,31 @@ func TestSplitFilename_Errors(t *testing.T) {
 		}
 	}
 }
+
+func TestSplitCompressedFilename(t *testing.T) {
+	tests := []struct {
+		path                string
+		expectedName        string
+		expectedExt         string
+		expectedCompression string
+		expectedErr         error
+	}{
+		{"/var/log/access.log", "access", "log", "", nil},
+		{"/var/log/access.log.gz", "access", "log", "gz", nil},
+		{"/var/log/access.log.2.gz", "access.log", "2", "gz", nil},
+		{"export.csv.ZST", "export", "csv", "ZST", nil},
+		{"export.tar.xz", "export", "tar", "xz", nil},
+		{"export.tgz", "export", "tar", "tgz", nil},
+		{"/tmp/export.gz", "", "", "gz", ErrNoExtension},
+	}
+
+	for _, tt := range tests {
+		name, ext, compression, err := SplitCompressedFilename(tt.path)
+		if err != tt.expectedErr {
+			t.Errorf("SplitCompressedFilename(%q) error = %v, want %v", tt.path, err, tt.expectedErr)
+		}
+		if name != tt.expectedName || ext != tt.expectedExt || compression != tt.expectedCompression {
+			t.Errorf("SplitCompressedFilename(%q) = (%q, %q, %q), want (%q, %q, %q)", tt.path, name, ext, compression, tt.expectedName, tt.expectedExt, tt.expectedCompression)
+		}
+	}
+}